@@ -0,0 +1,298 @@
+package pubsubPlebbitValidator
+
+import (
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "net"
+    "os"
+    "sort"
+    "sync"
+    peer "github.com/libp2p/go-libp2p/core/peer"
+    host "github.com/libp2p/go-libp2p/core/host"
+)
+
+// the json representation of the access list entries, public keys are base64 encoded
+type AccessListEntries struct {
+    PeerIds []string `json:"peerIds,omitempty"`
+    IpRanges []string `json:"ipRanges,omitempty"`
+    PublicKeys []string `json:"publicKeys,omitempty"`
+}
+
+// the json representation of the access list, the format of the access list file
+type AccessListConfig struct {
+    Blocked AccessListEntries `json:"blocked"`
+    Allowed AccessListEntries `json:"allowed"`
+}
+
+type accessListEntries struct {
+    peerIds map[peer.ID]bool
+    // keyed by the cidr string so they can be removed
+    ipRanges map[string]*net.IPNet
+    // keyed by the raw public key bytes
+    publicKeys map[string]bool
+}
+
+// block peer ids, ip ranges and signature public keys, allowed entries take precedence
+// over blocked entries, it can be updated at runtime and is safe for concurrent use
+type AccessList struct {
+    mutex *sync.RWMutex
+    blocked accessListEntries
+    allowed accessListEntries
+}
+
+func newAccessListEntries() accessListEntries {
+    return accessListEntries{
+        peerIds: map[peer.ID]bool{},
+        ipRanges: map[string]*net.IPNet{},
+        publicKeys: map[string]bool{},
+    }
+}
+
+func NewAccessList() *AccessList {
+    return &AccessList{
+        mutex: &sync.RWMutex{},
+        blocked: newAccessListEntries(),
+        allowed: newAccessListEntries(),
+    }
+}
+
+func NewAccessListFromConfig(config AccessListConfig) (*AccessList, error) {
+    accessList := NewAccessList()
+    err := accessList.SetConfig(config)
+    if (err != nil) {
+        return nil, err
+    }
+    return accessList, nil
+}
+
+func LoadAccessListFile(path string) (*AccessList, error) {
+    accessList := NewAccessList()
+    err := accessList.LoadFile(path)
+    if (err != nil) {
+        return nil, err
+    }
+    return accessList, nil
+}
+
+// replace all the entries with the entries of the json access list file
+func (accessList *AccessList) LoadFile(path string) error {
+    file, err := os.ReadFile(path)
+    if (err != nil) {
+        return err
+    }
+    var config AccessListConfig
+    err = json.Unmarshal(file, &config)
+    if (err != nil) {
+        return err
+    }
+    return accessList.SetConfig(config)
+}
+
+// replace all the entries, the access list is unchanged if an entry is invalid
+func (accessList *AccessList) SetConfig(config AccessListConfig) error {
+    blocked := newAccessListEntries()
+    err := blocked.add(config.Blocked)
+    if (err != nil) {
+        return err
+    }
+    allowed := newAccessListEntries()
+    err = allowed.add(config.Allowed)
+    if (err != nil) {
+        return err
+    }
+    accessList.mutex.Lock()
+    defer accessList.mutex.Unlock()
+    accessList.blocked = blocked
+    accessList.allowed = allowed
+    return nil
+}
+
+func (accessList *AccessList) Config() AccessListConfig {
+    accessList.mutex.RLock()
+    defer accessList.mutex.RUnlock()
+    return AccessListConfig{
+        Blocked: accessList.blocked.toConfig(),
+        Allowed: accessList.allowed.toConfig(),
+    }
+}
+
+func (accessList *AccessList) Block(entries AccessListEntries) error {
+    accessList.mutex.Lock()
+    defer accessList.mutex.Unlock()
+    return accessList.blocked.add(entries)
+}
+
+func (accessList *AccessList) Unblock(entries AccessListEntries) error {
+    accessList.mutex.Lock()
+    defer accessList.mutex.Unlock()
+    return accessList.blocked.remove(entries)
+}
+
+func (accessList *AccessList) Allow(entries AccessListEntries) error {
+    accessList.mutex.Lock()
+    defer accessList.mutex.Unlock()
+    return accessList.allowed.add(entries)
+}
+
+func (accessList *AccessList) Disallow(entries AccessListEntries) error {
+    accessList.mutex.Lock()
+    defer accessList.mutex.Unlock()
+    return accessList.allowed.remove(entries)
+}
+
+// the allowed ip ranges, used as IPColocationFactorWhitelist
+func (accessList *AccessList) AllowedIpRanges() []*net.IPNet {
+    accessList.mutex.RLock()
+    defer accessList.mutex.RUnlock()
+    ipRanges := []*net.IPNet{}
+    for _, ipRange := range accessList.allowed.ipRanges {
+        ipRanges = append(ipRanges, ipRange)
+    }
+    return ipRanges
+}
+
+// check the peer id and the IPs the peer is connected from
+func (accessList *AccessList) IsPeerBlocked(peerId peer.ID, host host.Host) bool {
+    accessList.mutex.RLock()
+    defer accessList.mutex.RUnlock()
+    if (accessList.allowed.peerIds[peerId]) {
+        return false
+    }
+    blocked := accessList.blocked.peerIds[peerId]
+
+    // no need to get the peer IPs if there are no ip ranges
+    if (len(accessList.blocked.ipRanges) == 0 && len(accessList.allowed.ipRanges) == 0) {
+        return blocked
+    }
+    for _, peerMultiaddr := range getPeerMultiaddrs(peerId, host) {
+        address, err := classifyMultiaddr(peerMultiaddr)
+        // the IP of a relayed address is the relay's
+        if (err != nil || address.relayed || address.ip == nil) {
+            continue
+        }
+        if (containsIp(accessList.allowed.ipRanges, address.ip)) {
+            return false
+        }
+        if (containsIp(accessList.blocked.ipRanges, address.ip)) {
+            blocked = true
+        }
+    }
+    return blocked
+}
+
+func (accessList *AccessList) IsPublicKeyBlocked(publicKey []byte) bool {
+    accessList.mutex.RLock()
+    defer accessList.mutex.RUnlock()
+    publicKeyString := string(publicKey)
+    return accessList.blocked.publicKeys[publicKeyString] && !accessList.allowed.publicKeys[publicKeyString]
+}
+
+func containsIp(ipRanges map[string]*net.IPNet, ip net.IP) bool {
+    for _, ipRange := range ipRanges {
+        if (ipRange.Contains(ip)) {
+            return true
+        }
+    }
+    return false
+}
+
+// parse a cidr, a single IP is parsed as a /32 or /128 range
+func parseIpRange(ipRangeString string) (*net.IPNet, error) {
+    _, ipRange, err := net.ParseCIDR(ipRangeString)
+    if (err == nil) {
+        return ipRange, nil
+    }
+    ip := net.ParseIP(ipRangeString)
+    if (ip == nil) {
+        return nil, errors.New("invalid ip range " + ipRangeString)
+    }
+    if ip4 := ip.To4(); ip4 != nil {
+        return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+    }
+    return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+type parsedAccessListEntries struct {
+    peerIds []peer.ID
+    ipRanges []*net.IPNet
+    publicKeys [][]byte
+}
+
+func parseAccessListEntries(entries AccessListEntries) (parsedAccessListEntries, error) {
+    parsed := parsedAccessListEntries{}
+    for _, peerIdString := range entries.PeerIds {
+        peerId, err := peer.Decode(peerIdString)
+        if (err != nil) {
+            return parsed, errors.New("invalid peer id " + peerIdString + ": " + err.Error())
+        }
+        parsed.peerIds = append(parsed.peerIds, peerId)
+    }
+    for _, ipRangeString := range entries.IpRanges {
+        ipRange, err := parseIpRange(ipRangeString)
+        if (err != nil) {
+            return parsed, err
+        }
+        parsed.ipRanges = append(parsed.ipRanges, ipRange)
+    }
+    for _, publicKeyString := range entries.PublicKeys {
+        publicKey, err := base64.StdEncoding.DecodeString(publicKeyString)
+        if (err != nil) {
+            return parsed, errors.New("invalid base64 public key " + publicKeyString)
+        }
+        parsed.publicKeys = append(parsed.publicKeys, publicKey)
+    }
+    return parsed, nil
+}
+
+// entries are only added if they are all valid
+func (accessListEntries accessListEntries) add(entries AccessListEntries) error {
+    parsed, err := parseAccessListEntries(entries)
+    if (err != nil) {
+        return err
+    }
+    for _, peerId := range parsed.peerIds {
+        accessListEntries.peerIds[peerId] = true
+    }
+    for _, ipRange := range parsed.ipRanges {
+        accessListEntries.ipRanges[ipRange.String()] = ipRange
+    }
+    for _, publicKey := range parsed.publicKeys {
+        accessListEntries.publicKeys[string(publicKey)] = true
+    }
+    return nil
+}
+
+func (accessListEntries accessListEntries) remove(entries AccessListEntries) error {
+    parsed, err := parseAccessListEntries(entries)
+    if (err != nil) {
+        return err
+    }
+    for _, peerId := range parsed.peerIds {
+        delete(accessListEntries.peerIds, peerId)
+    }
+    for _, ipRange := range parsed.ipRanges {
+        delete(accessListEntries.ipRanges, ipRange.String())
+    }
+    for _, publicKey := range parsed.publicKeys {
+        delete(accessListEntries.publicKeys, string(publicKey))
+    }
+    return nil
+}
+
+func (accessListEntries accessListEntries) toConfig() AccessListEntries {
+    config := AccessListEntries{}
+    for peerId := range accessListEntries.peerIds {
+        config.PeerIds = append(config.PeerIds, peerId.String())
+    }
+    for ipRangeString := range accessListEntries.ipRanges {
+        config.IpRanges = append(config.IpRanges, ipRangeString)
+    }
+    for publicKey := range accessListEntries.publicKeys {
+        config.PublicKeys = append(config.PublicKeys, base64.StdEncoding.EncodeToString([]byte(publicKey)))
+    }
+    sort.Strings(config.PeerIds)
+    sort.Strings(config.IpRanges)
+    sort.Strings(config.PublicKeys)
+    return config
+}
@@ -0,0 +1,168 @@
+package pubsubPlebbitValidator
+
+import (
+    "testing"
+    "context"
+    "encoding/base64"
+    "os"
+    "path/filepath"
+    pubsub "github.com/libp2p/go-libp2p-pubsub"
+    pubsub_pb "github.com/libp2p/go-libp2p-pubsub/pb"
+    peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// call Validate directly without a pubsub network
+func validateMessage(validator Validator, peerId peer.ID, topic string, encodedMessage []byte) pubsub.ValidationResult {
+    pubsubMessage := &pubsub.Message{
+        Message: &pubsub_pb.Message{Data: encodedMessage, Topic: &topic},
+        ReceivedFrom: peerId,
+    }
+    return validator.Validate(context.Background(), peerId, pubsubMessage)
+}
+
+func TestAccessListBlockedPeerId(t *testing.T) {
+    mockHost, peerId := newTestHost()
+    validator := NewValidator(mockHost)
+    encodedMessage := createSignedMessage("CHALLENGEREQUEST", tryGeneratePrivateKey(), nil)
+
+    result := validateMessage(validator, peerId, "topic", encodedMessage)
+    if (result != pubsub.ValidationAccept) {
+        t.Fatalf(`validation result is "%v" instead of "%v"`, result, pubsub.ValidationAccept)
+    }
+
+    // block at runtime
+    err := validator.AccessList().Block(AccessListEntries{PeerIds: []string{peerId.String()}})
+    if (err != nil) {
+        t.Fatalf(`block error is "%v" instead of "<nil>"`, err)
+    }
+    result = validateMessage(validator, peerId, "topic", encodedMessage)
+    if (result != pubsub.ValidationReject) {
+        t.Fatalf(`validation result is "%v" instead of "%v"`, result, pubsub.ValidationReject)
+    }
+    score := validator.AppSpecificScore(peerId)
+    if (score != worstScore) {
+        t.Fatalf(`blocked peer score is "%v" instead of "%v"`, score, worstScore)
+    }
+
+    // allowed entries take precedence
+    err = validator.AccessList().Allow(AccessListEntries{PeerIds: []string{peerId.String()}})
+    if (err != nil) {
+        t.Fatalf(`allow error is "%v" instead of "<nil>"`, err)
+    }
+    result = validateMessage(validator, peerId, "topic", encodedMessage)
+    if (result != pubsub.ValidationAccept) {
+        t.Fatalf(`validation result is "%v" instead of "%v"`, result, pubsub.ValidationAccept)
+    }
+}
+
+func TestAccessListBlockedIpRange(t *testing.T) {
+    mockHost := newMockHost()
+    accessList, err := NewAccessListFromConfig(AccessListConfig{
+        Blocked: AccessListEntries{IpRanges: []string{"1.2.3.0/24", "2001:db8::1"}},
+        Allowed: AccessListEntries{IpRanges: []string{"1.2.3.100"}},
+    })
+    if (err != nil) {
+        t.Fatalf(`NewAccessListFromConfig error is "%v" instead of "<nil>"`, err)
+    }
+    validator := NewValidator(mockHost, WithAccessList(accessList))
+
+    blockedPeerIds := []peer.ID{
+        mockHost.addPeer("/ip4/1.2.3.4/tcp/4001"),
+        mockHost.addPeer("/ip6/2001:db8::1/tcp/4001"),
+    }
+    for _, peerId := range blockedPeerIds {
+        if (!accessList.IsPeerBlocked(peerId, mockHost)) {
+            t.Fatalf(`peer "%v" is not blocked`, peerId)
+        }
+        if (validator.AppSpecificScore(peerId) != worstScore) {
+            t.Fatalf(`blocked peer score is "%v" instead of "%v"`, validator.AppSpecificScore(peerId), worstScore)
+        }
+    }
+    notBlockedPeerIds := []peer.ID{
+        mockHost.addPeer("/ip4/1.2.4.4/tcp/4001"),
+        mockHost.addPeer("/ip4/1.2.3.100/tcp/4001"),
+        mockHost.addPeer("/ip6/2001:db8::2/tcp/4001"),
+        // the IP of a relayed address is the relay's
+        mockHost.addPeer("/ip4/1.2.3.4/tcp/4001/p2p/12D3KooWDpJ7As7BWAwRMfu1VU2WCqNjvq387JEYKDBj4kx6nXTN/p2p-circuit"),
+    }
+    for _, peerId := range notBlockedPeerIds {
+        if (accessList.IsPeerBlocked(peerId, mockHost)) {
+            t.Fatalf(`peer "%v" is blocked`, peerId)
+        }
+    }
+
+    // allowed ip ranges are not penalized by ip colocation
+    peerScoreParams := NewPeerScoreParams(validator)
+    if (len(peerScoreParams.IPColocationFactorWhitelist) != 1 || peerScoreParams.IPColocationFactorWhitelist[0].String() != "1.2.3.100/32") {
+        t.Fatalf(`IPColocationFactorWhitelist is "%v" instead of "[1.2.3.100/32]"`, peerScoreParams.IPColocationFactorWhitelist)
+    }
+}
+
+func TestAccessListBlockedPublicKey(t *testing.T) {
+    mockHost, peerId := newTestHost()
+    validator := NewValidator(mockHost)
+    privateKey := tryGeneratePrivateKey()
+    publicKey := base64.StdEncoding.EncodeToString(getPublicKeyFromPrivateKey(privateKey))
+
+    err := validator.AccessList().Block(AccessListEntries{PublicKeys: []string{publicKey}})
+    if (err != nil) {
+        t.Fatalf(`block error is "%v" instead of "<nil>"`, err)
+    }
+    result := validateMessage(validator, peerId, "topic", createSignedMessage("CHALLENGEREQUEST", privateKey, nil))
+    if (result != pubsub.ValidationReject) {
+        t.Fatalf(`validation result is "%v" instead of "%v"`, result, pubsub.ValidationReject)
+    }
+
+    // other authors are not blocked
+    result = validateMessage(validator, peerId, "topic", createSignedMessage("CHALLENGEREQUEST", tryGeneratePrivateKey(), nil))
+    if (result != pubsub.ValidationAccept) {
+        t.Fatalf(`validation result is "%v" instead of "%v"`, result, pubsub.ValidationAccept)
+    }
+
+    // unblock at runtime
+    err = validator.AccessList().Unblock(AccessListEntries{PublicKeys: []string{publicKey}})
+    if (err != nil) {
+        t.Fatalf(`unblock error is "%v" instead of "<nil>"`, err)
+    }
+    result = validateMessage(validator, peerId, "topic", createSignedMessage("CHALLENGEREQUEST", privateKey, nil))
+    if (result != pubsub.ValidationAccept) {
+        t.Fatalf(`validation result is "%v" instead of "%v"`, result, pubsub.ValidationAccept)
+    }
+}
+
+func TestLoadAccessListFile(t *testing.T) {
+    peerId, err := getPeerIdFromPrivateKey(tryGeneratePrivateKey())
+    if (err != nil) {
+        t.Fatalf(`getPeerIdFromPrivateKey error is "%v" instead of "<nil>"`, err)
+    }
+    path := filepath.Join(t.TempDir(), "access-list.json")
+    file := `{"blocked": {"peerIds": ["` + peerId.String() + `"], "ipRanges": ["10.0.0.0/8"]}}`
+    err = os.WriteFile(path, []byte(file), 0644)
+    if (err != nil) {
+        t.Fatalf(`write file error is "%v" instead of "<nil>"`, err)
+    }
+    accessList, err := LoadAccessListFile(path)
+    if (err != nil) {
+        t.Fatalf(`LoadAccessListFile error is "%v" instead of "<nil>"`, err)
+    }
+    config := accessList.Config()
+    if (len(config.Blocked.PeerIds) != 1 || config.Blocked.PeerIds[0] != peerId.String()) {
+        t.Fatalf(`blocked peer ids are "%v" instead of "[%v]"`, config.Blocked.PeerIds, peerId)
+    }
+    if (len(config.Blocked.IpRanges) != 1 || config.Blocked.IpRanges[0] != "10.0.0.0/8") {
+        t.Fatalf(`blocked ip ranges are "%v" instead of "[10.0.0.0/8]"`, config.Blocked.IpRanges)
+    }
+
+    // reloading an invalid file keeps the previous entries
+    err = os.WriteFile(path, []byte(`{"blocked": {"ipRanges": ["invalid"]}}`), 0644)
+    if (err != nil) {
+        t.Fatalf(`write file error is "%v" instead of "<nil>"`, err)
+    }
+    err = accessList.LoadFile(path)
+    if (err == nil) {
+        t.Fatalf(`LoadFile error is "<nil>" with invalid ip range`)
+    }
+    if (len(accessList.Config().Blocked.PeerIds) != 1) {
+        t.Fatalf(`invalid file changed the access list`)
+    }
+}
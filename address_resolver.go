@@ -0,0 +1,149 @@
+package pubsubPlebbitValidator
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "sync"
+    "time"
+    lru "github.com/hashicorp/golang-lru/v2"
+    peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// resolves a subplebbit address that isn't a peer id, e.g. a domain or ENS name like "memes.eth",
+// to the peer id of the subplebbit public key
+type AddressResolver interface {
+    Resolve(ctx context.Context, address string) (peer.ID, error)
+}
+
+var ErrAddressNotFound = errors.New("address not found")
+
+// the address of the topic couldn't be resolved, the message is ignored instead of rejected,
+// the resolver of the forwarding peer may have resolved it
+var ErrAddressResolution = errors.New("invalid pubsub topic, failed resolve address")
+
+// is ErrAddressResolution and unwraps to the error of the resolver, e.g. ErrAddressNotFound
+type addressResolutionError struct {
+    address string
+    err error
+}
+
+func (addressResolutionError addressResolutionError) Error() string {
+    return fmt.Sprintf("%v %v: %v", ErrAddressResolution, addressResolutionError.address, addressResolutionError.err)
+}
+
+func (addressResolutionError addressResolutionError) Is(target error) bool {
+    return target == ErrAddressResolution
+}
+
+func (addressResolutionError addressResolutionError) Unwrap() error {
+    return addressResolutionError.err
+}
+
+// the time to wait for the resolver before ignoring the message
+var addressResolveTimeout = 5 * time.Second
+
+// the cache of WithAddressResolver
+var defaultAddressTtl = time.Hour
+var defaultAddressErrorTtl = time.Minute
+
+// accept CHALLENGE and CHALLENGEVERIFICATION on topics whose address resolves to the signer,
+// the resolver is wrapped in a CachingAddressResolver unless it's already one or a local StaticAddressResolver
+func WithAddressResolver(addressResolver AddressResolver) ValidatorOption {
+    return func(validator *Validator) {
+        switch addressResolver.(type) {
+        case *CachingAddressResolver, *StaticAddressResolver:
+            validator.addressResolver = addressResolver
+        default:
+            validator.addressResolver = NewCachingAddressResolver(addressResolver, defaultAddressTtl, defaultAddressErrorTtl)
+        }
+    }
+}
+
+// a fixed list of addresses, can be updated at runtime
+type StaticAddressResolver struct {
+    mutex *sync.RWMutex
+    addresses map[string]peer.ID
+}
+
+func NewStaticAddressResolver(addresses map[string]peer.ID) *StaticAddressResolver {
+    staticAddressResolver := &StaticAddressResolver{mutex: &sync.RWMutex{}, addresses: map[string]peer.ID{}}
+    for address, peerId := range addresses {
+        staticAddressResolver.addresses[address] = peerId
+    }
+    return staticAddressResolver
+}
+
+func (staticAddressResolver *StaticAddressResolver) Resolve(ctx context.Context, address string) (peer.ID, error) {
+    staticAddressResolver.mutex.RLock()
+    defer staticAddressResolver.mutex.RUnlock()
+    peerId, ok := staticAddressResolver.addresses[address]
+    if (!ok) {
+        return "", ErrAddressNotFound
+    }
+    return peerId, nil
+}
+
+func (staticAddressResolver *StaticAddressResolver) Set(address string, peerId peer.ID) {
+    staticAddressResolver.mutex.Lock()
+    defer staticAddressResolver.mutex.Unlock()
+    staticAddressResolver.addresses[address] = peerId
+}
+
+func (staticAddressResolver *StaticAddressResolver) Delete(address string) {
+    staticAddressResolver.mutex.Lock()
+    defer staticAddressResolver.mutex.Unlock()
+    delete(staticAddressResolver.addresses, address)
+}
+
+type cachedAddress struct {
+    peerId peer.ID
+    err error
+    expires time.Time
+}
+
+// caches the addresses of a slow resolver like ENS, the failed resolves are cached for errorTtl
+// so a message flood on an unresolvable topic doesn't flood the resolver
+type CachingAddressResolver struct {
+    addressResolver AddressResolver
+    ttl time.Duration
+    errorTtl time.Duration
+    cache *lru.Cache[string, cachedAddress]
+    // the clock of the cache expiry, replaced in tests
+    now func() time.Time
+}
+
+func NewCachingAddressResolver(addressResolver AddressResolver, ttl time.Duration, errorTtl time.Duration) *CachingAddressResolver {
+    cache, _ := lru.New[string, cachedAddress](1000)
+    return &CachingAddressResolver{
+        addressResolver: addressResolver,
+        ttl: ttl,
+        errorTtl: errorTtl,
+        cache: cache,
+        now: time.Now,
+    }
+}
+
+func (cachingAddressResolver *CachingAddressResolver) Resolve(ctx context.Context, address string) (peer.ID, error) {
+    now := cachingAddressResolver.now()
+    cached, ok := cachingAddressResolver.cache.Get(address)
+    if (ok && now.Before(cached.expires)) {
+        return cached.peerId, cached.err
+    }
+    peerId, err := cachingAddressResolver.addressResolver.Resolve(ctx, address)
+    // don't cache the resolves cancelled by the caller, the resolver didn't answer
+    if (ctx.Err() != nil) {
+        return peerId, err
+    }
+    expires := now.Add(cachingAddressResolver.ttl)
+    if (err != nil) {
+        expires = now.Add(cachingAddressResolver.errorTtl)
+    }
+    cachingAddressResolver.cache.Add(address, cachedAddress{peerId, err, expires})
+    return peerId, err
+}
+
+// forget the cached address, e.g. after the owner changed its public key
+func (cachingAddressResolver *CachingAddressResolver) Invalidate(address string) {
+    cachingAddressResolver.cache.Remove(address)
+}
@@ -0,0 +1,157 @@
+package pubsubPlebbitValidator
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+    pubsub "github.com/libp2p/go-libp2p-pubsub"
+    peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// a local resolver that counts the resolves
+type stubAddressResolver struct {
+    addressResolver AddressResolver
+    resolveCount int
+}
+
+func (stubAddressResolver *stubAddressResolver) Resolve(ctx context.Context, address string) (peer.ID, error) {
+    stubAddressResolver.resolveCount++
+    // like a network resolver, a cancelled resolve fails
+    if (ctx.Err() != nil) {
+        return "", ctx.Err()
+    }
+    return stubAddressResolver.addressResolver.Resolve(ctx, address)
+}
+
+func TestValidateAddressTopic(t *testing.T) {
+    mockHost, peerId := newTestHost()
+    otherPeerId, _ := getPeerIdFromPrivateKey(tryGeneratePrivateKey())
+    addressResolver := NewStaticAddressResolver(map[string]peer.ID{
+        "memes.eth": subplebbitPeerId,
+        "other.eth": otherPeerId,
+    })
+
+    // without resolver the topic must be the peer id
+    validator := NewValidator(mockHost)
+    result := validateMessage(validator, peerId, "memes.eth", createSignedMessage("CHALLENGE", subplebbitPrivateKey, nil))
+    if (result != pubsub.ValidationReject) {
+        t.Fatalf(`validation result without resolver is "%v" instead of "%v"`, result, pubsub.ValidationReject)
+    }
+
+    validator = NewValidator(mockHost, WithAddressResolver(addressResolver))
+    results := map[string]pubsub.ValidationResult{
+        "memes.eth": pubsub.ValidationAccept,
+        subplebbitPeerId.String(): pubsub.ValidationAccept,
+        // resolves to another subplebbit
+        "other.eth": pubsub.ValidationReject,
+        // the resolver of the forwarding peer may know it
+        "unknown.eth": pubsub.ValidationIgnore,
+    }
+    for topic, expected := range results {
+        for _, messageType := range []string{"CHALLENGE", "CHALLENGEVERIFICATION"} {
+            result := validateMessage(validator, peerId, topic, createSignedMessage(messageType, subplebbitPrivateKey, nil))
+            if (result != expected) {
+                t.Fatalf(`%v on topic "%v" validation result is "%v" instead of "%v"`, messageType, topic, result, expected)
+            }
+        }
+    }
+
+    // the reasons
+    failed, _ := getFailedChecks(validator.Check("other.eth", createSignedMessage("CHALLENGE", subplebbitPrivateKey, nil)))
+    if (len(failed) != 1 || failed[0] != "topic") {
+        t.Fatalf(`failed checks are "%v" instead of "[topic]"`, failed)
+    }
+    err := validatePubsubTopic(context.Background(), "unknown.eth", map[string]interface{}{}, Signature{publicKey: getPublicKeyFromPrivateKey(subplebbitPrivateKey)}, "CHALLENGE", validator)
+    if (!errors.Is(err, ErrAddressResolution) || !errors.Is(err, ErrAddressNotFound)) {
+        t.Fatalf(`unknown address error is "%v" instead of "%v"`, err, ErrAddressNotFound)
+    }
+
+    // the static addresses can be updated at runtime
+    addressResolver.Set("other.eth", subplebbitPeerId)
+    result = validateMessage(validator, peerId, "other.eth", createSignedMessage("CHALLENGE", subplebbitPrivateKey, nil))
+    if (result != pubsub.ValidationAccept) {
+        t.Fatalf(`updated address validation result is "%v" instead of "%v"`, result, pubsub.ValidationAccept)
+    }
+    addressResolver.Delete("memes.eth")
+    result = validateMessage(validator, peerId, "memes.eth", createSignedMessage("CHALLENGE", subplebbitPrivateKey, nil))
+    if (result != pubsub.ValidationIgnore) {
+        t.Fatalf(`deleted address validation result is "%v" instead of "%v"`, result, pubsub.ValidationIgnore)
+    }
+}
+
+func TestAddressResolverDefaultCache(t *testing.T) {
+    mockHost, peerId := newTestHost()
+    stub := &stubAddressResolver{addressResolver: NewStaticAddressResolver(map[string]peer.ID{"memes.eth": subplebbitPeerId})}
+    validator := NewValidator(mockHost, WithAddressResolver(stub))
+
+    // a network resolver is cached
+    for i := 0; i < 3; i++ {
+        result := validateMessage(validator, peerId, "memes.eth", createSignedMessage("CHALLENGE", subplebbitPrivateKey, nil))
+        if (result != pubsub.ValidationAccept) {
+            t.Fatalf(`validation result is "%v" instead of "%v"`, result, pubsub.ValidationAccept)
+        }
+        validateMessage(validator, peerId, "unknown.eth", createSignedMessage("CHALLENGE", subplebbitPrivateKey, nil))
+    }
+    if (stub.resolveCount != 2) {
+        t.Fatalf(`resolve count is "%v" instead of "2"`, stub.resolveCount)
+    }
+
+    // the resolve is cancelled with the context of Validate
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    err := validatePubsubTopic(ctx, "cancelled.eth", map[string]interface{}{}, Signature{publicKey: getPublicKeyFromPrivateKey(subplebbitPrivateKey)}, "CHALLENGE", validator)
+    if (!errors.Is(err, ErrAddressResolution) || !errors.Is(err, context.Canceled)) {
+        t.Fatalf(`cancelled resolve error is "%v" instead of "%v"`, err, context.Canceled)
+    }
+}
+
+func TestCachingAddressResolver(t *testing.T) {
+    stub := &stubAddressResolver{addressResolver: NewStaticAddressResolver(map[string]peer.ID{"memes.eth": subplebbitPeerId})}
+    cachingAddressResolver := NewCachingAddressResolver(stub, time.Hour, time.Minute)
+    testClock := newTestClock()
+    cachingAddressResolver.now = testClock.now
+    ctx := context.Background()
+
+    for i := 0; i < 3; i++ {
+        peerId, err := cachingAddressResolver.Resolve(ctx, "memes.eth")
+        if (err != nil || peerId != subplebbitPeerId) {
+            t.Fatalf(`resolved "%v" "%v" instead of "%v"`, peerId, err, subplebbitPeerId)
+        }
+        _, err = cachingAddressResolver.Resolve(ctx, "unknown.eth")
+        if (!errors.Is(err, ErrAddressNotFound)) {
+            t.Fatalf(`unknown address error is "%v" instead of "%v"`, err, ErrAddressNotFound)
+        }
+    }
+    if (stub.resolveCount != 2) {
+        t.Fatalf(`resolve count is "%v" instead of "2"`, stub.resolveCount)
+    }
+
+    // the errors expire before the addresses
+    testClock.advance(2 * time.Minute)
+    cachingAddressResolver.Resolve(ctx, "memes.eth")
+    cachingAddressResolver.Resolve(ctx, "unknown.eth")
+    if (stub.resolveCount != 3) {
+        t.Fatalf(`resolve count after error ttl is "%v" instead of "3"`, stub.resolveCount)
+    }
+    testClock.advance(time.Hour)
+    cachingAddressResolver.Resolve(ctx, "memes.eth")
+    if (stub.resolveCount != 4) {
+        t.Fatalf(`resolve count after ttl is "%v" instead of "4"`, stub.resolveCount)
+    }
+
+    cachingAddressResolver.Invalidate("memes.eth")
+    cachingAddressResolver.Resolve(ctx, "memes.eth")
+    if (stub.resolveCount != 5) {
+        t.Fatalf(`resolve count after invalidate is "%v" instead of "5"`, stub.resolveCount)
+    }
+
+    // the cancelled resolves are not cached
+    cancelledCtx, cancel := context.WithCancel(ctx)
+    cancel()
+    cachingAddressResolver.Resolve(cancelledCtx, "cancelled.eth")
+    cachingAddressResolver.Resolve(ctx, "cancelled.eth")
+    if (stub.resolveCount != 7) {
+        t.Fatalf(`resolve count after cancel is "%v" instead of "7"`, stub.resolveCount)
+    }
+}
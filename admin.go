@@ -0,0 +1,383 @@
+package pubsubPlebbitValidator
+
+import (
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "io"
+    "net/http"
+    "sort"
+    peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// a local http api to inspect and manage a running validator, don't expose it publicly
+//
+//    GET /peers              the connected and scored peers with their statistics and scores
+//    GET /challenges         the in-flight challenge request ids with their publishing and relaying peers
+//    POST /blocklist         block the AccessListEntries json body
+//    DELETE /blocklist       unblock the AccessListEntries json body
+//    GET /config             the validator configuration
+//    POST /validate?topic=   run the message checks on the raw message body without updating the peers statistics,
+//                            413 if the body is over the largest MaxMessageSize
+type AdminHandler struct {
+    validator Validator
+    scoreInspector *ScoreInspector
+    scoreConfig *ScoreConfig
+    mux *http.ServeMux
+}
+
+type AdminOption func(*AdminHandler)
+
+// add the gossipsub scores to GET /peers
+func WithAdminScoreInspector(scoreInspector *ScoreInspector) AdminOption {
+    return func(adminHandler *AdminHandler) {
+        adminHandler.scoreInspector = scoreInspector
+    }
+}
+
+// add the peer score config to GET /config
+func WithAdminScoreConfig(scoreConfig *ScoreConfig) AdminOption {
+    return func(adminHandler *AdminHandler) {
+        adminHandler.scoreConfig = scoreConfig
+    }
+}
+
+func NewAdminHandler(validator Validator, options ...AdminOption) *AdminHandler {
+    adminHandler := &AdminHandler{validator: validator, mux: http.NewServeMux()}
+    for _, option := range options {
+        option(adminHandler)
+    }
+    adminHandler.mux.HandleFunc("/peers", adminHandler.handlePeers)
+    adminHandler.mux.HandleFunc("/challenges", adminHandler.handleChallenges)
+    adminHandler.mux.HandleFunc("/blocklist", adminHandler.handleBlocklist)
+    adminHandler.mux.HandleFunc("/config", adminHandler.handleConfig)
+    adminHandler.mux.HandleFunc("/validate", adminHandler.handleValidate)
+    return adminHandler
+}
+
+func (adminHandler *AdminHandler) ServeHTTP(responseWriter http.ResponseWriter, request *http.Request) {
+    adminHandler.mux.ServeHTTP(responseWriter, request)
+}
+
+type adminError struct {
+    Error string `json:"error"`
+}
+
+func writeJson(responseWriter http.ResponseWriter, status int, value interface{}) {
+    responseWriter.Header().Set("Content-Type", "application/json")
+    responseWriter.WriteHeader(status)
+    json.NewEncoder(responseWriter).Encode(value)
+}
+
+func writeError(responseWriter http.ResponseWriter, status int, message string) {
+    writeJson(responseWriter, status, adminError{message})
+}
+
+func allowMethods(responseWriter http.ResponseWriter, request *http.Request, methods ...string) bool {
+    for _, method := range methods {
+        if (request.Method == method) {
+            return true
+        }
+    }
+    responseWriter.Header().Set("Allow", methods[0])
+    writeError(responseWriter, http.StatusMethodNotAllowed, "method not allowed")
+    return false
+}
+
+type AdminPeer struct {
+    PeerId string `json:"peerId"`
+    Connected bool `json:"connected"`
+    Blocked bool `json:"blocked"`
+    Trusted bool `json:"trusted"`
+    AppSpecificScore float64 `json:"appSpecificScore"`
+    // only with WithAdminScoreInspector, nil if gossipsub has no score for the peer
+    Score *float64 `json:"score,omitempty"`
+    ChallengeCount float64 `json:"challengeCount"`
+    CompletedChallengeCount float64 `json:"completedChallengeCount"`
+    RelayedChallengeCount float64 `json:"relayedChallengeCount"`
+    RelayedCompletedChallengeCount float64 `json:"relayedCompletedChallengeCount"`
+}
+
+func (adminHandler *AdminHandler) handlePeers(responseWriter http.ResponseWriter, request *http.Request) {
+    if (!allowMethods(responseWriter, request, http.MethodGet)) {
+        return
+    }
+    validator := adminHandler.validator
+    peerIds := map[peer.ID]bool{}
+    connectedPeerIds := map[peer.ID]bool{}
+    if (validator.host != nil) {
+        for _, peerId := range validator.host.Network().Peers() {
+            peerIds[peerId] = true
+            connectedPeerIds[peerId] = true
+        }
+    }
+    scores := map[peer.ID]float64{}
+    if (adminHandler.scoreInspector != nil) {
+        for _, peerReport := range adminHandler.scoreInspector.PeerReports() {
+            peerIds[peerReport.PeerId] = true
+            scores[peerReport.PeerId] = peerReport.Score
+        }
+    }
+
+    adminPeers := []AdminPeer{}
+    for peerId := range peerIds {
+        peerStatistics := getPeerStatistics(peerId, validator)
+        relayStatistics := getRelayStatistics(peerId, validator)
+        adminPeer := AdminPeer{
+            PeerId: peerId.String(),
+            Connected: connectedPeerIds[peerId],
+            Blocked: validator.accessList.IsPeerBlocked(peerId, validator.host),
+            Trusted: validator.trustedPeers[peerId],
+            AppSpecificScore: validator.AppSpecificScore(peerId),
+            ChallengeCount: peerStatistics.challengeCount,
+            CompletedChallengeCount: peerStatistics.completedChallengeCount,
+            RelayedChallengeCount: relayStatistics.challengeCount,
+            RelayedCompletedChallengeCount: relayStatistics.completedChallengeCount,
+        }
+        score, ok := scores[peerId]
+        if (ok) {
+            adminPeer.Score = &score
+        }
+        adminPeers = append(adminPeers, adminPeer)
+    }
+    sort.Slice(adminPeers, func(i, j int) bool {
+        return adminPeers[i].PeerId < adminPeers[j].PeerId
+    })
+    writeJson(responseWriter, http.StatusOK, adminPeers)
+}
+
+type AdminChallenge struct {
+    ChallengeRequestId string `json:"challengeRequestId"`
+    // the peer ids or the peer hostnames with WithPeerHostnameStatistics
+    Publishers []string `json:"publishers"`
+    Relays []string `json:"relays"`
+}
+
+// the peer id string of a peer statistics key, or the hostname
+func formatPeerStatisticsKey(peerStatisticsKey string) string {
+    peerId, err := peer.IDFromBytes([]byte(peerStatisticsKey))
+    if (err != nil) {
+        return peerStatisticsKey
+    }
+    return peerId.String()
+}
+
+func formatPeerStatisticsKeys(peerStatisticsKeys map[string]bool) []string {
+    formatted := []string{}
+    for peerStatisticsKey := range peerStatisticsKeys {
+        formatted = append(formatted, formatPeerStatisticsKey(peerStatisticsKey))
+    }
+    sort.Strings(formatted)
+    return formatted
+}
+
+func (adminHandler *AdminHandler) handleChallenges(responseWriter http.ResponseWriter, request *http.Request) {
+    if (!allowMethods(responseWriter, request, http.MethodGet)) {
+        return
+    }
+    validator := adminHandler.validator
+    validator.statisticsMutex.Lock()
+    adminChallenges := []AdminChallenge{}
+    for _, challengeRequestId := range validator.challenges.Keys() {
+        challengePeers, ok := validator.challenges.Peek(challengeRequestId)
+        if (!ok) {
+            continue
+        }
+        // the challenge request id is the peer id of the author, but it's not validated for subplebbit messages
+        formattedChallengeRequestId := base64.StdEncoding.EncodeToString([]byte(challengeRequestId))
+        peerId, err := peer.IDFromBytes([]byte(challengeRequestId))
+        if (err == nil) {
+            formattedChallengeRequestId = peerId.String()
+        }
+        adminChallenges = append(adminChallenges, AdminChallenge{
+            ChallengeRequestId: formattedChallengeRequestId,
+            Publishers: formatPeerStatisticsKeys(challengePeers.originators),
+            Relays: formatPeerStatisticsKeys(challengePeers.relays),
+        })
+    }
+    validator.statisticsMutex.Unlock()
+    writeJson(responseWriter, http.StatusOK, adminChallenges)
+}
+
+func (adminHandler *AdminHandler) handleBlocklist(responseWriter http.ResponseWriter, request *http.Request) {
+    if (!allowMethods(responseWriter, request, http.MethodPost, http.MethodDelete)) {
+        return
+    }
+    entries := AccessListEntries{}
+    err := json.NewDecoder(request.Body).Decode(&entries)
+    if (err != nil) {
+        writeError(responseWriter, http.StatusBadRequest, "invalid access list entries: " + err.Error())
+        return
+    }
+    accessList := adminHandler.validator.accessList
+    if (request.Method == http.MethodPost) {
+        err = accessList.Block(entries)
+    } else {
+        err = accessList.Unblock(entries)
+    }
+    if (err != nil) {
+        writeError(responseWriter, http.StatusBadRequest, err.Error())
+        return
+    }
+    writeJson(responseWriter, http.StatusOK, accessList.Config().Blocked)
+}
+
+type AdminConfig struct {
+    AccessList AccessListConfig `json:"accessList"`
+    TrustedPeers []string `json:"trustedPeers"`
+    PeerHostnameStatistics bool `json:"peerHostnameStatistics"`
+    StatisticsHalfLife string `json:"statisticsHalfLife"`
+    StatisticsDecayInterval string `json:"statisticsDecayInterval"`
+    MinimumChallengeCount uint `json:"minimumChallengeCount"`
+    WorstScore float64 `json:"worstScore"`
+    TrustedPeerScore float64 `json:"trustedPeerScore"`
+    RelayPenaltyWeight float64 `json:"relayPenaltyWeight"`
+    RelayWorstScore float64 `json:"relayWorstScore"`
+    // the minimum is included, the maximum is excluded
+    MinimumProtocolVersion string `json:"minimumProtocolVersion"`
+    MaximumProtocolVersion string `json:"maximumProtocolVersion"`
+    MessageLimits map[string]MessageLimits `json:"messageLimits"`
+    // empty if the strict challenge responses are disabled
+    StrictChallengeResponsesTtl string `json:"strictChallengeResponsesTtl,omitempty"`
+    StrictChallengeResponsesSize int `json:"strictChallengeResponsesSize,omitempty"`
+    // only with WithProofOfWork
+    ProofOfWork *AdminProofOfWork `json:"proofOfWork,omitempty"`
+    // only with WithLoadShedding
+    LoadShedding *AdminLoadShedding `json:"loadShedding,omitempty"`
+    // only with WithAdminScoreConfig
+    ScoreConfig *ScoreConfig `json:"scoreConfig,omitempty"`
+}
+
+type AdminProofOfWork struct {
+    MinimumDifficulty int `json:"minimumDifficulty"`
+    MaximumDifficulty int `json:"maximumDifficulty"`
+    TargetRate float64 `json:"targetRate"`
+    RateHalfLife string `json:"rateHalfLife"`
+    // the current difficulty of the topics with a fixed difficulty or an observed load
+    Difficulties map[string]int `json:"difficulties"`
+}
+
+type AdminLoadShedding struct {
+    TargetRate float64 `json:"targetRate"`
+    RateHalfLife string `json:"rateHalfLife"`
+    MaxInFlight int `json:"maxInFlight"`
+    PriorityCompletedChallengeCount float64 `json:"priorityCompletedChallengeCount"`
+    InFlight int `json:"inFlight"`
+    // the current load of the topics with an observed rate, over 1 is overloaded
+    Loads map[string]float64 `json:"loads"`
+}
+
+func (adminHandler *AdminHandler) handleConfig(responseWriter http.ResponseWriter, request *http.Request) {
+    if (!allowMethods(responseWriter, request, http.MethodGet)) {
+        return
+    }
+    validator := adminHandler.validator
+    trustedPeers := []string{}
+    for peerId := range validator.trustedPeers {
+        trustedPeers = append(trustedPeers, peerId.String())
+    }
+    sort.Strings(trustedPeers)
+    strictChallengeResponsesTtl := ""
+    if (validator.seenChallengeRequestTtl != 0) {
+        strictChallengeResponsesTtl = validator.seenChallengeRequestTtl.String()
+    }
+    var proofOfWork *AdminProofOfWork
+    if (validator.proofOfWork != nil) {
+        proofOfWorkConfig := validator.proofOfWork.Config()
+        proofOfWork = &AdminProofOfWork{
+            MinimumDifficulty: proofOfWorkConfig.MinimumDifficulty,
+            MaximumDifficulty: proofOfWorkConfig.MaximumDifficulty,
+            TargetRate: proofOfWorkConfig.TargetRate,
+            RateHalfLife: proofOfWorkConfig.RateHalfLife.String(),
+            Difficulties: validator.proofOfWork.Difficulties(),
+        }
+    }
+    var loadShedding *AdminLoadShedding
+    if (validator.loadShedding != nil) {
+        loadSheddingConfig := validator.loadShedding.Config()
+        loadShedding = &AdminLoadShedding{
+            TargetRate: loadSheddingConfig.TargetRate,
+            RateHalfLife: loadSheddingConfig.RateHalfLife.String(),
+            MaxInFlight: loadSheddingConfig.MaxInFlight,
+            PriorityCompletedChallengeCount: loadSheddingConfig.PriorityCompletedChallengeCount,
+            InFlight: validator.loadShedding.InFlight(),
+            Loads: validator.loadShedding.Loads(),
+        }
+    }
+    writeJson(responseWriter, http.StatusOK, AdminConfig{
+        AccessList: validator.accessList.Config(),
+        TrustedPeers: trustedPeers,
+        PeerHostnameStatistics: validator.peerHostnameStatistics,
+        StatisticsHalfLife: validator.statisticsHalfLife.String(),
+        StatisticsDecayInterval: validator.statisticsDecayInterval.String(),
+        MinimumChallengeCount: minimumChallengeCount,
+        WorstScore: worstScore,
+        TrustedPeerScore: trustedPeerScore,
+        RelayPenaltyWeight: relayPenaltyWeight,
+        RelayWorstScore: relayWorstScore,
+        MinimumProtocolVersion: validator.minimumProtocolVersion.String(),
+        MaximumProtocolVersion: validator.maximumProtocolVersion.String(),
+        MessageLimits: validator.messageLimits,
+        StrictChallengeResponsesTtl: strictChallengeResponsesTtl,
+        StrictChallengeResponsesSize: validator.seenChallengeRequestsSize,
+        ProofOfWork: proofOfWork,
+        LoadShedding: loadShedding,
+        ScoreConfig: adminHandler.scoreConfig,
+    })
+}
+
+type AdminCheckResult struct {
+    Name string `json:"name"`
+    Passed bool `json:"passed"`
+    Skipped bool `json:"skipped,omitempty"`
+    Error string `json:"error,omitempty"`
+}
+
+// gossipsub drops messages over 1 MiB
+var maxValidateSize int = 1024 * 1024
+
+// the largest message size limit, a message over it can't pass the checks
+func getMaxValidateSize(validator Validator) int {
+    maxSize := 0
+    for _, messageLimits := range validator.messageLimits {
+        if (messageLimits.MaxMessageSize == 0) {
+            return maxValidateSize
+        }
+        if (messageLimits.MaxMessageSize > maxSize) {
+            maxSize = messageLimits.MaxMessageSize
+        }
+    }
+    if (maxSize == 0) {
+        return maxValidateSize
+    }
+    return maxSize
+}
+
+func (adminHandler *AdminHandler) handleValidate(responseWriter http.ResponseWriter, request *http.Request) {
+    if (!allowMethods(responseWriter, request, http.MethodPost)) {
+        return
+    }
+    data, err := io.ReadAll(http.MaxBytesReader(responseWriter, request.Body, int64(getMaxValidateSize(adminHandler.validator))))
+    if (err != nil) {
+        maxBytesError := &http.MaxBytesError{}
+        if (errors.As(err, &maxBytesError)) {
+            writeError(responseWriter, http.StatusRequestEntityTooLarge, err.Error())
+            return
+        }
+        writeError(responseWriter, http.StatusBadRequest, err.Error())
+        return
+    }
+    adminCheckResults := []AdminCheckResult{}
+    for _, checkResult := range adminHandler.validator.Check(request.URL.Query().Get("topic"), data) {
+        adminCheckResult := AdminCheckResult{
+            Name: checkResult.Name,
+            Passed: !checkResult.Skipped && checkResult.Err == nil,
+            Skipped: checkResult.Skipped,
+        }
+        if (checkResult.Err != nil) {
+            adminCheckResult.Error = checkResult.Err.Error()
+        }
+        adminCheckResults = append(adminCheckResults, adminCheckResult)
+    }
+    writeJson(responseWriter, http.StatusOK, adminCheckResults)
+}
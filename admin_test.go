@@ -0,0 +1,179 @@
+package pubsubPlebbitValidator
+
+import (
+    "bytes"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+// send a request to the admin handler and decode the json response
+func adminRequest(t *testing.T, adminHandler *AdminHandler, method string, url string, body []byte, response interface{}) int {
+    recorder := httptest.NewRecorder()
+    adminHandler.ServeHTTP(recorder, httptest.NewRequest(method, url, bytes.NewReader(body)))
+    if (response != nil) {
+        err := json.Unmarshal(recorder.Body.Bytes(), response)
+        if (err != nil) {
+            t.Fatalf(`%v %v response "%v" is not json: %v`, method, url, recorder.Body.String(), err)
+        }
+    }
+    return recorder.Code
+}
+
+func TestAdminPeers(t *testing.T) {
+    mockHost := newMockHost()
+    validator := NewValidator(mockHost)
+    validator.now = newTestClock().now
+    spamPeerId := mockHost.addPeer("/ip4/1.2.3.4/tcp/4001")
+    mockHost.addConn(spamPeerId, "/ip4/1.2.3.4/tcp/4001")
+    sendFailedChallenges(validator, spamPeerId, int(minimumChallengeCount))
+    adminHandler := NewAdminHandler(validator)
+
+    adminPeers := []AdminPeer{}
+    status := adminRequest(t, adminHandler, http.MethodGet, "/peers", nil, &adminPeers)
+    if (status != http.StatusOK || len(adminPeers) != 1) {
+        t.Fatalf(`GET /peers is "%v" "%+v"`, status, adminPeers)
+    }
+    if (adminPeers[0].PeerId != spamPeerId.String() || !adminPeers[0].Connected || adminPeers[0].Score != nil) {
+        t.Fatalf(`peer is "%+v"`, adminPeers[0])
+    }
+    if (adminPeers[0].AppSpecificScore != worstScore || adminPeers[0].ChallengeCount != float64(minimumChallengeCount)) {
+        t.Fatalf(`peer score is "%v" and challenge count is "%v" instead of "%v" and "%v"`, adminPeers[0].AppSpecificScore, adminPeers[0].ChallengeCount, worstScore, minimumChallengeCount)
+    }
+
+    status = adminRequest(t, adminHandler, http.MethodPost, "/peers", nil, nil)
+    if (status != http.StatusMethodNotAllowed) {
+        t.Fatalf(`POST /peers status is "%v" instead of "%v"`, status, http.StatusMethodNotAllowed)
+    }
+}
+
+func TestAdminChallenges(t *testing.T) {
+    mockHost := newMockHost()
+    validator := NewValidator(mockHost)
+    relayPeerId := mockHost.addPeer("/ip4/5.6.7.8/tcp/4001")
+    authorPeerId := mockHost.addPeer("/ip4/1.2.3.4/tcp/4001")
+    validatePeer(map[string]interface{}{}, []byte(authorPeerId), relayPeerId, authorPeerId, "CHALLENGEREQUEST", validator)
+    adminHandler := NewAdminHandler(validator)
+
+    adminChallenges := []AdminChallenge{}
+    adminRequest(t, adminHandler, http.MethodGet, "/challenges", nil, &adminChallenges)
+    if (len(adminChallenges) != 1 || adminChallenges[0].ChallengeRequestId != authorPeerId.String()) {
+        t.Fatalf(`challenges are "%+v"`, adminChallenges)
+    }
+    if (len(adminChallenges[0].Publishers) != 1 || adminChallenges[0].Publishers[0] != authorPeerId.String()) {
+        t.Fatalf(`publishers are "%v" instead of "[%v]"`, adminChallenges[0].Publishers, authorPeerId)
+    }
+    if (len(adminChallenges[0].Relays) != 1 || adminChallenges[0].Relays[0] != relayPeerId.String()) {
+        t.Fatalf(`relays are "%v" instead of "[%v]"`, adminChallenges[0].Relays, relayPeerId)
+    }
+
+    // completed challenges are no longer in flight
+    validatePeer(map[string]interface{}{}, []byte(authorPeerId), relayPeerId, "", "CHALLENGEVERIFICATION", validator)
+    adminRequest(t, adminHandler, http.MethodGet, "/challenges", nil, &adminChallenges)
+    if (len(adminChallenges) != 0) {
+        t.Fatalf(`challenges are "%+v" instead of none`, adminChallenges)
+    }
+}
+
+func TestAdminBlocklist(t *testing.T) {
+    mockHost, peerId := newTestHost()
+    validator := NewValidator(mockHost)
+    adminHandler := NewAdminHandler(validator)
+    body := []byte(`{"peerIds": ["` + peerId.String() + `"]}`)
+
+    blocked := AccessListEntries{}
+    status := adminRequest(t, adminHandler, http.MethodPost, "/blocklist", body, &blocked)
+    if (status != http.StatusOK || len(blocked.PeerIds) != 1 || !validator.AccessList().IsPeerBlocked(peerId, mockHost)) {
+        t.Fatalf(`POST /blocklist is "%v" "%+v"`, status, blocked)
+    }
+    if (validateMessage(validator, peerId, "topic", createSignedMessage("CHALLENGEREQUEST", tryGeneratePrivateKey(), nil)) == pubsub.ValidationAccept) {
+        t.Fatalf(`blocked peer message was accepted`)
+    }
+
+    blocked = AccessListEntries{}
+    status = adminRequest(t, adminHandler, http.MethodDelete, "/blocklist", body, &blocked)
+    if (status != http.StatusOK || len(blocked.PeerIds) != 0 || validator.AccessList().IsPeerBlocked(peerId, mockHost)) {
+        t.Fatalf(`DELETE /blocklist is "%v" "%+v"`, status, blocked)
+    }
+
+    adminErr := adminError{}
+    status = adminRequest(t, adminHandler, http.MethodPost, "/blocklist", []byte(`{"ipRanges": ["invalid"]}`), &adminErr)
+    if (status != http.StatusBadRequest || adminErr.Error == "") {
+        t.Fatalf(`invalid POST /blocklist is "%v" "%+v"`, status, adminErr)
+    }
+}
+
+func TestAdminConfig(t *testing.T) {
+    mockHost := newMockHost()
+    trustedPeerId := mockHost.addPeer("/ip4/1.2.3.4/tcp/4001")
+    validator := NewValidator(mockHost, WithTrustedPeers(trustedPeerId))
+    scoreConfig, _ := NewScoreConfig(ScorePresetDefault)
+    adminHandler := NewAdminHandler(validator, WithAdminScoreConfig(scoreConfig))
+
+    adminConfig := AdminConfig{}
+    adminRequest(t, adminHandler, http.MethodGet, "/config", nil, &adminConfig)
+    if (len(adminConfig.TrustedPeers) != 1 || adminConfig.TrustedPeers[0] != trustedPeerId.String()) {
+        t.Fatalf(`trusted peers are "%v" instead of "[%v]"`, adminConfig.TrustedPeers, trustedPeerId)
+    }
+    if (adminConfig.StatisticsHalfLife != defaultStatisticsHalfLife.String() || adminConfig.MinimumChallengeCount != minimumChallengeCount) {
+        t.Fatalf(`config is "%+v"`, adminConfig)
+    }
+    if (adminConfig.ScoreConfig == nil || adminConfig.ScoreConfig.AppSpecificWeight != scoreConfig.AppSpecificWeight) {
+        t.Fatalf(`score config is "%+v" instead of "%+v"`, adminConfig.ScoreConfig, scoreConfig)
+    }
+    if (adminConfig.ProofOfWork != nil) {
+        t.Fatalf(`proof of work is "%+v" instead of "<nil>"`, adminConfig.ProofOfWork)
+    }
+
+    proofOfWork := NewProofOfWork(DefaultProofOfWorkConfig)
+    proofOfWork.SetDifficulty("topic", 12)
+    adminConfig = AdminConfig{}
+    adminRequest(t, NewAdminHandler(NewValidator(mockHost, WithProofOfWork(proofOfWork))), http.MethodGet, "/config", nil, &adminConfig)
+    if (adminConfig.ProofOfWork == nil || adminConfig.ProofOfWork.RateHalfLife != "1m0s" || adminConfig.ProofOfWork.Difficulties["topic"] != 12) {
+        t.Fatalf(`proof of work is "%+v"`, adminConfig.ProofOfWork)
+    }
+    if (adminConfig.LoadShedding != nil) {
+        t.Fatalf(`load shedding is "%+v" instead of "<nil>"`, adminConfig.LoadShedding)
+    }
+
+    adminConfig = AdminConfig{}
+    adminRequest(t, NewAdminHandler(NewValidator(mockHost, WithLoadShedding(NewLoadShedding(DefaultLoadSheddingConfig)))), http.MethodGet, "/config", nil, &adminConfig)
+    if (adminConfig.LoadShedding == nil || adminConfig.LoadShedding.MaxInFlight != DefaultLoadSheddingConfig.MaxInFlight || adminConfig.LoadShedding.InFlight != 0) {
+        t.Fatalf(`load shedding is "%+v"`, adminConfig.LoadShedding)
+    }
+}
+
+func TestAdminValidate(t *testing.T) {
+    validator := NewValidator(newMockHost())
+    adminHandler := NewAdminHandler(validator)
+
+    adminCheckResults := []AdminCheckResult{}
+    status := adminRequest(t, adminHandler, http.MethodPost, "/validate?topic=" + subplebbitPeerId.String(), createSignedMessage("CHALLENGEREQUEST", tryGeneratePrivateKey(), nil), &adminCheckResults)
+    if (status != http.StatusOK || len(adminCheckResults) != len(messageChecks) + 1) {
+        t.Fatalf(`POST /validate is "%v" "%+v"`, status, adminCheckResults)
+    }
+    for _, adminCheckResult := range adminCheckResults {
+        if (!adminCheckResult.Passed) {
+            t.Fatalf(`check "%+v" didn't pass`, adminCheckResult)
+        }
+    }
+
+    adminRequest(t, adminHandler, http.MethodPost, "/validate", []byte("invalid message"), &adminCheckResults)
+    if (adminCheckResults[0].Passed || adminCheckResults[0].Error == "" || !adminCheckResults[1].Skipped) {
+        t.Fatalf(`invalid message check results are "%+v"`, adminCheckResults)
+    }
+
+    // the body is limited to the largest message size
+    adminErr := adminError{}
+    status = adminRequest(t, adminHandler, http.MethodPost, "/validate", make([]byte, 256 * 1024 + 1), &adminErr)
+    if (status != http.StatusRequestEntityTooLarge || adminErr.Error == "") {
+        t.Fatalf(`POST /validate over the size limit is "%v" "%+v"`, status, adminErr)
+    }
+
+    // the dry run doesn't update the peers statistics
+    if (validator.challenges.Len() != 0 || validator.peersStatistics.Len() != 0) {
+        t.Fatalf(`POST /validate updated the peers statistics`)
+    }
+}
@@ -0,0 +1,78 @@
+package pubsubPlebbitValidator
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "errors"
+    "fmt"
+    cid "github.com/ipfs/go-cid"
+    crypto "github.com/libp2p/go-libp2p/core/crypto"
+    multihash "github.com/multiformats/go-multihash"
+)
+
+// the challengeRequestId is the peer id of the author public key, clients encode it as:
+//
+//    identity multihash of the protobuf public key, the libp2p default for ed25519 keys
+//    sha2-256 multihash of the protobuf public key, the libp2p default for keys longer than 42 bytes
+//    CIDv1 with the libp2p-key codec of one of these multihashes
+func decodeChallengeRequestId(challengeRequestId []byte) (*multihash.DecodedMultihash, error) {
+    if (len(challengeRequestId) == 0) {
+        return nil, errors.New("empty challenge request id")
+    }
+    // a multihash can't start with 1, it's not a hash function code
+    if (challengeRequestId[0] == 1) {
+        challengeRequestIdCid, err := cid.Cast(challengeRequestId)
+        if (err != nil) {
+            return nil, fmt.Errorf("failed cid.Cast(challengeRequestId): %w", err)
+        }
+        if (challengeRequestIdCid.Type() != cid.Libp2pKey) {
+            return nil, fmt.Errorf("cid codec is 0x%x instead of libp2p-key 0x%x", challengeRequestIdCid.Type(), cid.Libp2pKey)
+        }
+        challengeRequestId = challengeRequestIdCid.Hash()
+    }
+    decodedMultihash, err := multihash.Decode(challengeRequestId)
+    if (err != nil) {
+        return nil, fmt.Errorf("failed multihash.Decode(challengeRequestId): %w", err)
+    }
+    if (decodedMultihash.Code != multihash.IDENTITY && decodedMultihash.Code != multihash.SHA2_256) {
+        return nil, fmt.Errorf("multihash %v is not identity or sha2-256", decodedMultihash.Name)
+    }
+    return decodedMultihash, nil
+}
+
+// the reason the challengeRequestId is not the peer id of the ed25519 signature public key
+func matchChallengeRequestId(challengeRequestId []byte, signaturePublicKey []byte) error {
+    publicKey, err := crypto.UnmarshalEd25519PublicKey(signaturePublicKey)
+    if (err != nil) {
+        return fmt.Errorf("failed crypto.UnmarshalEd25519PublicKey(signature.publicKey): %w", err)
+    }
+    decodedMultihash, err := decodeChallengeRequestId(challengeRequestId)
+    if (err != nil) {
+        return err
+    }
+
+    if (decodedMultihash.Code == multihash.IDENTITY) {
+        challengeRequestIdPublicKey, err := crypto.UnmarshalPublicKey(decodedMultihash.Digest)
+        if (err != nil) {
+            return fmt.Errorf("identity multihash is not a public key, failed crypto.UnmarshalPublicKey: %w", err)
+        }
+        if (challengeRequestIdPublicKey.Type() != crypto.Ed25519) {
+            return fmt.Errorf("public key type is %v instead of the signature type Ed25519", challengeRequestIdPublicKey.Type())
+        }
+        if (!challengeRequestIdPublicKey.Equals(publicKey)) {
+            return errors.New("public key is not signature.publicKey")
+        }
+        return nil
+    }
+
+    // the sha2-256 digest can't be reversed, only compared
+    protobufPublicKey, err := crypto.MarshalPublicKey(publicKey)
+    if (err != nil) {
+        return fmt.Errorf("failed crypto.MarshalPublicKey(signature.publicKey): %w", err)
+    }
+    digest := sha256.Sum256(protobufPublicKey)
+    if (!bytes.Equal(decodedMultihash.Digest, digest[:])) {
+        return errors.New("sha2-256 digest is not the digest of signature.publicKey, it's a different public key or key type")
+    }
+    return nil
+}
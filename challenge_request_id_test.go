@@ -0,0 +1,90 @@
+package pubsubPlebbitValidator
+
+import (
+    "crypto/rand"
+    "strings"
+    "testing"
+    cid "github.com/ipfs/go-cid"
+    pubsub "github.com/libp2p/go-libp2p-pubsub"
+    crypto "github.com/libp2p/go-libp2p/core/crypto"
+    multihash "github.com/multiformats/go-multihash"
+)
+
+// the multihash of the protobuf public key
+func getPublicKeyMultihash(publicKey crypto.PubKey, code uint64) []byte {
+    protobufPublicKey, err := crypto.MarshalPublicKey(publicKey)
+    if (err != nil) {
+        panic(err)
+    }
+    publicKeyMultihash, err := multihash.Sum(protobufPublicKey, code, -1)
+    if (err != nil) {
+        panic(err)
+    }
+    return publicKeyMultihash
+}
+
+func TestMatchChallengeRequestId(t *testing.T) {
+    signaturePublicKey := getPublicKeyFromPrivateKey(tryGeneratePrivateKey())
+    publicKey, _ := crypto.UnmarshalEd25519PublicKey(signaturePublicKey)
+    identityMultihash := getPublicKeyMultihash(publicKey, multihash.IDENTITY)
+    sha256Multihash := getPublicKeyMultihash(publicKey, multihash.SHA2_256)
+    peerId, _ := getPeerIdFromPublicKey(signaturePublicKey)
+
+    valid := map[string][]byte{
+        "peer id": []byte(peerId),
+        "identity multihash": identityMultihash,
+        "sha2-256 multihash": sha256Multihash,
+        "identity CIDv1": cid.NewCidV1(cid.Libp2pKey, identityMultihash).Bytes(),
+        "sha2-256 CIDv1": cid.NewCidV1(cid.Libp2pKey, sha256Multihash).Bytes(),
+    }
+    for name, challengeRequestId := range valid {
+        err := matchChallengeRequestId(challengeRequestId, signaturePublicKey)
+        if (err != nil) {
+            t.Fatalf(`%v match error is "%v" instead of "<nil>"`, name, err)
+        }
+    }
+
+    otherPublicKey, _ := crypto.UnmarshalEd25519PublicKey(getPublicKeyFromPrivateKey(tryGeneratePrivateKey()))
+    _, secp256k1PublicKey, _ := crypto.GenerateSecp256k1Key(rand.Reader)
+    blake2bMultihash := getPublicKeyMultihash(publicKey, multihash.BLAKE2B_MIN + 31)
+    // the mismatch reasons
+    invalid := map[string]struct{challengeRequestId []byte; reason string}{
+        "empty": {[]byte{}, "empty challenge request id"},
+        "not a multihash": {[]byte("not a multihash"), "failed multihash.Decode"},
+        "blake2b multihash": {blake2bMultihash, "is not identity or sha2-256"},
+        "dag-pb CIDv1": {cid.NewCidV1(cid.DagProtobuf, identityMultihash).Bytes(), "libp2p-key"},
+        "invalid CIDv1": {[]byte{1, 0x72}, "failed cid.Cast"},
+        "identity multihash of bytes": {[]byte{0, 3, 1, 2, 3}, "identity multihash is not a public key"},
+        "other ed25519 identity multihash": {getPublicKeyMultihash(otherPublicKey, multihash.IDENTITY), "public key is not signature.publicKey"},
+        "other ed25519 sha2-256 multihash": {getPublicKeyMultihash(otherPublicKey, multihash.SHA2_256), "sha2-256 digest is not the digest of signature.publicKey"},
+        "secp256k1 identity multihash": {getPublicKeyMultihash(secp256k1PublicKey, multihash.IDENTITY), "public key type is Secp256k1"},
+        "secp256k1 sha2-256 multihash": {getPublicKeyMultihash(secp256k1PublicKey, multihash.SHA2_256), "sha2-256 digest is not the digest of signature.publicKey"},
+    }
+    for name, test := range invalid {
+        err := matchChallengeRequestId(test.challengeRequestId, signaturePublicKey)
+        if (err == nil || !strings.Contains(err.Error(), test.reason)) {
+            t.Fatalf(`%v match error is "%v" instead of "%v"`, name, err, test.reason)
+        }
+    }
+
+    err := matchChallengeRequestId([]byte(peerId), []byte("not an ed25519 public key"))
+    if (err == nil || !strings.Contains(err.Error(), "signature.publicKey")) {
+        t.Fatalf(`invalid signature public key match error is "%v"`, err)
+    }
+}
+
+func TestValidateChallengeRequestIdVariants(t *testing.T) {
+    mockHost, peerId := newTestHost()
+    validator := NewValidator(mockHost)
+
+    for _, code := range []uint64{multihash.IDENTITY, multihash.SHA2_256} {
+        privateKey := tryGeneratePrivateKey()
+        publicKey, _ := crypto.UnmarshalEd25519PublicKey(getPublicKeyFromPrivateKey(privateKey))
+        challengeRequestId := cid.NewCidV1(cid.Libp2pKey, getPublicKeyMultihash(publicKey, code)).Bytes()
+        message := createSignedMessage("CHALLENGEREQUEST", privateKey, map[string]interface{}{"challengeRequestId": challengeRequestId})
+        result := validateMessage(validator, peerId, "topic", message)
+        if (result != pubsub.ValidationAccept) {
+            t.Fatalf(`CIDv1 %v challenge request id validation result is "%v" instead of "%v"`, multihash.Codes[code], result, pubsub.ValidationAccept)
+        }
+    }
+}
@@ -0,0 +1,69 @@
+package pubsubPlebbitValidator
+
+import (
+    "time"
+    lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// the topics with seen challenge requests, the least recently used topic is forgotten
+var seenChallengeRequestTopicsSize = 1000
+var defaultSeenChallengeRequestsSize = 10000
+
+// in strict mode, the CHALLENGE and CHALLENGEVERIFICATION of the subplebbit owner are only forwarded
+// if the node saw the CHALLENGEREQUEST on the topic within the ttl, otherwise they are ignored, so the
+// owner can't flood the topic with fabricated challenge request ids. a node that joins the topic after
+// the challenge request ignores the responses of the challenge, they still reach the author through the
+// peers that saw it. size is the number of challenge requests remembered per topic, a flood of challenge
+// requests on a topic doesn't evict the challenge requests of the other topics, 0 is 10000
+func WithStrictChallengeResponses(ttl time.Duration, size int) ValidatorOption {
+    return func(validator *Validator) {
+        validator.seenChallengeRequestTtl = ttl
+        validator.seenChallengeRequestsSize = size
+        if (size <= 0) {
+            validator.seenChallengeRequestsSize = defaultSeenChallengeRequestsSize
+        }
+    }
+}
+
+// the seen challenge requests of the topic, created if add
+func getSeenChallengeRequests(topic string, add bool, validator Validator) (*lru.Cache[string, time.Time], bool) {
+    seenChallengeRequests, ok := validator.seenChallengeRequests.Get(topic)
+    if (ok || !add) {
+        return seenChallengeRequests, ok
+    }
+    seenChallengeRequests, _ = lru.New[string, time.Time](validator.seenChallengeRequestsSize)
+    // another validation may have added the topic first
+    previous, ok, _ := validator.seenChallengeRequests.PeekOrAdd(topic, seenChallengeRequests)
+    if (ok) {
+        return previous, true
+    }
+    return seenChallengeRequests, true
+}
+
+func addSeenChallengeRequest(fields pubsubMessageFields, validator Validator) {
+    if (validator.seenChallengeRequestTtl == 0 || fields.messageType != "CHALLENGEREQUEST") {
+        return
+    }
+    seenChallengeRequests, _ := getSeenChallengeRequests(fields.topic, true, validator)
+    seenChallengeRequests.Add(string(fields.challengeRequestId), validator.now())
+}
+
+// false if the owner response should be ignored in strict mode
+func validateChallengeResponse(fields pubsubMessageFields, validator Validator) bool {
+    if (validator.seenChallengeRequestTtl == 0 || (fields.messageType != "CHALLENGE" && fields.messageType != "CHALLENGEVERIFICATION")) {
+        return true
+    }
+    seenChallengeRequests, ok := getSeenChallengeRequests(fields.topic, false, validator)
+    if (!ok) {
+        return false
+    }
+    seen, ok := seenChallengeRequests.Get(string(fields.challengeRequestId))
+    if (!ok || validator.now().Sub(seen) > validator.seenChallengeRequestTtl) {
+        return false
+    }
+    // the verification ends the challenge, the owner can't verify it again
+    if (fields.messageType == "CHALLENGEVERIFICATION") {
+        seenChallengeRequests.Remove(string(fields.challengeRequestId))
+    }
+    return true
+}
@@ -0,0 +1,97 @@
+package pubsubPlebbitValidator
+
+import (
+    "testing"
+    "time"
+    pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+func TestStrictChallengeResponses(t *testing.T) {
+    mockHost, peerId := newTestHost()
+    topic := subplebbitPeerId.String()
+    authorPrivateKey := tryGeneratePrivateKey()
+    authorPeerId, _ := getPeerIdFromPrivateKey(authorPrivateKey)
+    challengeRequestId := []byte(authorPeerId)
+
+    // without strict mode the owner can send any challenge request id
+    validator := NewValidator(mockHost)
+    result := validateMessage(validator, peerId, topic, createSignedMessage("CHALLENGEVERIFICATION", subplebbitPrivateKey, map[string]interface{}{"challengeRequestId": wrongChallengeRequestId}))
+    if (result != pubsub.ValidationAccept) {
+        t.Fatalf(`not strict validation result is "%v" instead of "%v"`, result, pubsub.ValidationAccept)
+    }
+
+    validator = NewValidator(mockHost, WithStrictChallengeResponses(time.Minute, 0))
+    testClock := newTestClock()
+    validator.now = testClock.now
+    for _, messageType := range []string{"CHALLENGE", "CHALLENGEVERIFICATION"} {
+        result = validateMessage(validator, peerId, topic, createSignedMessage(messageType, subplebbitPrivateKey, map[string]interface{}{"challengeRequestId": challengeRequestId}))
+        if (result != pubsub.ValidationIgnore) {
+            t.Fatalf(`unseen %v validation result is "%v" instead of "%v"`, messageType, result, pubsub.ValidationIgnore)
+        }
+    }
+    // the ignored verification doesn't complete a challenge
+    if (getPeerStatistics(peerId, validator).completedChallengeCount != 0) {
+        t.Fatalf(`ignored verification completed challenge count is "%v" instead of "0"`, getPeerStatistics(peerId, validator).completedChallengeCount)
+    }
+
+    result = validateMessage(validator, peerId, topic, createSignedMessage("CHALLENGEREQUEST", authorPrivateKey, nil))
+    if (result != pubsub.ValidationAccept) {
+        t.Fatalf(`challenge request validation result is "%v" instead of "%v"`, result, pubsub.ValidationAccept)
+    }
+    // the challenge request was seen on another topic
+    result = validateMessage(validator, peerId, "other topic", createSignedMessage("CHALLENGE", subplebbitPrivateKey, map[string]interface{}{"challengeRequestId": challengeRequestId}))
+    if (result == pubsub.ValidationAccept) {
+        t.Fatalf(`challenge on another topic was accepted`)
+    }
+    result = validateMessage(validator, peerId, topic, createSignedMessage("CHALLENGE", subplebbitPrivateKey, map[string]interface{}{"challengeRequestId": challengeRequestId}))
+    if (result != pubsub.ValidationAccept) {
+        t.Fatalf(`seen challenge validation result is "%v" instead of "%v"`, result, pubsub.ValidationAccept)
+    }
+    result = validateMessage(validator, peerId, topic, createSignedMessage("CHALLENGEVERIFICATION", subplebbitPrivateKey, map[string]interface{}{"challengeRequestId": challengeRequestId}))
+    if (result != pubsub.ValidationAccept) {
+        t.Fatalf(`seen challenge verification validation result is "%v" instead of "%v"`, result, pubsub.ValidationAccept)
+    }
+    // the verification ends the challenge
+    result = validateMessage(validator, peerId, topic, createSignedMessage("CHALLENGEVERIFICATION", subplebbitPrivateKey, map[string]interface{}{"challengeRequestId": challengeRequestId}))
+    if (result != pubsub.ValidationIgnore) {
+        t.Fatalf(`second challenge verification validation result is "%v" instead of "%v"`, result, pubsub.ValidationIgnore)
+    }
+
+    // the challenge request expires after the ttl
+    validateMessage(validator, peerId, topic, createSignedMessage("CHALLENGEREQUEST", authorPrivateKey, nil))
+    testClock.advance(2 * time.Minute)
+    result = validateMessage(validator, peerId, topic, createSignedMessage("CHALLENGE", subplebbitPrivateKey, map[string]interface{}{"challengeRequestId": challengeRequestId}))
+    if (result != pubsub.ValidationIgnore) {
+        t.Fatalf(`expired challenge validation result is "%v" instead of "%v"`, result, pubsub.ValidationIgnore)
+    }
+}
+
+func TestStrictChallengeResponsesFlood(t *testing.T) {
+    mockHost, peerId := newTestHost()
+    topic := subplebbitPeerId.String()
+    validator := NewValidator(mockHost, WithStrictChallengeResponses(time.Minute, 10))
+    authorPrivateKey := tryGeneratePrivateKey()
+    authorPeerId, _ := getPeerIdFromPrivateKey(authorPrivateKey)
+
+    result := validateMessage(validator, peerId, topic, createSignedMessage("CHALLENGEREQUEST", authorPrivateKey, nil))
+    if (result != pubsub.ValidationAccept) {
+        t.Fatalf(`challenge request validation result is "%v" instead of "%v"`, result, pubsub.ValidationAccept)
+    }
+    // flood the cache with the challenge requests of another topic
+    for i := 0; i < 100; i++ {
+        validateMessage(validator, peerId, "flooded topic", createSignedMessage("CHALLENGEREQUEST", tryGeneratePrivateKey(), nil))
+    }
+    result = validateMessage(validator, peerId, topic, createSignedMessage("CHALLENGE", subplebbitPrivateKey, map[string]interface{}{"challengeRequestId": []byte(authorPeerId)}))
+    if (result != pubsub.ValidationAccept) {
+        t.Fatalf(`challenge after a flood on another topic validation result is "%v" instead of "%v"`, result, pubsub.ValidationAccept)
+    }
+
+    // a flood on the same topic over the size evicts the oldest challenge requests
+    for i := 0; i < 10; i++ {
+        validateMessage(validator, peerId, topic, createSignedMessage("CHALLENGEREQUEST", tryGeneratePrivateKey(), nil))
+    }
+    result = validateMessage(validator, peerId, topic, createSignedMessage("CHALLENGE", subplebbitPrivateKey, map[string]interface{}{"challengeRequestId": []byte(authorPeerId)}))
+    if (result != pubsub.ValidationIgnore) {
+        t.Fatalf(`challenge after a flood on its topic validation result is "%v" instead of "%v"`, result, pubsub.ValidationIgnore)
+    }
+}
@@ -0,0 +1,113 @@
+package pubsubPlebbitValidator
+
+import (
+    "context"
+    "errors"
+    "fmt"
+)
+
+// the decoded pubsub message fields used by the message checks
+type pubsubMessageFields struct {
+    topic string
+    // the size of the encoded message
+    size int
+    message map[string]interface{}
+    signature Signature
+    messageType string
+    challengeRequestId []byte
+}
+
+func decodePubsubMessage(topic string, data []byte) (pubsubMessageFields, error) {
+    message, err := cborDecode(data)
+    if (err != nil) {
+        return pubsubMessageFields{}, fmt.Errorf("failed cbor decode: %w", err)
+    }
+    signature, err := toSignature(message["signature"])
+    if (err != nil) {
+        return pubsubMessageFields{}, fmt.Errorf("invalid signature, %w", err)
+    }
+    messageType, ok := message["type"].(string)
+    if !ok {
+        return pubsubMessageFields{}, errors.New("invalid message type, failed convert message.type to string")
+    }
+    challengeRequestId, ok := message["challengeRequestId"].([]byte)
+    if !ok {
+        return pubsubMessageFields{}, errors.New("invalid challenge request id, failed convert message.challengeRequestId to []byte")
+    }
+    return pubsubMessageFields{topic, len(data), message, signature, messageType, challengeRequestId}, nil
+}
+
+// the stateless checks of a decoded message, in the order Validate runs them
+type messageCheck struct {
+    name string
+    check func(ctx context.Context, fields pubsubMessageFields, validator Validator) error
+}
+
+var messageChecks = []messageCheck{
+    {"type", func(ctx context.Context, fields pubsubMessageFields, validator Validator) error {
+        return validateType(fields.messageType)
+    }},
+    // validate the sizes before the signature, it's cheaper
+    {"limits", func(ctx context.Context, fields pubsubMessageFields, validator Validator) error {
+        return validateMessageLimits(fields, validator)
+    }},
+    // validate the encrypted envelopes are well formed
+    {"envelopes", func(ctx context.Context, fields pubsubMessageFields, validator Validator) error {
+        return validateEnvelopes(fields)
+    }},
+    {"protocolVersion", func(ctx context.Context, fields pubsubMessageFields, validator Validator) error {
+        return validateProtocolVersion(fields.message, validator)
+    }},
+    {"signature", func(ctx context.Context, fields pubsubMessageFields, validator Validator) error {
+        return validateSignature(fields.message, fields.signature)
+    }},
+    // validate the rules of the protocol version, e.g. the required signed properties
+    {"protocolRules", func(ctx context.Context, fields pubsubMessageFields, validator Validator) error {
+        return validateProtocolRules(fields)
+    }},
+    // validate the author is not blocked
+    {"author", func(ctx context.Context, fields pubsubMessageFields, validator Validator) error {
+        if (validator.accessList.IsPublicKeyBlocked(fields.signature.publicKey)) {
+            return errors.New("author public key is blocked by the access list")
+        }
+        return nil
+    }},
+    // validate challengeRequestId if from author
+    {"challengeRequestId", func(ctx context.Context, fields pubsubMessageFields, validator Validator) error {
+        return validateChallengeRequestId(fields.challengeRequestId, fields.signature, fields.messageType)
+    }},
+    // validate pubsub topic if from subplebbit owner
+    {"topic", func(ctx context.Context, fields pubsubMessageFields, validator Validator) error {
+        return validatePubsubTopic(ctx, fields.topic, fields.message, fields.signature, fields.messageType, validator)
+    }},
+    {"timestamp", func(ctx context.Context, fields pubsubMessageFields, validator Validator) error {
+        return validateTimestamp(fields.message, validator)
+    }},
+    // validate the proof of work last, an insufficient stamp is ignored but a forged or invalid message must be rejected
+    {"proofOfWork", func(ctx context.Context, fields pubsubMessageFields, validator Validator) error {
+        return validateProofOfWork(fields, validator)
+    }},
+}
+
+type CheckResult struct {
+    Name string
+    // nil if the check passed
+    Err error
+    // the check didn't run because the message couldn't be decoded
+    Skipped bool
+}
+
+// run the message checks without the peer checks and without updating the peers statistics,
+// to find which check rejects a message, the first result is the decoding of the message
+func (validator Validator) Check(topic string, data []byte) []CheckResult {
+    fields, err := decodePubsubMessage(topic, data)
+    checkResults := []CheckResult{{Name: "decode", Err: err}}
+    for _, messageCheck := range messageChecks {
+        if (err != nil) {
+            checkResults = append(checkResults, CheckResult{Name: messageCheck.name, Skipped: true})
+            continue
+        }
+        checkResults = append(checkResults, CheckResult{Name: messageCheck.name, Err: messageCheck.check(context.Background(), fields, validator)})
+    }
+    return checkResults
+}
@@ -0,0 +1,57 @@
+package pubsubPlebbitValidator
+
+import (
+    "testing"
+)
+
+// the names of the checks that failed and the names of the checks that were skipped
+func getFailedChecks(checkResults []CheckResult) ([]string, []string) {
+    failed, skipped := []string{}, []string{}
+    for _, checkResult := range checkResults {
+        if (checkResult.Skipped) {
+            skipped = append(skipped, checkResult.Name)
+        } else if (checkResult.Err != nil) {
+            failed = append(failed, checkResult.Name)
+        }
+    }
+    return failed, skipped
+}
+
+func TestCheck(t *testing.T) {
+    validator := NewValidator(newMockHost())
+    topic := subplebbitPeerId.String()
+    privateKey := tryGeneratePrivateKey()
+
+    // valid message
+    checkResults := validator.Check(topic, createSignedMessage("CHALLENGEREQUEST", privateKey, nil))
+    failed, skipped := getFailedChecks(checkResults)
+    if (len(checkResults) != len(messageChecks) + 1 || len(failed) != 0 || len(skipped) != 0) {
+        t.Fatalf(`valid message check results are "%+v"`, checkResults)
+    }
+
+    // only the failed check is reported, the other checks still run
+    message := createSignedMessage("CHALLENGEREQUEST", privateKey, map[string]interface{}{"challengeRequestId": wrongChallengeRequestId})
+    failed, skipped = getFailedChecks(validator.Check(topic, message))
+    if (len(failed) != 1 || failed[0] != "challengeRequestId" || len(skipped) != 0) {
+        t.Fatalf(`failed checks are "%v" instead of "[challengeRequestId]"`, failed)
+    }
+
+    // challenge verification on the wrong topic
+    message = createSignedMessage("CHALLENGEVERIFICATION", subplebbitPrivateKey, nil)
+    failed, _ = getFailedChecks(validator.Check("wrong topic", message))
+    if (len(failed) != 1 || failed[0] != "topic") {
+        t.Fatalf(`failed checks are "%v" instead of "[topic]"`, failed)
+    }
+
+    // a message that can't be decoded skips all the checks
+    checkResults = validator.Check(topic, []byte("invalid message"))
+    failed, skipped = getFailedChecks(checkResults)
+    if (len(failed) != 1 || failed[0] != "decode" || len(skipped) != len(messageChecks)) {
+        t.Fatalf(`invalid message check results are "%+v"`, checkResults)
+    }
+
+    // the checks don't update the peers statistics
+    if (validator.challenges.Len() != 0 || validator.peersStatistics.Len() != 0) {
+        t.Fatalf(`Check updated the peers statistics`)
+    }
+}
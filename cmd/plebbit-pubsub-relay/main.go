@@ -0,0 +1,95 @@
+// a dedicated gossipsub relay node running the plebbit validator
+//
+//    plebbit-pubsub-relay -identity relay.key -listen /ip4/0.0.0.0/tcp/4001 -bootstrap /dnsaddr/bootstrap.libp2p.io/p2p/QmNnoo... -topics 12D3KooW...,12D3KooW...
+package main
+
+import (
+    "context"
+    "flag"
+    "log"
+    "os"
+    "os/signal"
+    "strings"
+    "syscall"
+    "time"
+    plebbitValidator "github.com/plebbit/go-libp2p-pubsub-plebbit-validator"
+)
+
+// comma separated flag values
+func splitList(value string) []string {
+    list := []string{}
+    for _, element := range strings.Split(value, ",") {
+        element = strings.TrimSpace(element)
+        if (element != "") {
+            list = append(list, element)
+        }
+    }
+    return list
+}
+
+func main() {
+    config := relayConfig{}
+    var listen, bootstrap, topics, trusted, preset string
+    flag.StringVar(&config.identityPath, "identity", "plebbit-pubsub-relay.key", "libp2p private key file, created if it doesn't exist")
+    flag.StringVar(&listen, "listen", "/ip4/0.0.0.0/tcp/4001,/ip4/0.0.0.0/udp/4001/quic-v1", "comma separated listen multiaddrs")
+    flag.StringVar(&bootstrap, "bootstrap", "", "comma separated bootstrap peer multiaddrs with /p2p/ peer ids")
+    flag.StringVar(&topics, "topics", "", "comma separated topics to subscribe to, the subplebbit addresses")
+    flag.StringVar(&trusted, "trusted", "", "comma separated trusted peer ids, e.g. the bootstrap and subplebbit nodes")
+    flag.StringVar(&preset, "preset", "default", "peer score preset: conservative, default or aggressive")
+    flag.Float64Var(&config.expectedMessageRate, "expected-message-rate", 1, "expected messages per second on each topic, for the topic score params")
+    flag.StringVar(&config.accessListPath, "access-list", "", "access list json file, reloaded on SIGHUP")
+    flag.StringVar(&config.statePath, "state", "", "file to persist the peers statistics across restarts")
+    flag.DurationVar(&config.strictChallengeResponsesTtl, "strict-challenge-responses", 0, "only forward the challenges and challenge verifications of the challenge requests seen within this duration, e.g. 10m, disabled by default")
+    flag.IntVar(&config.strictChallengeResponsesSize, "strict-challenge-responses-size", 10000, "number of challenge requests remembered per topic for -strict-challenge-responses")
+    flag.IntVar(&config.proofOfWorkMaximumDifficulty, "proof-of-work", 0, "maximum difficulty of the challenge request proof of work stamps, the difficulty adapts to the load of each topic, e.g. 20, disabled by default")
+    flag.Float64Var(&config.loadSheddingRate, "load-shedding", 0, "challenge requests per second on a topic above which the requests of unknown and low priority publishers are ignored, e.g. 10, disabled by default")
+    flag.StringVar(&config.adminAddr, "admin", "", "listen address of the http admin api, e.g. 127.0.0.1:4002, disabled by default")
+    flag.Parse()
+    config.listenAddrs = splitList(listen)
+    config.bootstrapAddrs = splitList(bootstrap)
+    config.topics = splitList(topics)
+    config.trustedPeerIds = splitList(trusted)
+    config.preset = plebbitValidator.ScorePreset(preset)
+
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    relay, err := newRelay(ctx, config)
+    if (err != nil) {
+        log.Fatalln(err)
+    }
+    log.Println("peer id", relay.host.ID())
+    for _, address := range relay.host.Addrs() {
+        log.Printf("listening on %v/p2p/%v", address, relay.host.ID())
+    }
+    relay.connectBootstrapPeers(ctx)
+
+    // reload the access list without restarting
+    reload := make(chan os.Signal, 1)
+    signal.Notify(reload, syscall.SIGHUP)
+    for {
+        select {
+        case <-reload:
+            err = relay.reloadAccessList()
+            if (err != nil) {
+                log.Println("failed reloading access list", err)
+            } else {
+                log.Println("reloaded access list")
+            }
+        case <-ctx.Done():
+            log.Println("shutting down")
+            // don't hang forever if closing the connections is stuck
+            closed := make(chan struct{})
+            go func() {
+                relay.close()
+                close(closed)
+            }()
+            select {
+            case <-closed:
+            case <-time.After(10 * time.Second):
+                log.Println("shutdown timed out")
+            }
+            return
+        }
+    }
+}
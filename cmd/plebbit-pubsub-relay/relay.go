@@ -0,0 +1,256 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "log"
+    "net"
+    "net/http"
+    "os"
+    "time"
+    libp2p "github.com/libp2p/go-libp2p"
+    pubsub "github.com/libp2p/go-libp2p-pubsub"
+    crypto "github.com/libp2p/go-libp2p/core/crypto"
+    host "github.com/libp2p/go-libp2p/core/host"
+    peer "github.com/libp2p/go-libp2p/core/peer"
+    multiaddr "github.com/multiformats/go-multiaddr"
+    plebbitValidator "github.com/plebbit/go-libp2p-pubsub-plebbit-validator"
+)
+
+type relayConfig struct {
+    identityPath string
+    listenAddrs []string
+    bootstrapAddrs []string
+    topics []string
+    trustedPeerIds []string
+    preset plebbitValidator.ScorePreset
+    expectedMessageRate float64
+    accessListPath string
+    statePath string
+    adminAddr string
+    strictChallengeResponsesTtl time.Duration
+    strictChallengeResponsesSize int
+    // 0 doesn't require proof of work stamps
+    proofOfWorkMaximumDifficulty int
+    // 0 doesn't shed the challenge requests under load
+    loadSheddingRate float64
+}
+
+type relay struct {
+    config relayConfig
+    host host.Host
+    validator plebbitValidator.Validator
+    pubsub *pubsub.PubSub
+    topics []*pubsub.Topic
+    subscriptions []*pubsub.Subscription
+    adminServer *http.Server
+    adminListener net.Listener
+}
+
+// the libp2p identity, generated and saved if the file doesn't exist, so the peer id is stable across restarts
+func loadIdentity(path string) (crypto.PrivKey, error) {
+    file, err := os.ReadFile(path)
+    if (err == nil) {
+        return crypto.UnmarshalPrivateKey(file)
+    }
+    if (!errors.Is(err, os.ErrNotExist)) {
+        return nil, err
+    }
+    privateKey, _, err := crypto.GenerateEd25519Key(nil)
+    if (err != nil) {
+        return nil, err
+    }
+    file, err = crypto.MarshalPrivateKey(privateKey)
+    if (err != nil) {
+        return nil, err
+    }
+    err = os.WriteFile(path, file, 0600)
+    if (err != nil) {
+        return nil, err
+    }
+    return privateKey, nil
+}
+
+func newRelay(ctx context.Context, config relayConfig) (*relay, error) {
+    identity, err := loadIdentity(config.identityPath)
+    if (err != nil) {
+        return nil, err
+    }
+    validatorOptions := []plebbitValidator.ValidatorOption{}
+    if (config.accessListPath != "") {
+        accessList, err := plebbitValidator.LoadAccessListFile(config.accessListPath)
+        if (err != nil) {
+            return nil, err
+        }
+        validatorOptions = append(validatorOptions, plebbitValidator.WithAccessList(accessList))
+    }
+    if (config.statePath != "") {
+        validatorOptions = append(validatorOptions, plebbitValidator.WithStateStore(plebbitValidator.NewFileStateStore(config.statePath)))
+    }
+    if (config.strictChallengeResponsesTtl != 0) {
+        validatorOptions = append(validatorOptions, plebbitValidator.WithStrictChallengeResponses(config.strictChallengeResponsesTtl, config.strictChallengeResponsesSize))
+    }
+    if (config.proofOfWorkMaximumDifficulty != 0) {
+        proofOfWorkConfig := plebbitValidator.DefaultProofOfWorkConfig
+        proofOfWorkConfig.MaximumDifficulty = config.proofOfWorkMaximumDifficulty
+        validatorOptions = append(validatorOptions, plebbitValidator.WithProofOfWork(plebbitValidator.NewProofOfWork(proofOfWorkConfig)))
+    }
+    if (config.loadSheddingRate != 0) {
+        loadSheddingConfig := plebbitValidator.DefaultLoadSheddingConfig
+        loadSheddingConfig.TargetRate = config.loadSheddingRate
+        validatorOptions = append(validatorOptions, plebbitValidator.WithLoadShedding(plebbitValidator.NewLoadShedding(loadSheddingConfig)))
+    }
+    trustedPeerIds := []peer.ID{}
+    for _, trustedPeerId := range config.trustedPeerIds {
+        peerId, err := peer.Decode(trustedPeerId)
+        if (err != nil) {
+            return nil, err
+        }
+        trustedPeerIds = append(trustedPeerIds, peerId)
+    }
+    validatorOptions = append(validatorOptions, plebbitValidator.WithTrustedPeers(trustedPeerIds...))
+    scoreConfig, err := plebbitValidator.NewScoreConfig(config.preset)
+    if (err != nil) {
+        return nil, err
+    }
+
+    // create libp2p
+    host, err := libp2p.New(libp2p.Identity(identity), libp2p.ListenAddrStrings(config.listenAddrs...))
+    if (err != nil) {
+        return nil, err
+    }
+    relay := &relay{config: config, host: host}
+    relay.validator = plebbitValidator.NewValidator(host, validatorOptions...)
+    if (relay.validator.RestoreStateError() != nil) {
+        log.Println("failed restoring state, starting with empty statistics", relay.validator.RestoreStateError())
+    }
+
+    // create pubsub with plebbit validator and peer scoring
+    peerScoreOption, err := scoreConfig.PubsubOption(relay.validator)
+    if (err != nil) {
+        host.Close()
+        return nil, err
+    }
+    pubsubOptions := []pubsub.Option{
+        pubsub.WithDefaultValidator(relay.validator.Validate),
+        peerScoreOption,
+        pubsub.WithMessageIdFn(plebbitValidator.MessageIdFn),
+    }
+    // the admin api shows the gossipsub scores
+    scoreInspector := plebbitValidator.NewScoreInspector(relay.validator)
+    if (config.adminAddr != "") {
+        pubsubOptions = append(pubsubOptions, scoreInspector.PubsubOption(10 * time.Second))
+    }
+    relay.pubsub, err = pubsub.NewGossipSub(ctx, host, pubsubOptions...)
+    if (err != nil) {
+        host.Close()
+        return nil, err
+    }
+
+    // the relay only forwards, the subscriptions are drained so they don't fill up
+    for _, topicString := range config.topics {
+        topic, err := plebbitValidator.JoinTopic(relay.pubsub, topicString, scoreConfig.TopicScoreParams(config.expectedMessageRate))
+        if (err != nil) {
+            relay.close()
+            return nil, err
+        }
+        relay.topics = append(relay.topics, topic)
+        subscription, err := topic.Subscribe()
+        if (err != nil) {
+            relay.close()
+            return nil, err
+        }
+        relay.subscriptions = append(relay.subscriptions, subscription)
+        go func() {
+            for {
+                _, err := subscription.Next(ctx)
+                if (err != nil) {
+                    return
+                }
+            }
+        }()
+    }
+
+    if (config.adminAddr != "") {
+        err = relay.serveAdmin(plebbitValidator.NewAdminHandler(relay.validator, plebbitValidator.WithAdminScoreInspector(scoreInspector), plebbitValidator.WithAdminScoreConfig(scoreConfig)))
+        if (err != nil) {
+            relay.close()
+            return nil, err
+        }
+    }
+
+    if (config.statePath != "") {
+        relay.validator.PersistStatePeriodically(ctx, time.Minute, func(err error) {
+            log.Println("failed saving state", err)
+        })
+    }
+    return relay, nil
+}
+
+func (relay *relay) connectBootstrapPeers(ctx context.Context) {
+    for _, bootstrapAddr := range relay.config.bootstrapAddrs {
+        address, err := multiaddr.NewMultiaddr(bootstrapAddr)
+        if (err != nil) {
+            log.Println("invalid bootstrap address", bootstrapAddr, err)
+            continue
+        }
+        addrInfo, err := peer.AddrInfoFromP2pAddr(address)
+        if (err != nil) {
+            log.Println("invalid bootstrap address", bootstrapAddr, err)
+            continue
+        }
+        connectCtx, cancel := context.WithTimeout(ctx, 30 * time.Second)
+        err = relay.host.Connect(connectCtx, *addrInfo)
+        cancel()
+        if (err != nil) {
+            log.Println("failed connecting to bootstrap peer", addrInfo.ID, err)
+            continue
+        }
+        log.Println("connected to bootstrap peer", addrInfo.ID)
+    }
+}
+
+// listen before returning so an address already in use fails the startup
+func (relay *relay) serveAdmin(adminHandler http.Handler) error {
+    listener, err := net.Listen("tcp", relay.config.adminAddr)
+    if (err != nil) {
+        return err
+    }
+    relay.adminListener = listener
+    relay.adminServer = &http.Server{Handler: adminHandler, ReadHeaderTimeout: 10 * time.Second}
+    go func() {
+        err := relay.adminServer.Serve(listener)
+        if (err != nil && err != http.ErrServerClosed) {
+            log.Println("admin api stopped", err)
+        }
+    }()
+    log.Println("admin api listening on", listener.Addr())
+    return nil
+}
+
+func (relay *relay) reloadAccessList() error {
+    if (relay.config.accessListPath == "") {
+        return errors.New("no access list file")
+    }
+    return relay.validator.AccessList().LoadFile(relay.config.accessListPath)
+}
+
+// stop the admin api, leave the topics, save the state and close the host
+func (relay *relay) close() {
+    if (relay.adminServer != nil) {
+        relay.adminServer.Close()
+    }
+    for _, subscription := range relay.subscriptions {
+        subscription.Cancel()
+    }
+    for _, topic := range relay.topics {
+        topic.Close()
+    }
+    if (relay.config.statePath != "") {
+        err := relay.validator.SaveState()
+        if (err != nil) {
+            log.Println("failed saving state", err)
+        }
+    }
+    relay.host.Close()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+    "testing"
+    "context"
+    "encoding/json"
+    "net/http"
+    "os"
+    "path/filepath"
+    "time"
+    plebbitValidator "github.com/plebbit/go-libp2p-pubsub-plebbit-validator"
+)
+
+func newTestRelayConfig(t *testing.T, name string) relayConfig {
+    directory := t.TempDir()
+    return relayConfig{
+        identityPath: filepath.Join(directory, name + ".key"),
+        listenAddrs: []string{"/ip4/127.0.0.1/tcp/0"},
+        topics: []string{"12D3KooWG3XbzoVyAE6Y9vHZKF64Yuuu4TjdgQKedk14iYmTEPWu"},
+        preset: plebbitValidator.ScorePresetDefault,
+        expectedMessageRate: 1,
+        statePath: filepath.Join(directory, name + "-state.json"),
+    }
+}
+
+func TestRelay(t *testing.T) {
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+
+    bootstrapConfig := newTestRelayConfig(t, "bootstrap")
+    bootstrapRelay, err := newRelay(ctx, bootstrapConfig)
+    if (err != nil) {
+        t.Fatalf(`newRelay error is "%v" instead of "<nil>"`, err)
+    }
+    defer bootstrapRelay.close()
+
+    config := newTestRelayConfig(t, "relay")
+    config.bootstrapAddrs = []string{bootstrapRelay.host.Addrs()[0].String() + "/p2p/" + bootstrapRelay.host.ID().String()}
+    relay, err := newRelay(ctx, config)
+    if (err != nil) {
+        t.Fatalf(`newRelay error is "%v" instead of "<nil>"`, err)
+    }
+    relay.connectBootstrapPeers(ctx)
+
+    // the relays find each other on the topic
+    deadline := time.Now().Add(10 * time.Second)
+    for len(relay.topics[0].ListPeers()) == 0 && time.Now().Before(deadline) {
+        time.Sleep(100 * time.Millisecond)
+    }
+    topicPeers := relay.topics[0].ListPeers()
+    if (len(topicPeers) != 1 || topicPeers[0] != bootstrapRelay.host.ID()) {
+        t.Fatalf(`topic peers are "%v" instead of "[%v]"`, topicPeers, bootstrapRelay.host.ID())
+    }
+
+    // the state is saved on close and the identity is reused on restart
+    peerId := relay.host.ID()
+    relay.close()
+    _, err = os.Stat(config.statePath)
+    if (err != nil) {
+        t.Fatalf(`state file error is "%v" instead of "<nil>"`, err)
+    }
+    relay, err = newRelay(ctx, config)
+    if (err != nil) {
+        t.Fatalf(`newRelay error is "%v" instead of "<nil>"`, err)
+    }
+    defer relay.close()
+    if (relay.host.ID() != peerId) {
+        t.Fatalf(`restarted relay peer id is "%v" instead of "%v"`, relay.host.ID(), peerId)
+    }
+    if (relay.reloadAccessList() == nil) {
+        t.Fatalf(`reloadAccessList without access list file error is "<nil>"`)
+    }
+}
+
+func TestRelayAdmin(t *testing.T) {
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+
+    config := newTestRelayConfig(t, "relay")
+    config.adminAddr = "127.0.0.1:0"
+    relay, err := newRelay(ctx, config)
+    if (err != nil) {
+        t.Fatalf(`newRelay error is "%v" instead of "<nil>"`, err)
+    }
+    response, err := http.Get("http://" + relay.adminListener.Addr().String() + "/config")
+    if (err != nil) {
+        t.Fatalf(`GET /config error is "%v" instead of "<nil>"`, err)
+    }
+    adminConfig := plebbitValidator.AdminConfig{}
+    err = json.NewDecoder(response.Body).Decode(&adminConfig)
+    response.Body.Close()
+    if (err != nil || adminConfig.ScoreConfig == nil) {
+        t.Fatalf(`GET /config is "%+v" with error "%v"`, adminConfig, err)
+    }
+
+    // the admin api is stopped on close
+    relay.close()
+    _, err = http.Get("http://" + relay.adminListener.Addr().String() + "/config")
+    if (err == nil) {
+        t.Fatalf(`GET /config after close error is "<nil>"`)
+    }
+}
+
+func TestSplitList(t *testing.T) {
+    list := splitList(" a, b,,c ")
+    if (len(list) != 3 || list[0] != "a" || list[1] != "b" || list[2] != "c") {
+        t.Fatalf(`list is "%v" instead of "[a b c]"`, list)
+    }
+}
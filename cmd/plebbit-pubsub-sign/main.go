@@ -0,0 +1,428 @@
+// generate keys and sign plebbit pubsub messages, to create test fixtures without writing go
+//
+//    plebbit-pubsub-sign keygen -out author.key
+//    plebbit-pubsub-sign info -key author.key
+//    plebbit-pubsub-sign sign -key author.key -type CHALLENGEREQUEST -template challenge-request.json -out challenge-request.cbor
+//    plebbit-pubsub-sign sign -key subplebbit.key -type all -challenge-request-id 12D3KooW... -out fixtures
+//    plebbit-pubsub-sign delegate -key subplebbit.key -topic memes.eth -public-key <base64 hot public key> -expiry 720h -out delegation.cbor
+//    plebbit-pubsub-sign sign -key hot.key -type CHALLENGE -delegation delegation.cbor -out challenge.cbor
+package main
+
+import (
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "time"
+    peer "github.com/libp2p/go-libp2p/core/peer"
+    plebbitValidator "github.com/plebbit/go-libp2p-pubsub-plebbit-validator"
+)
+
+var messageTypes = []string{"CHALLENGEREQUEST", "CHALLENGE", "CHALLENGEANSWER", "CHALLENGEVERIFICATION"}
+
+// the template string values with this prefix are bytes
+const bytesPrefix = "base64:"
+
+// the default templates, the type, timestamp and challengeRequestId are added when signing,
+// the envelopes are well formed but not encrypted
+var defaultTemplates = map[string]map[string]interface{}{
+    "CHALLENGEREQUEST": {
+        "acceptedChallengeTypes": []interface{}{"image/png"},
+        "encryptedPublication": createEnvelope([]byte("publication")),
+    },
+    "CHALLENGE": {
+        "encryptedChallenges": createEnvelope([]byte("challenges")),
+    },
+    "CHALLENGEANSWER": {
+        "encryptedChallengeAnswers": createEnvelope([]byte("challenge answers")),
+    },
+    "CHALLENGEVERIFICATION": {
+        "challengeSuccess": true,
+        "encryptedPublication": createEnvelope([]byte("publication")),
+    },
+}
+
+// a structurally valid envelope, the ciphertext is not encrypted and the iv and tag are zeros
+func createEnvelope(ciphertext []byte) map[string]interface{} {
+    return map[string]interface{}{"ciphertext": ciphertext, "iv": make([]byte, 12), "tag": make([]byte, 16), "type": "ed25519-aes-gcm"}
+}
+
+func main() {
+    if (len(os.Args) < 2) {
+        usage()
+        os.Exit(2)
+    }
+    var err error
+    switch (os.Args[1]) {
+    case "keygen":
+        err = keygen(os.Args[2:])
+    case "info":
+        err = info(os.Args[2:])
+    case "sign":
+        err = sign(os.Args[2:])
+    case "delegate":
+        err = delegate(os.Args[2:])
+    default:
+        usage()
+        os.Exit(2)
+    }
+    if (err != nil) {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+    }
+}
+
+func usage() {
+    fmt.Fprintln(os.Stderr, "usage: plebbit-pubsub-sign keygen|info|sign|delegate [flags]")
+    fmt.Fprintln(os.Stderr, "  keygen  generate an ed25519 private key and print its peer id, subplebbit address and challengeRequestId")
+    fmt.Fprintln(os.Stderr, "  info    print the peer id, subplebbit address and challengeRequestId of a private key")
+    fmt.Fprintln(os.Stderr, "  sign    sign a cbor message from a json template, string values prefixed with \"" + bytesPrefix + "\" are bytes")
+    fmt.Fprintln(os.Stderr, "  delegate  sign a cbor delegation certificate authorizing another key to sign for the subplebbit")
+}
+
+func keygen(arguments []string) error {
+    flags := flag.NewFlagSet("keygen", flag.ExitOnError)
+    out := flags.String("out", "", "write the base64 private key to this file")
+    flags.Parse(arguments)
+
+    privateKey, err := plebbitValidator.GeneratePrivateKey()
+    if (err != nil) {
+        return err
+    }
+    if (*out != "") {
+        err = os.WriteFile(*out, []byte(base64.StdEncoding.EncodeToString(privateKey) + "\n"), 0600)
+        if (err != nil) {
+            return err
+        }
+    }
+    return printKeyInfo(os.Stdout, privateKey)
+}
+
+func info(arguments []string) error {
+    flags := flag.NewFlagSet("info", flag.ExitOnError)
+    keyPath := flags.String("key", "", "base64 private key file")
+    flags.Parse(arguments)
+
+    privateKey, err := readPrivateKey(*keyPath)
+    if (err != nil) {
+        return err
+    }
+    return printKeyInfo(os.Stdout, privateKey)
+}
+
+func printKeyInfo(output io.Writer, privateKey []byte) error {
+    peerId, err := plebbitValidator.GetPeerIdFromPrivateKey(privateKey)
+    if (err != nil) {
+        return err
+    }
+    fmt.Fprintf(output, "privateKey          %v\n", base64.StdEncoding.EncodeToString(privateKey))
+    fmt.Fprintf(output, "publicKey           %v\n", base64.StdEncoding.EncodeToString(plebbitValidator.GetPublicKeyFromPrivateKey(privateKey)))
+    fmt.Fprintf(output, "peerId              %v\n", peerId)
+    // the subplebbit address and the challengeRequestId of an author are the peer id of the key
+    fmt.Fprintf(output, "subplebbitAddress   %v\n", peerId)
+    fmt.Fprintf(output, "challengeRequestId  %v%v\n", bytesPrefix, base64.StdEncoding.EncodeToString([]byte(peerId)))
+    return nil
+}
+
+func readPrivateKey(path string) ([]byte, error) {
+    if (path == "") {
+        return nil, errors.New("missing -key private key file")
+    }
+    file, err := os.ReadFile(path)
+    if (err != nil) {
+        return nil, err
+    }
+    privateKey, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(file)))
+    if (err != nil) {
+        return nil, fmt.Errorf("invalid private key file, failed base64 decode: %w", err)
+    }
+    if (len(privateKey) != 32) {
+        return nil, fmt.Errorf("invalid private key file, private key is %v bytes instead of 32", len(privateKey))
+    }
+    return privateKey, nil
+}
+
+func sign(arguments []string) error {
+    flags := flag.NewFlagSet("sign", flag.ExitOnError)
+    keyPath := flags.String("key", "", "base64 private key file, the author key or the subplebbit key")
+    messageType := flags.String("type", "", "message type: " + strings.Join(messageTypes, ", ") + " or all, defaults to the template type")
+    templatePath := flags.String("template", "", "json template of the message, defaults to a minimal message of the type")
+    challengeRequestId := flags.String("challenge-request-id", "", "peer id or " + bytesPrefix + " challengeRequestId, defaults to the template or the key peer id")
+    encoding := flags.String("encoding", "raw", "encoding of the output: raw, hex or base64")
+    out := flags.String("out", "", "output file, or output directory with -type all, defaults to stdout")
+    delegationPath := flags.String("delegation", "", "cbor delegation certificate file to add to the messages, when -key is a delegated key")
+    proofOfWorkDifficulty := flags.Int("proof-of-work", 0, "difficulty in leading zero bits of the proof of work stamp of the challenge requests, 0 doesn't stamp")
+    flags.Parse(arguments)
+
+    privateKey, err := readPrivateKey(*keyPath)
+    if (err != nil) {
+        return err
+    }
+    var delegation map[string]interface{}
+    if (*delegationPath != "") {
+        file, err := os.ReadFile(*delegationPath)
+        if (err != nil) {
+            return err
+        }
+        delegation, err = plebbitValidator.DecodeMessage(file)
+        if (err != nil) {
+            return fmt.Errorf("invalid delegation %v: %w", *delegationPath, err)
+        }
+    }
+    var template map[string]interface{}
+    if (*templatePath != "") {
+        template, err = readTemplate(*templatePath)
+        if (err != nil) {
+            return err
+        }
+    }
+
+    types := []string{*messageType}
+    if (*messageType == "all") {
+        types = messageTypes
+        if (*out == "") {
+            return errors.New("missing -out directory with -type all")
+        }
+        err = os.MkdirAll(*out, 0755)
+        if (err != nil) {
+            return err
+        }
+    }
+    for _, messageType := range types {
+        encoded, err := createMessage(template, messageType, *challengeRequestId, delegation, *proofOfWorkDifficulty, privateKey)
+        if (err != nil) {
+            return err
+        }
+        output, err := encodeOutput(encoded, *encoding)
+        if (err != nil) {
+            return err
+        }
+        path := *out
+        if (len(types) > 1) {
+            path = filepath.Join(*out, messageType + "." + map[string]string{"raw": "cbor", "hex": "hex", "base64": "base64"}[*encoding])
+        }
+        if (path == "") {
+            _, err = os.Stdout.Write(output)
+        } else {
+            err = os.WriteFile(path, output, 0644)
+        }
+        if (err != nil) {
+            return err
+        }
+    }
+    return nil
+}
+
+func readTemplate(path string) (map[string]interface{}, error) {
+    file, err := os.ReadFile(path)
+    if (err != nil) {
+        return nil, err
+    }
+    decoder := json.NewDecoder(strings.NewReader(string(file)))
+    // keep the integers as integers, cbor encodes them differently than floats
+    decoder.UseNumber()
+    template := map[string]interface{}{}
+    err = decoder.Decode(&template)
+    if (err != nil) {
+        return nil, fmt.Errorf("invalid template %v: %w", path, err)
+    }
+    return template, nil
+}
+
+// the signed cbor message of the template, the properties signed are the template
+// signedPropertyNames, or all the properties
+func createMessage(template map[string]interface{}, messageType string, challengeRequestId string, delegation map[string]interface{}, proofOfWorkDifficulty int, privateKey []byte) ([]byte, error) {
+    if (template == nil) {
+        template = defaultTemplates[messageType]
+    }
+    converted, err := fromJson(template)
+    if (err != nil) {
+        return nil, err
+    }
+    message := converted.(map[string]interface{})
+    delete(message, "signature")
+
+    if (messageType != "") {
+        message["type"] = messageType
+    }
+    validType := false
+    for _, knownType := range messageTypes {
+        validType = validType || message["type"] == knownType
+    }
+    if (!validType) {
+        return nil, fmt.Errorf("invalid message type %v, must be one of %v", message["type"], strings.Join(messageTypes, ", "))
+    }
+    if (message["timestamp"] == nil) {
+        message["timestamp"] = time.Now().Unix()
+    }
+    if (message["protocolVersion"] == nil) {
+        message["protocolVersion"] = "1.0.0"
+    }
+    if (message["userAgent"] == nil) {
+        message["userAgent"] = "/plebbit-pubsub-sign/0.0.1"
+    }
+    if (challengeRequestId != "") {
+        message["challengeRequestId"], err = parseChallengeRequestId(challengeRequestId)
+        if (err != nil) {
+            return nil, err
+        }
+    }
+    if (delegation != nil) {
+        message["delegation"] = delegation
+    }
+    if (message["challengeRequestId"] == nil) {
+        peerId, err := plebbitValidator.GetPeerIdFromPrivateKey(privateKey)
+        if (err != nil) {
+            return nil, err
+        }
+        message["challengeRequestId"] = []byte(peerId)
+    }
+
+    signedPropertyNames := []string{}
+    if (message["signedPropertyNames"] != nil) {
+        names, ok := message["signedPropertyNames"].([]interface{})
+        if (!ok) {
+            return nil, errors.New("invalid template signedPropertyNames, must be an array of strings")
+        }
+        for _, name := range names {
+            nameString, ok := name.(string)
+            if (!ok) {
+                return nil, errors.New("invalid template signedPropertyNames, must be an array of strings")
+            }
+            signedPropertyNames = append(signedPropertyNames, nameString)
+        }
+        delete(message, "signedPropertyNames")
+    } else {
+        for name := range message {
+            signedPropertyNames = append(signedPropertyNames, name)
+        }
+        sort.Strings(signedPropertyNames)
+    }
+    plebbitValidator.SignMessage(message, privateKey, signedPropertyNames)
+    // the stamp is over the signed bytes, it's added after signing
+    if (proofOfWorkDifficulty > 0 && message["type"] == "CHALLENGEREQUEST") {
+        err = plebbitValidator.StampMessage(message, proofOfWorkDifficulty)
+        if (err != nil) {
+            return nil, err
+        }
+    }
+    return plebbitValidator.EncodeMessage(message), nil
+}
+
+func delegate(arguments []string) error {
+    flags := flag.NewFlagSet("delegate", flag.ExitOnError)
+    keyPath := flags.String("key", "", "base64 private key file of the subplebbit owner")
+    publicKey := flags.String("public-key", "", "base64 ed25519 public key to delegate to, e.g. the publicKey printed by info")
+    topic := flags.String("topic", "", "pubsub topic of the delegation, the subplebbit address, defaults to the peer id of -key")
+    expiry := flags.Duration("expiry", 30 * 24 * time.Hour, "duration until the delegation expires")
+    encoding := flags.String("encoding", "raw", "encoding of the output: raw, hex or base64")
+    out := flags.String("out", "", "output file, defaults to stdout")
+    flags.Parse(arguments)
+
+    privateKey, err := readPrivateKey(*keyPath)
+    if (err != nil) {
+        return err
+    }
+    encoded, err := createDelegation(privateKey, *topic, *publicKey, time.Now().Add(*expiry))
+    if (err != nil) {
+        return err
+    }
+    output, err := encodeOutput(encoded, *encoding)
+    if (err != nil) {
+        return err
+    }
+    if (*out == "") {
+        _, err = os.Stdout.Write(output)
+        return err
+    }
+    return os.WriteFile(*out, output, 0644)
+}
+
+func createDelegation(ownerPrivateKey []byte, topic string, publicKey string, expiry time.Time) ([]byte, error) {
+    delegatedPublicKey, err := base64.StdEncoding.DecodeString(publicKey)
+    if (err != nil) {
+        return nil, fmt.Errorf("invalid -public-key, failed base64 decode: %w", err)
+    }
+    if (len(delegatedPublicKey) != 32) {
+        return nil, fmt.Errorf("invalid -public-key, public key is %v bytes instead of 32", len(delegatedPublicKey))
+    }
+    if (topic == "") {
+        ownerPeerId, err := plebbitValidator.GetPeerIdFromPrivateKey(ownerPrivateKey)
+        if (err != nil) {
+            return nil, err
+        }
+        topic = ownerPeerId.String()
+    }
+    return plebbitValidator.EncodeMessage(plebbitValidator.CreateDelegation(ownerPrivateKey, topic, delegatedPublicKey, expiry)), nil
+}
+
+func parseChallengeRequestId(challengeRequestId string) ([]byte, error) {
+    if (strings.HasPrefix(challengeRequestId, bytesPrefix)) {
+        return base64.StdEncoding.DecodeString(strings.TrimPrefix(challengeRequestId, bytesPrefix))
+    }
+    peerId, err := peer.Decode(challengeRequestId)
+    if (err != nil) {
+        return nil, fmt.Errorf("invalid challenge request id, failed peer.Decode: %w", err)
+    }
+    return []byte(peerId), nil
+}
+
+// convert the json template values to the cbor message values
+func fromJson(value interface{}) (interface{}, error) {
+    switch value := value.(type) {
+    case map[string]interface{}:
+        converted := map[string]interface{}{}
+        for key, element := range value {
+            convertedElement, err := fromJson(element)
+            if (err != nil) {
+                return nil, err
+            }
+            converted[key] = convertedElement
+        }
+        return converted, nil
+    case []interface{}:
+        converted := make([]interface{}, len(value))
+        for i, element := range value {
+            convertedElement, err := fromJson(element)
+            if (err != nil) {
+                return nil, err
+            }
+            converted[i] = convertedElement
+        }
+        return converted, nil
+    case json.Number:
+        integer, err := value.Int64()
+        if (err == nil) {
+            return integer, nil
+        }
+        return value.Float64()
+    case string:
+        if (strings.HasPrefix(value, bytesPrefix)) {
+            bytes, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, bytesPrefix))
+            if (err != nil) {
+                return nil, fmt.Errorf("invalid %v value %v: %w", bytesPrefix, value, err)
+            }
+            return bytes, nil
+        }
+    }
+    return value, nil
+}
+
+func encodeOutput(encoded []byte, encoding string) ([]byte, error) {
+    switch (encoding) {
+    case "raw":
+        return encoded, nil
+    case "hex":
+        return []byte(hex.EncodeToString(encoded) + "\n"), nil
+    case "base64":
+        return []byte(base64.StdEncoding.EncodeToString(encoded) + "\n"), nil
+    }
+    return nil, errors.New("unknown encoding " + encoding)
+}
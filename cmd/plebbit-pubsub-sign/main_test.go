@@ -0,0 +1,169 @@
+package main
+
+import (
+    "testing"
+    "bytes"
+    "encoding/base64"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+    plebbitValidator "github.com/plebbit/go-libp2p-pubsub-plebbit-validator"
+)
+
+// the names of the checks that didn't pass
+func getFailedChecks(checkResults []plebbitValidator.CheckResult) []string {
+    failed := []string{}
+    for _, checkResult := range checkResults {
+        if (checkResult.Skipped || checkResult.Err != nil) {
+            failed = append(failed, checkResult.Name)
+        }
+    }
+    return failed
+}
+
+func TestCreateMessage(t *testing.T) {
+    authorPrivateKey, _ := plebbitValidator.GeneratePrivateKey()
+    subplebbitPrivateKey, _ := plebbitValidator.GeneratePrivateKey()
+    authorPeerId, _ := plebbitValidator.GetPeerIdFromPrivateKey(authorPrivateKey)
+    subplebbitPeerId, _ := plebbitValidator.GetPeerIdFromPrivateKey(subplebbitPrivateKey)
+    validator := plebbitValidator.NewValidator(nil)
+
+    // the author types signed by the author and the subplebbit types signed by the subplebbit pass all the checks
+    for _, messageType := range messageTypes {
+        privateKey := authorPrivateKey
+        if (messageType == "CHALLENGE" || messageType == "CHALLENGEVERIFICATION") {
+            privateKey = subplebbitPrivateKey
+        }
+        encoded, err := createMessage(nil, messageType, authorPeerId.String(), nil, 0, privateKey)
+        if (err != nil) {
+            t.Fatalf(`createMessage "%v" error is "%v" instead of "<nil>"`, messageType, err)
+        }
+        failed := getFailedChecks(validator.Check(subplebbitPeerId.String(), encoded))
+        if (len(failed) != 0) {
+            t.Fatalf(`"%v" failed checks are "%v" instead of "[]"`, messageType, failed)
+        }
+        message, _ := plebbitValidator.DecodeMessage(encoded)
+        if (!bytes.Equal(message["challengeRequestId"].([]byte), []byte(authorPeerId))) {
+            t.Fatalf(`"%v" challengeRequestId is "%v" instead of "%v"`, messageType, message["challengeRequestId"], authorPeerId)
+        }
+    }
+
+    // template with bytes, integers and signedPropertyNames
+    templatePath := filepath.Join(t.TempDir(), "template.json")
+    os.WriteFile(templatePath, []byte(`{
+        "type": "CHALLENGEREQUEST",
+        "timestamp": 1,
+        "encryptedPublication": {"ciphertext": "base64:AAEC", "iv": "base64:AAAAAAAAAAAAAAAA", "tag": "base64:AAAAAAAAAAAAAAAAAAAAAA==", "type": "ed25519-aes-gcm"},
+        "acceptedChallengeTypes": ["image/png"],
+        "signedPropertyNames": ["type", "timestamp", "challengeRequestId", "encryptedPublication"]
+    }`), 0644)
+    template, err := readTemplate(templatePath)
+    if (err != nil) {
+        t.Fatalf(`readTemplate error is "%v" instead of "<nil>"`, err)
+    }
+    encoded, err := createMessage(template, "", "", nil, 0, authorPrivateKey)
+    if (err != nil) {
+        t.Fatalf(`createMessage error is "%v" instead of "<nil>"`, err)
+    }
+    failed := getFailedChecks(validator.Check("", encoded))
+    if (len(failed) != 1 || failed[0] != "timestamp") {
+        t.Fatalf(`failed checks are "%v" instead of "[timestamp]"`, failed)
+    }
+    message, _ := plebbitValidator.DecodeMessage(encoded)
+    encryptedPublication := message["encryptedPublication"].(map[interface{}]interface{})
+    if (!bytes.Equal(encryptedPublication["ciphertext"].([]byte), []byte{0, 1, 2}) || message["timestamp"] != uint64(1)) {
+        t.Fatalf(`message is "%v"`, message)
+    }
+    if (message["signedPropertyNames"] != nil || message["signature"].(map[interface{}]interface{})["signedPropertyNames"].([]interface{})[3] != "encryptedPublication") {
+        t.Fatalf(`message signature is "%v"`, message["signature"])
+    }
+
+    _, err = createMessage(nil, "UNKNOWN", "", nil, 0, authorPrivateKey)
+    if (err == nil) {
+        t.Fatalf(`createMessage unknown type error is "<nil>"`)
+    }
+}
+
+func TestCreateStampedMessage(t *testing.T) {
+    authorPrivateKey, _ := plebbitValidator.GeneratePrivateKey()
+    authorPeerId, _ := plebbitValidator.GetPeerIdFromPrivateKey(authorPrivateKey)
+    proofOfWork := plebbitValidator.NewProofOfWork(plebbitValidator.ProofOfWorkConfig{MinimumDifficulty: 8, MaximumDifficulty: 8})
+    validator := plebbitValidator.NewValidator(nil, plebbitValidator.WithProofOfWork(proofOfWork))
+
+    encoded, err := createMessage(nil, "CHALLENGEREQUEST", "", nil, 8, authorPrivateKey)
+    if (err != nil) {
+        t.Fatalf(`createMessage error is "%v" instead of "<nil>"`, err)
+    }
+    failed := getFailedChecks(validator.Check(authorPeerId.String(), encoded))
+    if (len(failed) != 0) {
+        t.Fatalf(`stamped challenge request failed checks are "%v" instead of "[]"`, failed)
+    }
+    encoded, _ = createMessage(nil, "CHALLENGEREQUEST", "", nil, 0, authorPrivateKey)
+    failed = getFailedChecks(validator.Check(authorPeerId.String(), encoded))
+    if (len(failed) != 1 || failed[0] != "proofOfWork") {
+        t.Fatalf(`unstamped challenge request failed checks are "%v" instead of "[proofOfWork]"`, failed)
+    }
+}
+
+func TestCreateDelegation(t *testing.T) {
+    subplebbitPrivateKey, _ := plebbitValidator.GeneratePrivateKey()
+    hotPrivateKey, _ := plebbitValidator.GeneratePrivateKey()
+    authorPeerId, _ := plebbitValidator.GetPeerIdFromPrivateKey(hotPrivateKey)
+    subplebbitPeerId, _ := plebbitValidator.GetPeerIdFromPrivateKey(subplebbitPrivateKey)
+    validator := plebbitValidator.NewValidator(nil)
+
+    encodedDelegation, err := createDelegation(subplebbitPrivateKey, "", base64.StdEncoding.EncodeToString(plebbitValidator.GetPublicKeyFromPrivateKey(hotPrivateKey)), time.Now().Add(time.Hour))
+    if (err != nil) {
+        t.Fatalf(`createDelegation error is "%v" instead of "<nil>"`, err)
+    }
+    delegation, _ := plebbitValidator.DecodeMessage(encodedDelegation)
+    encoded, err := createMessage(nil, "CHALLENGE", authorPeerId.String(), delegation, 0, hotPrivateKey)
+    if (err != nil) {
+        t.Fatalf(`createMessage error is "%v" instead of "<nil>"`, err)
+    }
+    failed := getFailedChecks(validator.Check(subplebbitPeerId.String(), encoded))
+    if (len(failed) != 0) {
+        t.Fatalf(`delegated message failed checks are "%v" instead of "[]"`, failed)
+    }
+
+    // the delegation is only valid on its topic
+    encodedDelegation, _ = createDelegation(subplebbitPrivateKey, "memes.eth", base64.StdEncoding.EncodeToString(plebbitValidator.GetPublicKeyFromPrivateKey(hotPrivateKey)), time.Now().Add(time.Hour))
+    delegation, _ = plebbitValidator.DecodeMessage(encodedDelegation)
+    encoded, _ = createMessage(nil, "CHALLENGE", authorPeerId.String(), delegation, 0, hotPrivateKey)
+    failed = getFailedChecks(validator.Check(subplebbitPeerId.String(), encoded))
+    if (len(failed) != 1 || failed[0] != "topic") {
+        t.Fatalf(`delegated message on another topic failed checks are "%v" instead of "[topic]"`, failed)
+    }
+
+    _, err = createDelegation(subplebbitPrivateKey, "", "AAEC", time.Now())
+    if (err == nil) {
+        t.Fatalf(`createDelegation with invalid public key error is "<nil>"`)
+    }
+}
+
+func TestPrintKeyInfo(t *testing.T) {
+    privateKey, _ := plebbitValidator.GeneratePrivateKey()
+    peerId, _ := plebbitValidator.GetPeerIdFromPrivateKey(privateKey)
+    keyPath := filepath.Join(t.TempDir(), "key")
+    os.WriteFile(keyPath, []byte(encodeBase64(privateKey) + "\n"), 0600)
+    readKey, err := readPrivateKey(keyPath)
+    if (err != nil || !bytes.Equal(readKey, privateKey)) {
+        t.Fatalf(`readPrivateKey is "%v" with error "%v" instead of "%v"`, readKey, err, privateKey)
+    }
+
+    output := &bytes.Buffer{}
+    printKeyInfo(output, privateKey)
+    if (!strings.Contains(output.String(), "subplebbitAddress   " + peerId.String())) {
+        t.Fatalf(`key info is "%v"`, output.String())
+    }
+    challengeRequestId, err := parseChallengeRequestId(bytesPrefix + encodeBase64([]byte(peerId)))
+    if (err != nil || !bytes.Equal(challengeRequestId, []byte(peerId))) {
+        t.Fatalf(`challengeRequestId is "%v" with error "%v" instead of "%v"`, challengeRequestId, err, []byte(peerId))
+    }
+}
+
+func encodeBase64(data []byte) string {
+    encoded, _ := encodeOutput(data, "base64")
+    return strings.TrimSpace(string(encoded))
+}
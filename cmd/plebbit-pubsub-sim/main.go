@@ -0,0 +1,83 @@
+// simulate spam attacks on an in-process gossipsub network with the plebbit validator, to tune the
+// peer score thresholds and the app specific score with evidence
+//
+//    go run ./cmd/plebbit-pubsub-sim -honest 20 -attackers 3 -attack unanswered,invalid-signature -duration 1m
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "strings"
+    "time"
+    plebbitValidator "github.com/plebbit/go-libp2p-pubsub-plebbit-validator"
+)
+
+func main() {
+    config := simulationConfig{}
+    var preset string
+    var attack string
+    flag.IntVar(&config.honestNodes, "honest", 10, "number of honest nodes, the first one is the subplebbit")
+    flag.IntVar(&config.attackerNodes, "attackers", 2, "number of attacker nodes")
+    flag.StringVar(&attack, "attack", attackUnanswered, "comma separated attacks of the attacker nodes, assigned in turn: " + strings.Join(attacks, ", "))
+    flag.IntVar(&config.connections, "connections", 4, "number of random peers each node connects to")
+    flag.DurationVar(&config.duration, "duration", 30 * time.Second, "duration of the simulation")
+    flag.Float64Var(&config.honestRate, "honest-rate", 0.5, "challenge requests per second published by each honest author")
+    flag.Float64Var(&config.attackRate, "attack-rate", 10, "messages per second published by each attacker")
+    flag.StringVar(&preset, "preset", "default", "peer score preset: conservative, default or aggressive")
+    flag.Float64Var(&config.graylistThreshold, "graylist-threshold", 0, "override the preset graylist threshold")
+    flag.Float64Var(&config.appSpecificWeight, "app-specific-weight", 0, "override the preset app specific score weight")
+    flag.Int64Var(&config.seed, "seed", 1, "seed of the random topology")
+    flag.Parse()
+    config.attacks = strings.Split(attack, ",")
+
+    switch (preset) {
+    case "conservative":
+        config.preset = plebbitValidator.ScorePresetConservative
+    case "default":
+        config.preset = plebbitValidator.ScorePresetDefault
+    case "aggressive":
+        config.preset = plebbitValidator.ScorePresetAggressive
+    default:
+        fmt.Fprintln(os.Stderr, "unknown preset", preset)
+        os.Exit(2)
+    }
+
+    report, err := runSimulation(config)
+    if (err != nil) {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+    }
+    printReport(report)
+}
+
+func printReport(report *simulationReport) {
+    fmt.Printf("%v honest nodes, %v attackers, attacks %v, %v, preset %v, graylist threshold %v\n\n",
+        report.config.honestNodes, report.config.attackerNodes, strings.Join(report.config.attacks, ", "), report.config.duration, report.config.preset, report.graylistThreshold)
+
+    fmt.Println("time to graylist")
+    for _, attacker := range report.attackers {
+        fmt.Printf("  %v  %v, graylisted by %v/%v connected honest peers, first %v, all %v, %v messages delivered\n",
+            attacker.peerId, attacker.attack, attacker.graylistedHonestPeers, attacker.connectedHonestPeers, formatGraylistTime(attacker.firstGraylist), formatGraylistTime(attacker.allGraylist), attacker.delivered)
+    }
+    fmt.Println()
+
+    fmt.Printf("false positives  %v/%v honest peer pairs graylisted (%v)\n", report.falsePositivePeerPairs, report.honestPeerPairs, formatRate(report.falsePositivePeerPairs, report.honestPeerPairs))
+    fmt.Printf("challenges       %v/%v honest challenge requests verified (%v)\n", report.completedChallenges, report.challengeRequests, formatRate(report.completedChallenges, report.challengeRequests))
+    fmt.Printf("honest delivery  %v/%v (%v) of %v messages\n", report.honestDelivered, report.honestExpected, formatRate(report.honestDelivered, report.honestExpected), report.honestPublished)
+    fmt.Printf("attack delivery  %v/%v (%v) of %v messages\n", report.attackDelivered, report.attackExpected, formatRate(report.attackDelivered, report.attackExpected), report.attackPublished)
+}
+
+func formatGraylistTime(graylistTime time.Duration) string {
+    if (graylistTime < 0) {
+        return "never"
+    }
+    return graylistTime.Round(100 * time.Millisecond).String()
+}
+
+func formatRate(count int, total int) string {
+    if (total == 0) {
+        return "n/a"
+    }
+    return fmt.Sprintf("%.1f%%", float64(count) / float64(total) * 100)
+}
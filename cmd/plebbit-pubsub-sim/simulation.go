@@ -0,0 +1,540 @@
+package main
+
+import (
+    "context"
+    "crypto/sha256"
+    "errors"
+    "math/rand"
+    "sync"
+    "time"
+    pubsub "github.com/libp2p/go-libp2p-pubsub"
+    host "github.com/libp2p/go-libp2p/core/host"
+    peer "github.com/libp2p/go-libp2p/core/peer"
+    mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+    plebbitValidator "github.com/plebbit/go-libp2p-pubsub-plebbit-validator"
+)
+
+const (
+    attackUnanswered = "unanswered"
+    attackInvalidSignature = "invalid-signature"
+    attackStaleTimestamp = "stale-timestamp"
+)
+
+var attacks = []string{attackUnanswered, attackInvalidSignature, attackStaleTimestamp}
+
+type simulationConfig struct {
+    honestNodes int
+    attackerNodes int
+    // the attacks of the attacker nodes, assigned in turn
+    attacks []string
+    // the number of random peers each node connects to
+    connections int
+    duration time.Duration
+    // messages per second published by each honest author and each attacker
+    honestRate float64
+    attackRate float64
+    preset plebbitValidator.ScorePreset
+    // override the preset graylist threshold if not 0
+    graylistThreshold float64
+    appSpecificWeight float64
+    seed int64
+}
+
+type attackerReport struct {
+    peerId peer.ID
+    attack string
+    connectedHonestPeers int
+    graylistedHonestPeers int
+    // since the attack started, -1 if never graylisted
+    firstGraylist time.Duration
+    allGraylist time.Duration
+    // the messages of the attacker received by the honest nodes
+    delivered int
+}
+
+type simulationReport struct {
+    config simulationConfig
+    graylistThreshold float64
+    attackers []attackerReport
+    // connected honest peers scored below the graylist threshold by another honest peer
+    honestPeerPairs int
+    falsePositivePeerPairs int
+    // the challenge requests of the honest authors verified by the subplebbit
+    challengeRequests int
+    completedChallenges int
+    honestPublished int
+    honestDelivered int
+    honestExpected int
+    attackPublished int
+    attackDelivered int
+    attackExpected int
+}
+
+type simulationNode struct {
+    host host.Host
+    honest bool
+    // the attack of an attacker node
+    attack string
+    scoreInspector *plebbitValidator.ScoreInspector
+    topic *pubsub.Topic
+    subscription *pubsub.Subscription
+    mutex *sync.Mutex
+    received map[[32]byte]bool
+}
+
+type simulation struct {
+    config simulationConfig
+    ctx context.Context
+    random *rand.Rand
+    nodes []*simulationNode
+    // peer ids of the nodes, to classify the scored peers
+    honestPeers map[peer.ID]bool
+    links map[peer.ID]map[peer.ID]bool
+    subplebbitPrivateKey []byte
+    scoreConfig *plebbitValidator.ScoreConfig
+    mutex *sync.Mutex
+    // the honest authors by challenge request id, until the subplebbit verifies the challenge
+    honestAuthors map[string]*honestAuthor
+    challengeRequests int
+    completedChallenges int
+    honestMessages map[[32]byte]bool
+    // the attacker that published each attack message
+    attackMessages map[[32]byte]peer.ID
+    // when each honest node first graylisted each peer, since the attack started
+    graylisted map[peer.ID]map[peer.ID]time.Duration
+}
+
+type honestAuthor struct {
+    node *simulationNode
+    privateKey []byte
+}
+
+func runSimulation(config simulationConfig) (*simulationReport, error) {
+    if (config.honestNodes < 2) {
+        return nil, errors.New("need at least 2 honest nodes, the subplebbit and an author")
+    }
+    if (config.attackerNodes > 0 && len(config.attacks) == 0) {
+        return nil, errors.New("need at least 1 attack for the attacker nodes")
+    }
+    for _, attack := range config.attacks {
+        if (attack != attackUnanswered && attack != attackInvalidSignature && attack != attackStaleTimestamp) {
+            return nil, errors.New("unknown attack " + attack)
+        }
+    }
+    scoreConfig, err := plebbitValidator.NewScoreConfig(config.preset)
+    if (err != nil) {
+        return nil, err
+    }
+    if (config.graylistThreshold != 0) {
+        thresholds := scoreConfig.Thresholds
+        thresholds.GraylistThreshold = config.graylistThreshold
+        scoreConfig.WithThresholds(thresholds)
+    }
+    if (config.appSpecificWeight != 0) {
+        scoreConfig.WithAppSpecificWeight(config.appSpecificWeight)
+    }
+    subplebbitPrivateKey, err := plebbitValidator.GeneratePrivateKey()
+    if (err != nil) {
+        return nil, err
+    }
+
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    simulation := &simulation{
+        config: config,
+        ctx: ctx,
+        random: rand.New(rand.NewSource(config.seed)),
+        honestPeers: map[peer.ID]bool{},
+        links: map[peer.ID]map[peer.ID]bool{},
+        subplebbitPrivateKey: subplebbitPrivateKey,
+        scoreConfig: scoreConfig,
+        mutex: &sync.Mutex{},
+        honestAuthors: map[string]*honestAuthor{},
+        honestMessages: map[[32]byte]bool{},
+        attackMessages: map[[32]byte]peer.ID{},
+        graylisted: map[peer.ID]map[peer.ID]time.Duration{},
+    }
+    network := mocknet.New()
+    defer network.Close()
+    err = simulation.createNodes(network)
+    if (err != nil) {
+        return nil, err
+    }
+
+    // wait for the subscriptions to propagate and the meshes to form
+    time.Sleep(2 * time.Second)
+
+    start := time.Now()
+    simulationCtx, stop := context.WithTimeout(ctx, config.duration)
+    defer stop()
+    waitGroup := &sync.WaitGroup{}
+    for _, node := range simulation.nodes {
+        node := node
+        waitGroup.Add(1)
+        go func() {
+            defer waitGroup.Done()
+            simulation.receive(simulationCtx, node)
+        }()
+    }
+    // the first honest node is the subplebbit, it only answers the challenge exchanges
+    for i, node := range simulation.nodes[1:] {
+        node := node
+        seed := config.seed + int64(i)
+        waitGroup.Add(1)
+        go func() {
+            defer waitGroup.Done()
+            simulation.publish(simulationCtx, node, seed)
+        }()
+    }
+    simulation.monitor(simulationCtx, start)
+    waitGroup.Wait()
+
+    // let the last messages propagate
+    time.Sleep(time.Second)
+    return simulation.report(), nil
+}
+
+func (simulation *simulation) createNodes(network mocknet.Mocknet) error {
+    nodeCount := simulation.config.honestNodes + simulation.config.attackerNodes
+    for i := 0; i < nodeCount; i++ {
+        _, err := network.GenPeer()
+        if (err != nil) {
+            return err
+        }
+    }
+    err := network.LinkAll()
+    if (err != nil) {
+        return err
+    }
+    hosts := network.Hosts()
+    for _, host := range hosts {
+        simulation.links[host.ID()] = map[peer.ID]bool{}
+    }
+
+    // a chain of the honest nodes so the network is connected, each attacker connected to a random honest
+    // node so all the attacks are measured, plus random connections
+    for i := 1; i < simulation.config.honestNodes; i++ {
+        simulation.connect(network, hosts[i - 1], hosts[i])
+    }
+    for i := simulation.config.honestNodes; i < nodeCount; i++ {
+        simulation.connect(network, hosts[simulation.random.Intn(simulation.config.honestNodes)], hosts[i])
+    }
+    for i := 0; i < nodeCount; i++ {
+        for j := 1; j < simulation.config.connections; j++ {
+            otherHost := hosts[simulation.random.Intn(nodeCount)]
+            if (otherHost.ID() != hosts[i].ID() && !simulation.links[hosts[i].ID()][otherHost.ID()]) {
+                simulation.connect(network, hosts[i], otherHost)
+            }
+        }
+    }
+
+    subplebbitPeerId, _ := plebbitValidator.GetPeerIdFromPrivateKey(simulation.subplebbitPrivateKey)
+    for i, host := range hosts {
+        node := &simulationNode{
+            host: host,
+            honest: i < simulation.config.honestNodes,
+            mutex: &sync.Mutex{},
+            received: map[[32]byte]bool{},
+        }
+        if (!node.honest) {
+            node.attack = simulation.config.attacks[(i - simulation.config.honestNodes) % len(simulation.config.attacks)]
+        }
+        options := []pubsub.Option{pubsub.WithMessageIdFn(plebbitValidator.MessageIdFn)}
+        // the attackers don't validate or score, so they can publish anything
+        if (node.honest) {
+            simulation.honestPeers[host.ID()] = true
+            validator := plebbitValidator.NewValidator(host)
+            node.scoreInspector = plebbitValidator.NewScoreInspector(validator)
+            peerScoreOption, err := simulation.scoreConfig.PubsubOption(validator)
+            if (err != nil) {
+                return err
+            }
+            options = append(options,
+                pubsub.WithDefaultValidator(validator.Validate),
+                peerScoreOption,
+                node.scoreInspector.PubsubOption(100 * time.Millisecond),
+            )
+        }
+        nodePubsub, err := pubsub.NewGossipSub(simulation.ctx, host, options...)
+        if (err != nil) {
+            return err
+        }
+        if (node.honest) {
+            // each challenge request is followed by a challenge, a challenge answer and a challenge verification
+            expectedMessageRate := simulation.config.honestRate * float64(simulation.config.honestNodes - 1) * 4
+            node.topic, err = plebbitValidator.JoinTopic(nodePubsub, subplebbitPeerId.String(), simulation.scoreConfig.TopicScoreParams(expectedMessageRate))
+        } else {
+            node.topic, err = nodePubsub.Join(subplebbitPeerId.String())
+        }
+        if (err != nil) {
+            return err
+        }
+        node.subscription, err = node.topic.Subscribe()
+        if (err != nil) {
+            return err
+        }
+        simulation.nodes = append(simulation.nodes, node)
+    }
+    return nil
+}
+
+func (simulation *simulation) connect(network mocknet.Mocknet, host host.Host, otherHost host.Host) {
+    _, err := network.ConnectPeers(host.ID(), otherHost.ID())
+    if (err != nil) {
+        return
+    }
+    simulation.links[host.ID()][otherHost.ID()] = true
+    simulation.links[otherHost.ID()][host.ID()] = true
+}
+
+func (simulation *simulation) receive(ctx context.Context, node *simulationNode) {
+    for {
+        pubsubMessage, err := node.subscription.Next(ctx)
+        if (err != nil) {
+            return
+        }
+        messageHash := sha256.Sum256(pubsubMessage.Data)
+        node.mutex.Lock()
+        node.received[messageHash] = true
+        node.mutex.Unlock()
+
+        // the subplebbit and the honest authors go through the challenge exchange, request, challenge,
+        // answer and verification
+        if (!node.honest) {
+            continue
+        }
+        message, err := plebbitValidator.DecodeMessage(pubsubMessage.Data)
+        if (err != nil) {
+            continue
+        }
+        challengeRequestId, _ := message["challengeRequestId"].([]byte)
+        simulation.mutex.Lock()
+        author := simulation.honestAuthors[string(challengeRequestId)]
+        simulation.mutex.Unlock()
+        if (author == nil) {
+            continue
+        }
+        subplebbit := node == simulation.nodes[0]
+        switch {
+        case subplebbit && message["type"] == "CHALLENGEREQUEST":
+            simulation.publishResponse(ctx, node, "CHALLENGE", challengeRequestId, simulation.subplebbitPrivateKey)
+        case node == author.node && message["type"] == "CHALLENGE":
+            simulation.publishResponse(ctx, node, "CHALLENGEANSWER", challengeRequestId, author.privateKey)
+        case subplebbit && message["type"] == "CHALLENGEANSWER":
+            simulation.mutex.Lock()
+            delete(simulation.honestAuthors, string(challengeRequestId))
+            simulation.completedChallenges++
+            simulation.mutex.Unlock()
+            simulation.publishResponse(ctx, node, "CHALLENGEVERIFICATION", challengeRequestId, simulation.subplebbitPrivateKey)
+        }
+    }
+}
+
+func (simulation *simulation) publishResponse(ctx context.Context, node *simulationNode, messageType string, challengeRequestId []byte, privateKey []byte) {
+    message := createMessage(messageType, challengeRequestId, time.Now().Unix())
+    signMessage(message, privateKey)
+    simulation.publishMessage(ctx, node, plebbitValidator.EncodeMessage(message))
+}
+
+func (simulation *simulation) publish(ctx context.Context, node *simulationNode, seed int64) {
+    rate := simulation.config.attackRate
+    if (node.honest) {
+        rate = simulation.config.honestRate
+    }
+    if (rate <= 0) {
+        return
+    }
+    // start at a random time so the publishers are not synchronized
+    interval := time.Duration(float64(time.Second) / rate)
+    random := rand.New(rand.NewSource(seed))
+    select {
+    case <-time.After(time.Duration(random.Int63n(int64(interval)))):
+    case <-ctx.Done():
+        return
+    }
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        if (node.honest) {
+            simulation.publishMessage(ctx, node, simulation.createHonestMessage(node))
+        } else {
+            simulation.publishMessage(ctx, node, createAttackMessage(node.attack))
+        }
+        select {
+        case <-ticker.C:
+        case <-ctx.Done():
+            return
+        }
+    }
+}
+
+func (simulation *simulation) publishMessage(ctx context.Context, node *simulationNode, data []byte) {
+    messageHash := sha256.Sum256(data)
+    simulation.mutex.Lock()
+    if (node.honest) {
+        simulation.honestMessages[messageHash] = true
+    } else {
+        simulation.attackMessages[messageHash] = node.host.ID()
+    }
+    simulation.mutex.Unlock()
+    node.topic.Publish(ctx, data)
+}
+
+func (simulation *simulation) createHonestMessage(node *simulationNode) []byte {
+    authorPrivateKey, _ := plebbitValidator.GeneratePrivateKey()
+    challengeRequestId, _ := plebbitValidator.GetPeerIdFromPrivateKey(authorPrivateKey)
+    simulation.mutex.Lock()
+    simulation.honestAuthors[string(challengeRequestId)] = &honestAuthor{node: node, privateKey: authorPrivateKey}
+    simulation.challengeRequests++
+    simulation.mutex.Unlock()
+    challengeRequest := createMessage("CHALLENGEREQUEST", []byte(challengeRequestId), time.Now().Unix())
+    signMessage(challengeRequest, authorPrivateKey)
+    return plebbitValidator.EncodeMessage(challengeRequest)
+}
+
+func createAttackMessage(attack string) []byte {
+    authorPrivateKey, _ := plebbitValidator.GeneratePrivateKey()
+    challengeRequestId, _ := plebbitValidator.GetPeerIdFromPrivateKey(authorPrivateKey)
+    timestamp := time.Now().Unix()
+    if (attack == attackStaleTimestamp) {
+        timestamp -= 60 * 60
+    }
+    challengeRequest := createMessage("CHALLENGEREQUEST", []byte(challengeRequestId), timestamp)
+    signMessage(challengeRequest, authorPrivateKey)
+    if (attack == attackInvalidSignature) {
+        signature := challengeRequest["signature"].(map[string]interface{})
+        signature["signature"].([]byte)[0] ^= 0xff
+    }
+    return plebbitValidator.EncodeMessage(challengeRequest)
+}
+
+func createMessage(messageType string, challengeRequestId []byte, timestamp int64) map[string]interface{} {
+    message := map[string]interface{}{
+        "type": messageType,
+        "timestamp": timestamp,
+        "protocolVersion": "1.0.0",
+        "userAgent": "/plebbit-pubsub-sim/0.0.1",
+        "acceptedChallengeTypes": []string{"image/png"},
+        "encryptedPublication": createEnvelope([]byte("publication")),
+        "challengeRequestId": challengeRequestId,
+    }
+    if (messageType == "CHALLENGE") {
+        message["encryptedChallenges"] = createEnvelope([]byte("challenges"))
+    }
+    if (messageType == "CHALLENGEANSWER") {
+        message["encryptedChallengeAnswers"] = createEnvelope([]byte("challenge answers"))
+    }
+    return message
+}
+
+// a structurally valid envelope, the ciphertext is not encrypted and the iv and tag are zeros
+func createEnvelope(ciphertext []byte) map[string]interface{} {
+    return map[string]interface{}{"ciphertext": ciphertext, "iv": make([]byte, 12), "tag": make([]byte, 16), "type": "ed25519-aes-gcm"}
+}
+
+func signMessage(message map[string]interface{}, privateKey []byte) {
+    signedPropertyNames := []string{"type", "timestamp", "challengeRequestId", "acceptedChallengeTypes", "encryptedPublication"}
+    plebbitValidator.SignMessage(message, privateKey, signedPropertyNames)
+}
+
+// sample the scores of the honest nodes until the simulation ends
+func (simulation *simulation) monitor(ctx context.Context, start time.Time) {
+    ticker := time.NewTicker(200 * time.Millisecond)
+    defer ticker.Stop()
+    graylistThreshold := simulation.scoreConfig.Thresholds.GraylistThreshold
+    for {
+        select {
+        case <-ticker.C:
+        case <-ctx.Done():
+            return
+        }
+        elapsed := time.Since(start)
+        for _, node := range simulation.nodes {
+            if (!node.honest) {
+                continue
+            }
+            for _, peerReport := range node.scoreInspector.PeerReports() {
+                if (peerReport.Score >= graylistThreshold) {
+                    continue
+                }
+                if (simulation.graylisted[node.host.ID()] == nil) {
+                    simulation.graylisted[node.host.ID()] = map[peer.ID]time.Duration{}
+                }
+                if _, ok := simulation.graylisted[node.host.ID()][peerReport.PeerId]; !ok {
+                    simulation.graylisted[node.host.ID()][peerReport.PeerId] = elapsed
+                }
+            }
+        }
+    }
+}
+
+func (simulation *simulation) report() *simulationReport {
+    simulation.mutex.Lock()
+    defer simulation.mutex.Unlock()
+
+    report := &simulationReport{
+        config: simulation.config,
+        graylistThreshold: simulation.scoreConfig.Thresholds.GraylistThreshold,
+        honestPublished: len(simulation.honestMessages),
+        attackPublished: len(simulation.attackMessages),
+        challengeRequests: simulation.challengeRequests,
+        completedChallenges: simulation.completedChallenges,
+    }
+    attackDelivered := map[peer.ID]int{}
+    for _, node := range simulation.nodes {
+        if (!node.honest) {
+            attackerReport := attackerReport{peerId: node.host.ID(), attack: node.attack, firstGraylist: -1, allGraylist: -1}
+            for peerId := range simulation.links[node.host.ID()] {
+                if (!simulation.honestPeers[peerId]) {
+                    continue
+                }
+                attackerReport.connectedHonestPeers++
+                graylistTime, ok := simulation.graylisted[peerId][node.host.ID()]
+                if (!ok) {
+                    continue
+                }
+                attackerReport.graylistedHonestPeers++
+                if (attackerReport.firstGraylist == -1 || graylistTime < attackerReport.firstGraylist) {
+                    attackerReport.firstGraylist = graylistTime
+                }
+                if (graylistTime > attackerReport.allGraylist) {
+                    attackerReport.allGraylist = graylistTime
+                }
+            }
+            if (attackerReport.graylistedHonestPeers < attackerReport.connectedHonestPeers) {
+                attackerReport.allGraylist = -1
+            }
+            report.attackers = append(report.attackers, attackerReport)
+            continue
+        }
+
+        for peerId := range simulation.links[node.host.ID()] {
+            if (!simulation.honestPeers[peerId]) {
+                continue
+            }
+            report.honestPeerPairs++
+            if _, ok := simulation.graylisted[node.host.ID()][peerId]; ok {
+                report.falsePositivePeerPairs++
+            }
+        }
+
+        // the messages received by the honest nodes, a publisher receives its own messages
+        node.mutex.Lock()
+        for messageHash := range node.received {
+            if (simulation.honestMessages[messageHash]) {
+                report.honestDelivered++
+            }
+            if attackerPeerId, ok := simulation.attackMessages[messageHash]; ok {
+                report.attackDelivered++
+                attackDelivered[attackerPeerId]++
+            }
+        }
+        node.mutex.Unlock()
+    }
+    report.honestExpected = report.honestPublished * simulation.config.honestNodes
+    for i := range report.attackers {
+        report.attackers[i].delivered = attackDelivered[report.attackers[i].peerId]
+    }
+    report.attackExpected = report.attackPublished * simulation.config.honestNodes
+    return report
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+    "testing"
+    "time"
+    plebbitValidator "github.com/plebbit/go-libp2p-pubsub-plebbit-validator"
+)
+
+func TestRunSimulation(t *testing.T) {
+    report, err := runSimulation(simulationConfig{
+        honestNodes: 4,
+        attackerNodes: 3,
+        attacks: []string{attackInvalidSignature, attackStaleTimestamp, attackUnanswered},
+        connections: 2,
+        duration: 3 * time.Second,
+        honestRate: 2,
+        // enough unanswered challenge requests to reach the minimum challenge count in the run
+        attackRate: 100,
+        preset: plebbitValidator.ScorePresetDefault,
+        seed: 1,
+    })
+    if (err != nil) {
+        t.Fatalf(`runSimulation error is "%v" instead of "<nil>"`, err)
+    }
+    if (len(report.attackers) != 3) {
+        t.Fatalf(`attackers count is "%v" instead of "3"`, len(report.attackers))
+    }
+    for _, attacker := range report.attackers {
+        if (attacker.firstGraylist < 0) {
+            t.Fatalf(`%v attacker was not graylisted "%+v"`, attacker.attack, attacker)
+        }
+        // the unanswered challenge requests are valid messages, they are delivered until the attacker is graylisted
+        if (attacker.attack != attackUnanswered && attacker.delivered != 0) {
+            t.Fatalf(`%v attack delivered is "%v" instead of "0"`, attacker.attack, attacker.delivered)
+        }
+    }
+    if (report.honestPublished == 0 || report.honestDelivered == 0) {
+        t.Fatalf(`honest published is "%v" and delivered is "%v"`, report.honestPublished, report.honestDelivered)
+    }
+    // the honest authors complete their challenges and are not graylisted
+    if (report.completedChallenges == 0) {
+        t.Fatalf(`completed challenges is "0" of "%v" challenge requests`, report.challengeRequests)
+    }
+    if (report.falsePositivePeerPairs != 0) {
+        t.Fatalf(`false positive peer pairs is "%v" instead of "0"`, report.falsePositivePeerPairs)
+    }
+
+    _, err = runSimulation(simulationConfig{honestNodes: 4, attackerNodes: 1, attacks: []string{attackUnanswered, "unknown"}})
+    if (err == nil) {
+        t.Fatalf(`runSimulation of unknown attack error is "<nil>"`)
+    }
+}
@@ -0,0 +1,163 @@
+// decode a captured plebbit pubsub message and report which validator check rejects it
+//
+//    plebbit-pubsub-validate -topic 12D3KooW... message.cbor
+//    echo a46474797065... | plebbit-pubsub-validate -topic 12D3KooW... -encoding hex
+package main
+
+import (
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+    plebbitValidator "github.com/plebbit/go-libp2p-pubsub-plebbit-validator"
+)
+
+func main() {
+    topic := flag.String("topic", "", "pubsub topic the message was published on, the subplebbit address")
+    encoding := flag.String("encoding", "raw", "encoding of the input: raw, hex or base64")
+    now := flag.String("now", "", "check the timestamp at this time instead of the current time, unix seconds or RFC3339")
+    noTimestamp := flag.Bool("no-timestamp", false, "skip the timestamp check, e.g. for a message captured more than 5 minutes ago")
+    flag.Usage = func() {
+        fmt.Fprintln(flag.CommandLine.Output(), "usage: plebbit-pubsub-validate [flags] [file]")
+        fmt.Fprintln(flag.CommandLine.Output(), "read the cbor message from file, or from stdin if there is no file or file is -")
+        flag.PrintDefaults()
+    }
+    flag.Parse()
+    if (flag.NArg() > 1) {
+        flag.Usage()
+        os.Exit(2)
+    }
+
+    input, err := readInput(flag.Arg(0))
+    if (err != nil) {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    data, err := decodeInput(input, *encoding)
+    if (err != nil) {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+
+    message, err := plebbitValidator.DecodeMessage(data)
+    if (err == nil) {
+        messageJson, _ := json.MarshalIndent(toJson(message), "", "  ")
+        fmt.Println(string(messageJson))
+        fmt.Println()
+    }
+
+    validatorOptions, err := getValidatorOptions(*now, *noTimestamp)
+    if (err != nil) {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    // the checks don't use the host
+    validator := plebbitValidator.NewValidator(nil, validatorOptions...)
+    valid := printCheckResults(os.Stdout, validator.Check(*topic, data))
+    if (!valid) {
+        os.Exit(1)
+    }
+}
+
+func readInput(path string) ([]byte, error) {
+    if (path == "" || path == "-") {
+        return io.ReadAll(os.Stdin)
+    }
+    return os.ReadFile(path)
+}
+
+func decodeInput(input []byte, encoding string) ([]byte, error) {
+    switch (encoding) {
+    case "raw":
+        return input, nil
+    case "hex":
+        return hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(input)), "0x"))
+    case "base64":
+        trimmed := strings.TrimSpace(string(input))
+        data, err := base64.StdEncoding.DecodeString(trimmed)
+        if (err != nil) {
+            // plebbit-js and browsers sometimes use unpadded or url safe base64
+            data, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(strings.NewReplacer("+", "-", "/", "_").Replace(trimmed), "="))
+        }
+        return data, err
+    }
+    return nil, errors.New("unknown encoding " + encoding)
+}
+
+func getValidatorOptions(now string, noTimestamp bool) ([]plebbitValidator.ValidatorOption, error) {
+    validatorOptions := []plebbitValidator.ValidatorOption{}
+    if (noTimestamp) {
+        validatorOptions = append(validatorOptions, plebbitValidator.WithoutTimestamp())
+    }
+    if (now != "") {
+        nowTime, err := parseTime(now)
+        if (err != nil) {
+            return nil, err
+        }
+        validatorOptions = append(validatorOptions, plebbitValidator.WithClock(func() time.Time {
+            return nowTime
+        }))
+    }
+    return validatorOptions, nil
+}
+
+// unix seconds like the message timestamp, or RFC3339
+func parseTime(value string) (time.Time, error) {
+    seconds, err := strconv.ParseInt(value, 10, 64)
+    if (err == nil) {
+        return time.Unix(seconds, 0), nil
+    }
+    parsed, err := time.Parse(time.RFC3339, value)
+    if (err != nil) {
+        return time.Time{}, errors.New("invalid time " + value + ", must be unix seconds or RFC3339")
+    }
+    return parsed, nil
+}
+
+// convert the cbor decoded values to values json can encode, bytes are base64 like encoding/json
+func toJson(value interface{}) interface{} {
+    switch value := value.(type) {
+    case map[string]interface{}:
+        converted := map[string]interface{}{}
+        for key, element := range value {
+            converted[key] = toJson(element)
+        }
+        return converted
+    case map[interface{}]interface{}:
+        converted := map[string]interface{}{}
+        for key, element := range value {
+            converted[fmt.Sprint(key)] = toJson(element)
+        }
+        return converted
+    case []interface{}:
+        converted := make([]interface{}, len(value))
+        for i, element := range value {
+            converted[i] = toJson(element)
+        }
+        return converted
+    }
+    return value
+}
+
+// print a line per check, returns false if a check failed
+func printCheckResults(output io.Writer, checkResults []plebbitValidator.CheckResult) bool {
+    valid := true
+    for _, checkResult := range checkResults {
+        status := "ok"
+        if (checkResult.Skipped) {
+            status = "skipped"
+        } else if (checkResult.Err != nil) {
+            status = "FAIL " + checkResult.Err.Error()
+            valid = false
+        }
+        fmt.Fprintf(output, "%-20v%v\n", checkResult.Name, status)
+    }
+    return valid
+}
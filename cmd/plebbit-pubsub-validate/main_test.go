@@ -0,0 +1,88 @@
+package main
+
+import (
+    "testing"
+    "bytes"
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/json"
+    "os"
+    "path/filepath"
+    "strings"
+    plebbitValidator "github.com/plebbit/go-libp2p-pubsub-plebbit-validator"
+)
+
+// a challenge request signed at timestamp 1, created with
+//
+//    plebbit-pubsub-sign sign -key author.key -type CHALLENGEREQUEST -template testdata/challenge-request.json -out testdata/challenge-request.cbor
+func readChallengeRequest(t *testing.T) []byte {
+    data, err := os.ReadFile(filepath.Join("testdata", "challenge-request.cbor"))
+    if (err != nil) {
+        t.Fatalf(`ReadFile error is "%v" instead of "<nil>"`, err)
+    }
+    return data
+}
+
+func TestDecodeInput(t *testing.T) {
+    data := []byte{0xa1, 0x64, 0x74, 0x79, 0x70, 0x65, 0xff}
+    inputs := map[string]string{
+        "raw": string(data),
+        "hex": "0x" + hex.EncodeToString(data) + "\n",
+        "base64": base64.RawURLEncoding.EncodeToString(data),
+    }
+    for encoding, input := range inputs {
+        decoded, err := decodeInput([]byte(input), encoding)
+        if (err != nil || !bytes.Equal(decoded, data)) {
+            t.Fatalf(`decoded "%v" input is "%v" with error "%v" instead of "%v"`, encoding, decoded, err, data)
+        }
+    }
+    _, err := decodeInput(data, "unknown")
+    if (err == nil) {
+        t.Fatalf(`decodeInput unknown encoding error is "<nil>"`)
+    }
+}
+
+func TestPrintCheckResults(t *testing.T) {
+    data := readChallengeRequest(t)
+
+    message, err := plebbitValidator.DecodeMessage(data)
+    if (err != nil) {
+        t.Fatalf(`DecodeMessage error is "%v" instead of "<nil>"`, err)
+    }
+    _, err = json.Marshal(toJson(message))
+    if (err != nil) {
+        t.Fatalf(`json.Marshal error is "%v" instead of "<nil>"`, err)
+    }
+
+    // the hardcoded timestamp is too old
+    output := &bytes.Buffer{}
+    valid := printCheckResults(output, plebbitValidator.NewValidator(nil).Check("topic", data))
+    if (valid || !strings.Contains(output.String(), "timestamp           FAIL invalid message timestamp, older than 5 minutes")) {
+        t.Fatalf(`check results are "%v"`, output.String())
+    }
+    if (!strings.Contains(output.String(), "signature           ok")) {
+        t.Fatalf(`check results are "%v"`, output.String())
+    }
+}
+
+func TestGetValidatorOptions(t *testing.T) {
+    data := readChallengeRequest(t)
+
+    // the hardcoded timestamp passes at the time it was signed, or without the timestamp check
+    for _, flags := range [][2]string{{"1", ""}, {"1970-01-01T00:04:00Z", ""}, {"", "no-timestamp"}} {
+        validatorOptions, err := getValidatorOptions(flags[0], flags[1] != "")
+        if (err != nil) {
+            t.Fatalf(`getValidatorOptions "%v" error is "%v" instead of "<nil>"`, flags, err)
+        }
+        output := &bytes.Buffer{}
+        valid := printCheckResults(output, plebbitValidator.NewValidator(nil, validatorOptions...).Check("topic", data))
+        if (!valid) {
+            t.Fatalf(`"%v" check results are "%v"`, flags, output.String())
+        }
+    }
+
+    _, err := getValidatorOptions("yesterday", false)
+    if (err == nil) {
+        t.Fatalf(`getValidatorOptions invalid time error is "<nil>"`)
+    }
+}
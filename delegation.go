@@ -0,0 +1,231 @@
+package pubsubPlebbitValidator
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "sync"
+    "time"
+    peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// a delegation certificate lets the subplebbit owner key authorize another key, e.g. a hot key
+// or a moderator key, to sign CHALLENGE and CHALLENGEVERIFICATION on a topic until the expiry.
+// it's carried in message["delegation"] or added locally to the validator Delegations
+//
+//    {
+//      type: "delegation",
+//      topic: <the pubsub topic, the subplebbit address>,
+//      publicKey: <the delegated ed25519 public key>,
+//      expiry: <unix seconds>,
+//      signature: <the owner signature of type, topic, publicKey and expiry>
+//    }
+//
+// the signed type keeps another message signed by the owner from being used as a certificate
+var delegationSignedPropertyNames = []string{"type", "topic", "publicKey", "expiry"}
+
+const delegationType = "delegation"
+
+// the owner signed certificate, set it to message["delegation"] or add its EncodeMessage to Delegations
+func CreateDelegation(ownerPrivateKey []byte, topic string, delegatedPublicKey []byte, expiry time.Time) map[string]interface{} {
+    delegation := map[string]interface{}{
+        "type": delegationType,
+        "topic": topic,
+        "publicKey": delegatedPublicKey,
+        "expiry": uint64(expiry.Unix()),
+    }
+    SignMessage(delegation, ownerPrivateKey, delegationSignedPropertyNames)
+    return delegation
+}
+
+type delegation struct {
+    ownerPeerId peer.ID
+    topic string
+    publicKey []byte
+    expiry time.Time
+}
+
+// verify the owner signature of a delegation certificate, the cbor decoded certificate
+// is a map[string]interface{} at the root of the message or a map[interface{}]interface{} inside it
+func parseDelegation(value interface{}) (delegation, error) {
+    certificate, ok := value.(map[string]interface{})
+    if (!ok) {
+        _certificate, ok := value.(map[interface{}]interface{})
+        if (!ok) {
+            return delegation{}, errors.New("failed convert delegation to map")
+        }
+        certificate = map[string]interface{}{}
+        for key, element := range _certificate {
+            keyString, ok := key.(string)
+            if (!ok) {
+                return delegation{}, errors.New("failed convert delegation key to string")
+            }
+            certificate[keyString] = element
+        }
+    }
+    if (certificate["type"] != delegationType) {
+        return delegation{}, fmt.Errorf("invalid delegation type %v instead of %v", certificate["type"], delegationType)
+    }
+    topic, ok := certificate["topic"].(string)
+    if (!ok) {
+        return delegation{}, errors.New("failed convert delegation.topic to string")
+    }
+    publicKey, ok := certificate["publicKey"].([]byte)
+    if (!ok) {
+        return delegation{}, errors.New("failed convert delegation.publicKey to []byte")
+    }
+    expiry, ok := certificate["expiry"].(uint64)
+    if (!ok) {
+        return delegation{}, errors.New("failed convert delegation.expiry to uint64")
+    }
+    signature, err := toSignature(certificate["signature"])
+    if (err != nil) {
+        return delegation{}, fmt.Errorf("invalid delegation signature, %w", err)
+    }
+    signedPropertyNames := map[string]bool{}
+    for _, propertyName := range signature.signedPropertyNames {
+        signedPropertyNames[propertyName] = true
+    }
+    for _, propertyName := range delegationSignedPropertyNames {
+        if (!signedPropertyNames[propertyName]) {
+            return delegation{}, fmt.Errorf("invalid delegation signature, %v is not in signature.signedPropertyNames", propertyName)
+        }
+    }
+    err = validateSignature(certificate, signature)
+    if (err != nil) {
+        return delegation{}, fmt.Errorf("invalid delegation signature, %w", err)
+    }
+    ownerPeerId, err := getPeerIdFromPublicKey(signature.publicKey)
+    if (err != nil) {
+        return delegation{}, fmt.Errorf("invalid delegation signature, failed getPeerIdFromPublicKey(signature.publicKey): %w", err)
+    }
+    return delegation{ownerPeerId, topic, publicKey, time.Unix(int64(expiry), 0)}, nil
+}
+
+// the reason the delegation doesn't authorize the signer to sign for the owner
+func (delegation delegation) authorizes(topic string, ownerPeerId peer.ID, signerPublicKey []byte, now time.Time) error {
+    if (delegation.ownerPeerId != ownerPeerId) {
+        return fmt.Errorf("delegation is signed by %v instead of %v", delegation.ownerPeerId, ownerPeerId)
+    }
+    if (delegation.topic != topic) {
+        return fmt.Errorf("delegation topic is %v instead of %v", delegation.topic, topic)
+    }
+    if (!bytes.Equal(delegation.publicKey, signerPublicKey)) {
+        return errors.New("delegation public key is not signature.publicKey")
+    }
+    if (!now.Before(delegation.expiry)) {
+        return fmt.Errorf("delegation expired at %v", delegation.expiry.UTC().Format(time.RFC3339))
+    }
+    return nil
+}
+
+// the locally added delegations and the revoked delegated keys, can be updated at runtime
+type Delegations struct {
+    mutex *sync.RWMutex
+    delegations map[peer.ID][]delegation
+    // the revoked keys can't sign with a delegation in the message either, e.g. a leaked hot key
+    revoked map[string]bool
+}
+
+func NewDelegations() *Delegations {
+    return &Delegations{
+        mutex: &sync.RWMutex{},
+        delegations: map[peer.ID][]delegation{},
+        revoked: map[string]bool{},
+    }
+}
+
+// use local delegations, by default there are none. the delegations in the messages are accepted
+// unless WithLocalDelegationsOnly, and the revocations are local to this validator, a revoked key
+// is still forwarded by the peers that didn't revoke it until its delegation expires. nil is ignored,
+// the validator keeps its empty delegations
+func WithDelegations(delegations *Delegations) ValidatorOption {
+    return func(validator *Validator) {
+        if (delegations != nil) {
+            validator.delegations = delegations
+        }
+    }
+}
+
+// only accept the local delegations, the delegations in the messages are ignored,
+// e.g. for a node run by the subplebbit owner that knows all its delegated keys
+func WithLocalDelegationsOnly() ValidatorOption {
+    return func(validator *Validator) {
+        validator.localDelegationsOnly = true
+    }
+}
+
+// add a cbor encoded delegation certificate
+func (delegations *Delegations) Add(encodedCertificate []byte) error {
+    certificate, err := cborDecode(encodedCertificate)
+    if (err != nil) {
+        return fmt.Errorf("invalid delegation, failed cbor decode: %w", err)
+    }
+    delegation, err := parseDelegation(certificate)
+    if (err != nil) {
+        return err
+    }
+    delegations.mutex.Lock()
+    defer delegations.mutex.Unlock()
+    delegations.delegations[delegation.ownerPeerId] = append(delegations.delegations[delegation.ownerPeerId], delegation)
+    return nil
+}
+
+// remove the delegations of the public key and reject it in the message delegations too
+func (delegations *Delegations) Revoke(publicKey []byte) {
+    delegations.mutex.Lock()
+    defer delegations.mutex.Unlock()
+    delegations.revoked[string(publicKey)] = true
+    for ownerPeerId, ownerDelegations := range delegations.delegations {
+        kept := []delegation{}
+        for _, delegation := range ownerDelegations {
+            if (!bytes.Equal(delegation.publicKey, publicKey)) {
+                kept = append(kept, delegation)
+            }
+        }
+        delegations.delegations[ownerPeerId] = kept
+    }
+}
+
+func (delegations *Delegations) IsRevoked(publicKey []byte) bool {
+    delegations.mutex.RLock()
+    defer delegations.mutex.RUnlock()
+    return delegations.revoked[string(publicKey)]
+}
+
+func (delegations *Delegations) isDelegated(topic string, ownerPeerId peer.ID, signerPublicKey []byte, now time.Time) bool {
+    delegations.mutex.RLock()
+    defer delegations.mutex.RUnlock()
+    for _, delegation := range delegations.delegations[ownerPeerId] {
+        if (delegation.authorizes(topic, ownerPeerId, signerPublicKey, now) == nil) {
+            return true
+        }
+    }
+    return false
+}
+
+// the validator delegations can be updated at runtime, e.g. validator.Delegations().Revoke(publicKey)
+func (validator Validator) Delegations() *Delegations {
+    return validator.delegations
+}
+
+// the reason the signer of the message isn't authorized by the owner with a message or a local delegation
+func validateDelegation(message map[string]interface{}, topic string, ownerPeerId peer.ID, signerPublicKey []byte, validator Validator) error {
+    if (validator.delegations.IsRevoked(signerPublicKey)) {
+        return errors.New("delegated public key is revoked")
+    }
+    if (validator.delegations.isDelegated(topic, ownerPeerId, signerPublicKey, validator.now())) {
+        return nil
+    }
+    if (validator.localDelegationsOnly) {
+        return errors.New("no local delegation")
+    }
+    if (message["delegation"] == nil) {
+        return errors.New("no delegation")
+    }
+    delegation, err := parseDelegation(message["delegation"])
+    if (err != nil) {
+        return err
+    }
+    return delegation.authorizes(topic, ownerPeerId, signerPublicKey, validator.now())
+}
@@ -0,0 +1,143 @@
+package pubsubPlebbitValidator
+
+import (
+    "strings"
+    "testing"
+    "time"
+    pubsub "github.com/libp2p/go-libp2p-pubsub"
+    peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestDelegationInMessage(t *testing.T) {
+    mockHost, peerId := newTestHost()
+    validator := NewValidator(mockHost)
+    topic := subplebbitPeerId.String()
+    hotPrivateKey := tryGeneratePrivateKey()
+    hotPublicKey := getPublicKeyFromPrivateKey(hotPrivateKey)
+    expiry := time.Now().Add(time.Hour)
+
+    validDelegation := CreateDelegation(subplebbitPrivateKey, topic, hotPublicKey, expiry)
+    for _, messageType := range []string{"CHALLENGE", "CHALLENGEVERIFICATION"} {
+        result := validateMessage(validator, peerId, topic, createSignedMessage(messageType, hotPrivateKey, map[string]interface{}{"delegation": validDelegation}))
+        if (result != pubsub.ValidationAccept) {
+            t.Fatalf(`delegated %v validation result is "%v" instead of "%v"`, messageType, result, pubsub.ValidationAccept)
+        }
+    }
+
+    tamperedDelegation := CreateDelegation(subplebbitPrivateKey, topic, hotPublicKey, expiry)
+    tamperedDelegation["expiry"] = uint64(expiry.Add(365 * 24 * time.Hour).Unix())
+    unsignedExpiryDelegation := map[string]interface{}{"type": "delegation", "topic": topic, "publicKey": hotPublicKey, "expiry": uint64(expiry.Unix())}
+    SignMessage(unsignedExpiryDelegation, subplebbitPrivateKey, []string{"type", "topic", "publicKey"})
+    // another message signed by the owner with the same properties can't be used as a certificate
+    untypedDelegation := map[string]interface{}{"topic": topic, "publicKey": hotPublicKey, "expiry": uint64(expiry.Unix())}
+    SignMessage(untypedDelegation, subplebbitPrivateKey, []string{"topic", "publicKey", "expiry"})
+    // the mismatch reasons
+    invalid := map[string]struct{delegation interface{}; reason string}{
+        "no delegation": {nil, "no delegation"},
+        "expired": {CreateDelegation(subplebbitPrivateKey, topic, hotPublicKey, time.Now().Add(-time.Minute)), "delegation expired"},
+        "other owner": {CreateDelegation(tryGeneratePrivateKey(), topic, hotPublicKey, expiry), "delegation is signed by"},
+        "other public key": {CreateDelegation(subplebbitPrivateKey, topic, getPublicKeyFromPrivateKey(tryGeneratePrivateKey()), expiry), "delegation public key is not signature.publicKey"},
+        "tampered expiry": {tamperedDelegation, "invalid delegation signature"},
+        "unsigned expiry": {unsignedExpiryDelegation, "expiry is not in signature.signedPropertyNames"},
+        "no type": {untypedDelegation, "invalid delegation type"},
+        "other topic": {CreateDelegation(subplebbitPrivateKey, "memes.eth", hotPublicKey, expiry), "delegation topic is memes.eth"},
+        "invalid": {map[string]interface{}{"type": "delegation", "topic": topic, "publicKey": "not bytes"}, "failed convert delegation.publicKey"},
+    }
+    for name, test := range invalid {
+        encodedMessage := createSignedMessage("CHALLENGE", hotPrivateKey, map[string]interface{}{"delegation": test.delegation})
+        result := validateMessage(validator, peerId, topic, encodedMessage)
+        if (result != pubsub.ValidationReject) {
+            t.Fatalf(`%v delegation validation result is "%v" instead of "%v"`, name, result, pubsub.ValidationReject)
+        }
+        for _, checkResult := range validator.Check(topic, encodedMessage) {
+            if (checkResult.Name == "topic" && (checkResult.Err == nil || !strings.Contains(checkResult.Err.Error(), test.reason))) {
+                t.Fatalf(`%v delegation error is "%v" instead of "%v"`, name, checkResult.Err, test.reason)
+            }
+        }
+    }
+
+    // a delegation of the owner of a subplebbit address
+    validator = NewValidator(mockHost, WithAddressResolver(NewStaticAddressResolver(map[string]peer.ID{"memes.eth": subplebbitPeerId})))
+    result := validateMessage(validator, peerId, "memes.eth", createSignedMessage("CHALLENGE", hotPrivateKey, map[string]interface{}{"delegation": CreateDelegation(subplebbitPrivateKey, "memes.eth", hotPublicKey, expiry)}))
+    if (result != pubsub.ValidationAccept) {
+        t.Fatalf(`delegated message on address topic validation result is "%v" instead of "%v"`, result, pubsub.ValidationAccept)
+    }
+}
+
+func TestLocalDelegations(t *testing.T) {
+    mockHost, peerId := newTestHost()
+    delegations := NewDelegations()
+    validator := NewValidator(mockHost, WithDelegations(delegations))
+    topic := subplebbitPeerId.String()
+    hotPrivateKey := tryGeneratePrivateKey()
+    hotPublicKey := getPublicKeyFromPrivateKey(hotPrivateKey)
+
+    result := validateMessage(validator, peerId, topic, createSignedMessage("CHALLENGE", hotPrivateKey, nil))
+    if (result != pubsub.ValidationReject) {
+        t.Fatalf(`not delegated validation result is "%v" instead of "%v"`, result, pubsub.ValidationReject)
+    }
+
+    // the messages don't need to carry the locally added delegation
+    err := validator.Delegations().Add(EncodeMessage(CreateDelegation(subplebbitPrivateKey, topic, hotPublicKey, time.Now().Add(time.Hour))))
+    if (err != nil) {
+        t.Fatalf(`add delegation error is "%v" instead of "<nil>"`, err)
+    }
+    result = validateMessage(validator, peerId, topic, createSignedMessage("CHALLENGE", hotPrivateKey, nil))
+    if (result != pubsub.ValidationAccept) {
+        t.Fatalf(`locally delegated validation result is "%v" instead of "%v"`, result, pubsub.ValidationAccept)
+    }
+
+    // the local delegations are verified
+    tamperedDelegation := CreateDelegation(subplebbitPrivateKey, topic, hotPublicKey, time.Now().Add(time.Hour))
+    tamperedDelegation["publicKey"] = getPublicKeyFromPrivateKey(tryGeneratePrivateKey())
+    err = delegations.Add(EncodeMessage(tamperedDelegation))
+    if (err == nil) {
+        t.Fatalf(`add tampered delegation error is "<nil>"`)
+    }
+    err = delegations.Add([]byte("invalid"))
+    if (err == nil) {
+        t.Fatalf(`add invalid delegation error is "<nil>"`)
+    }
+
+    // a revoked key can't use a delegation in the message either
+    delegations.Revoke(hotPublicKey)
+    if (!delegations.IsRevoked(hotPublicKey)) {
+        t.Fatalf(`revoked key is not revoked`)
+    }
+    result = validateMessage(validator, peerId, topic, createSignedMessage("CHALLENGE", hotPrivateKey, map[string]interface{}{"delegation": CreateDelegation(subplebbitPrivateKey, topic, hotPublicKey, time.Now().Add(time.Hour))}))
+    if (result != pubsub.ValidationReject) {
+        t.Fatalf(`revoked key validation result is "%v" instead of "%v"`, result, pubsub.ValidationReject)
+    }
+
+    // the owner key still signs
+    result = validateMessage(validator, peerId, topic, createSignedMessage("CHALLENGE", subplebbitPrivateKey, nil))
+    if (result != pubsub.ValidationAccept) {
+        t.Fatalf(`owner validation result is "%v" instead of "%v"`, result, pubsub.ValidationAccept)
+    }
+
+    // nil delegations are ignored
+    validator = NewValidator(mockHost, WithDelegations(nil))
+    result = validateMessage(validator, peerId, topic, createSignedMessage("CHALLENGE", hotPrivateKey, nil))
+    if (validator.Delegations() == nil || result != pubsub.ValidationReject) {
+        t.Fatalf(`nil delegations validation result is "%v" instead of "%v"`, result, pubsub.ValidationReject)
+    }
+}
+
+func TestLocalDelegationsOnly(t *testing.T) {
+    mockHost, peerId := newTestHost()
+    validator := NewValidator(mockHost, WithLocalDelegationsOnly())
+    topic := subplebbitPeerId.String()
+    hotPrivateKey := tryGeneratePrivateKey()
+    delegation := CreateDelegation(subplebbitPrivateKey, topic, getPublicKeyFromPrivateKey(hotPrivateKey), time.Now().Add(time.Hour))
+
+    // the delegation in the message is ignored
+    result := validateMessage(validator, peerId, topic, createSignedMessage("CHALLENGE", hotPrivateKey, map[string]interface{}{"delegation": delegation}))
+    if (result != pubsub.ValidationReject) {
+        t.Fatalf(`message delegation validation result is "%v" instead of "%v"`, result, pubsub.ValidationReject)
+    }
+    validator.Delegations().Add(EncodeMessage(delegation))
+    result = validateMessage(validator, peerId, topic, createSignedMessage("CHALLENGE", hotPrivateKey, nil))
+    if (result != pubsub.ValidationAccept) {
+        t.Fatalf(`local delegation validation result is "%v" instead of "%v"`, result, pubsub.ValidationAccept)
+    }
+}
@@ -0,0 +1,86 @@
+package pubsubPlebbitValidator
+
+import (
+    "errors"
+    "fmt"
+)
+
+// the encrypted fields of plebbit messages are envelopes of the ciphertext, the AES-GCM iv and tag,
+// and the encryption type, the validator can't decrypt them but can reject malformed envelopes
+const envelopeType = "ed25519-aes-gcm"
+const envelopeIvLength = 12
+const envelopeTagLength = 16
+
+var envelopeFieldNames = []string{"encryptedPublication", "encryptedChallenges", "encryptedChallengeAnswers"}
+
+// the envelope each message type must have, the CHALLENGEVERIFICATION only has an
+// encryptedPublication if the challenge succeeded
+var requiredEnvelopeFieldNames = map[string]string{
+    "CHALLENGEREQUEST": "encryptedPublication",
+    "CHALLENGE": "encryptedChallenges",
+    "CHALLENGEANSWER": "encryptedChallengeAnswers",
+}
+
+func validateEnvelope(value interface{}) error {
+    envelope, ok := value.(map[string]interface{})
+    if (!ok) {
+        _envelope, ok := value.(map[interface{}]interface{})
+        if (!ok) {
+            return errors.New("failed convert to map")
+        }
+        envelope = map[string]interface{}{}
+        for key, element := range _envelope {
+            keyString, ok := key.(string)
+            if (!ok) {
+                return errors.New("failed convert key to string")
+            }
+            envelope[keyString] = element
+        }
+    }
+    ciphertext, ok := envelope["ciphertext"].([]byte)
+    if (!ok) {
+        return errors.New("failed convert ciphertext to []byte")
+    }
+    if (len(ciphertext) == 0) {
+        return errors.New("ciphertext is empty")
+    }
+    iv, ok := envelope["iv"].([]byte)
+    if (!ok) {
+        return errors.New("failed convert iv to []byte")
+    }
+    if (len(iv) != envelopeIvLength) {
+        return fmt.Errorf("iv length is %v instead of %v", len(iv), envelopeIvLength)
+    }
+    tag, ok := envelope["tag"].([]byte)
+    if (!ok) {
+        return errors.New("failed convert tag to []byte")
+    }
+    if (len(tag) != envelopeTagLength) {
+        return fmt.Errorf("tag length is %v instead of %v", len(tag), envelopeTagLength)
+    }
+    encryptionType, ok := envelope["type"].(string)
+    if (!ok) {
+        return errors.New("failed convert type to string")
+    }
+    if (encryptionType != envelopeType) {
+        return fmt.Errorf("type %q is not supported", encryptionType)
+    }
+    return nil
+}
+
+func validateEnvelopes(fields pubsubMessageFields) error {
+    requiredFieldName := requiredEnvelopeFieldNames[fields.messageType]
+    if (requiredFieldName != "" && fields.message[requiredFieldName] == nil) {
+        return fmt.Errorf("invalid message.%v, %v has no %v", requiredFieldName, fields.messageType, requiredFieldName)
+    }
+    for _, fieldName := range envelopeFieldNames {
+        if (fields.message[fieldName] == nil) {
+            continue
+        }
+        err := validateEnvelope(fields.message[fieldName])
+        if (err != nil) {
+            return fmt.Errorf("invalid message.%v, %w", fieldName, err)
+        }
+    }
+    return nil
+}
@@ -0,0 +1,94 @@
+package pubsubPlebbitValidator
+
+import (
+    "strings"
+    "testing"
+    pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+func TestValidateEnvelope(t *testing.T) {
+    err := validateEnvelope(createTestEnvelope([]byte{1}))
+    if (err != nil) {
+        t.Fatalf(`valid envelope error is "%v" instead of "<nil>"`, err)
+    }
+    // the envelopes inside a decoded message are map[interface{}]interface{}
+    decoded, _ := cborDecode(cborEncode(map[string]interface{}{"encryptedPublication": createTestEnvelope([]byte{1})}))
+    err = validateEnvelope(decoded["encryptedPublication"])
+    if (err != nil) {
+        t.Fatalf(`decoded envelope error is "%v" instead of "<nil>"`, err)
+    }
+
+    invalid := map[string]struct{property string; value interface{}; reason string}{
+        "no ciphertext": {"ciphertext", nil, "failed convert ciphertext to []byte"},
+        "empty ciphertext": {"ciphertext", []byte{}, "ciphertext is empty"},
+        "string ciphertext": {"ciphertext", "ciphertext", "failed convert ciphertext to []byte"},
+        "no iv": {"iv", nil, "failed convert iv to []byte"},
+        "short iv": {"iv", make([]byte, 11), "iv length is 11 instead of 12"},
+        "long iv": {"iv", make([]byte, 13), "iv length is 13 instead of 12"},
+        "no tag": {"tag", nil, "failed convert tag to []byte"},
+        "short tag": {"tag", make([]byte, 15), "tag length is 15 instead of 16"},
+        "long tag": {"tag", make([]byte, 17), "tag length is 17 instead of 16"},
+        "no type": {"type", nil, "failed convert type to string"},
+        "unsupported type": {"type", "aes-cbc", `type "aes-cbc" is not supported`},
+    }
+    for name, test := range invalid {
+        envelope := createTestEnvelope([]byte{1})
+        envelope[test.property] = test.value
+        err := validateEnvelope(envelope)
+        if (err == nil || !strings.Contains(err.Error(), test.reason)) {
+            t.Fatalf(`%v envelope error is "%v" instead of "%v"`, name, err, test.reason)
+        }
+    }
+    err = validateEnvelope(map[string]interface{}{})
+    if (err == nil) {
+        t.Fatalf(`empty envelope error is "<nil>"`)
+    }
+    err = validateEnvelope([]byte{1})
+    if (err == nil) {
+        t.Fatalf(`bytes envelope error is "<nil>"`)
+    }
+}
+
+func TestValidateEnvelopes(t *testing.T) {
+    mockHost, peerId := newTestHost()
+    validator := NewValidator(mockHost)
+    topic := subplebbitPeerId.String()
+
+    // the empty envelope the tests used to send
+    encodedMessage := createSignedMessage("CHALLENGEREQUEST", tryGeneratePrivateKey(), map[string]interface{}{"encryptedPublication": map[string]interface{}{}})
+    result := validateMessage(validator, peerId, topic, encodedMessage)
+    if (result != pubsub.ValidationReject) {
+        t.Fatalf(`empty envelope validation result is "%v" instead of "%v"`, result, pubsub.ValidationReject)
+    }
+    failed, _ := getFailedChecks(validator.Check(topic, encodedMessage))
+    if (len(failed) != 1 || failed[0] != "envelopes") {
+        t.Fatalf(`failed checks are "%v" instead of "[envelopes]"`, failed)
+    }
+
+    // the required envelope of each type
+    requiredEnvelopes := map[string]string{"CHALLENGEREQUEST": "encryptedPublication", "CHALLENGE": "encryptedChallenges", "CHALLENGEANSWER": "encryptedChallengeAnswers"}
+    for messageType, fieldName := range requiredEnvelopes {
+        fields, _ := decodePubsubMessage(topic, createSignedMessage(messageType, subplebbitPrivateKey, nil))
+        err := validateEnvelopes(fields)
+        if (err != nil) {
+            t.Fatalf(`%v envelopes error is "%v" instead of "<nil>"`, messageType, err)
+        }
+        fields, _ = decodePubsubMessage(topic, createSignedMessage(messageType, subplebbitPrivateKey, map[string]interface{}{"encryptedPublication": nil, fieldName: nil}))
+        err = validateEnvelopes(fields)
+        if (err == nil || !strings.Contains(err.Error(), messageType + " has no " + fieldName)) {
+            t.Fatalf(`%v without %v error is "%v"`, messageType, fieldName, err)
+        }
+    }
+
+    // a failed challenge verification has no publication, but a malformed one is rejected
+    fields, _ := decodePubsubMessage(topic, createSignedMessage("CHALLENGEVERIFICATION", subplebbitPrivateKey, map[string]interface{}{"encryptedPublication": nil}))
+    err := validateEnvelopes(fields)
+    if (err != nil) {
+        t.Fatalf(`verification without publication error is "%v" instead of "<nil>"`, err)
+    }
+    fields, _ = decodePubsubMessage(topic, createSignedMessage("CHALLENGEVERIFICATION", subplebbitPrivateKey, map[string]interface{}{"encryptedPublication": map[string]interface{}{"ciphertext": []byte{1}}}))
+    err = validateEnvelopes(fields)
+    if (err == nil || !strings.Contains(err.Error(), "invalid message.encryptedPublication, failed convert iv")) {
+        t.Fatalf(`verification with malformed publication error is "%v"`, err)
+    }
+}
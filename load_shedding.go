@@ -0,0 +1,157 @@
+package pubsubPlebbitValidator
+
+import (
+    "math"
+    "sync"
+    "sync/atomic"
+    "time"
+    lru "github.com/hashicorp/golang-lru/v2"
+    peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+type LoadSheddingConfig struct {
+    // the accepted challenge requests per second on a topic above which the topic is overloaded, 0 doesn't limit the rate
+    TargetRate float64
+    // the half life of the observed rate, how fast the shedding stops after the load
+    RateHalfLife time.Duration
+    // the concurrent Validate calls above which all the topics are overloaded, 0 doesn't limit them
+    MaxInFlight int
+    // the completed challenges a publisher needs to keep full priority, with a completion ratio of 1
+    PriorityCompletedChallengeCount float64
+}
+
+var DefaultLoadSheddingConfig = LoadSheddingConfig{
+    TargetRate: 10,
+    RateHalfLife: time.Minute,
+    MaxInFlight: 1000,
+    PriorityCompletedChallengeCount: 10,
+}
+
+// when a topic is overloaded, the challenge requests of the publishers with the lowest priority are ignored.
+// a load of 2, twice the target rate or the max in flight, ignores the publishers under half priority, so
+// unknown publishers are ignored first and publishers that complete their challenges last. it's safe for
+// concurrent use
+type LoadShedding struct {
+    mutex *sync.Mutex
+    config LoadSheddingConfig
+    topicLoads *lru.Cache[string, *topicLoad]
+    inFlight *int64
+    // the clock of the validator, see WithClock
+    now func() time.Time
+}
+
+func NewLoadShedding(config LoadSheddingConfig) *LoadShedding {
+    topicLoads, err := lru.New[string, *topicLoad](topicLoadsSize)
+    if (err != nil) {
+        panic(err)
+    }
+    return &LoadShedding{
+        mutex: &sync.Mutex{},
+        config: config,
+        topicLoads: topicLoads,
+        inFlight: new(int64),
+        now: time.Now,
+    }
+}
+
+// ignore the challenge requests of the low priority publishers when a topic is overloaded
+func WithLoadShedding(loadShedding *LoadShedding) ValidatorOption {
+    return func(validator *Validator) {
+        validator.loadShedding = loadShedding
+    }
+}
+
+// nil without WithLoadShedding
+func (validator Validator) LoadShedding() *LoadShedding {
+    return validator.loadShedding
+}
+
+func (loadShedding *LoadShedding) Config() LoadSheddingConfig {
+    return loadShedding.config
+}
+
+// the number of Validate calls in progress, the validation queue depth
+func (loadShedding *LoadShedding) InFlight() int {
+    return int(atomic.LoadInt64(loadShedding.inFlight))
+}
+
+func (loadShedding *LoadShedding) Load(topic string) float64 {
+    return loadShedding.getLoad(topic, loadShedding.now())
+}
+
+// the loads of the topics with an observed rate
+func (loadShedding *LoadShedding) Loads() map[string]float64 {
+    loads := map[string]float64{}
+    now := loadShedding.now()
+    for _, topic := range loadShedding.topicLoads.Keys() {
+        loads[topic] = loadShedding.getLoad(topic, now)
+    }
+    return loads
+}
+
+// the highest of the accepted rate over the target rate and the in flight over the max in flight, over 1 is overloaded
+func (loadShedding *LoadShedding) getLoad(topic string, now time.Time) float64 {
+    config := loadShedding.config
+    load := 0.0
+    if (config.MaxInFlight > 0) {
+        load = float64(loadShedding.InFlight()) / float64(config.MaxInFlight)
+    }
+    if (config.TargetRate <= 0 || config.RateHalfLife <= 0) {
+        return load
+    }
+    loadShedding.mutex.Lock()
+    defer loadShedding.mutex.Unlock()
+    topicLoad, ok := loadShedding.topicLoads.Peek(topic)
+    if (!ok) {
+        return load
+    }
+    decayTopicLoad(topicLoad, now, config.RateHalfLife)
+    // the rate of a count decaying with the half life
+    rate := topicLoad.count * math.Ln2 / config.RateHalfLife.Seconds()
+    return math.Max(load, rate / config.TargetRate)
+}
+
+// count an accepted challenge request in the rate of the topic
+func (loadShedding *LoadShedding) observe(topic string, now time.Time) {
+    loadShedding.mutex.Lock()
+    defer loadShedding.mutex.Unlock()
+    load, ok := loadShedding.topicLoads.Get(topic)
+    if (!ok) {
+        load = &topicLoad{updated: now}
+        loadShedding.topicLoads.Add(topic, load)
+    }
+    decayTopicLoad(load, now, loadShedding.config.RateHalfLife)
+    load.count++
+}
+
+// from 0 for an unknown publisher or a publisher that never completes its challenges,
+// to 1 for a trusted publisher or a publisher with enough completed challenges
+func getPublisherPriority(publisherId peer.ID, validator Validator) float64 {
+    if (validator.trustedPeers[publisherId]) {
+        return 1
+    }
+    peerStatistics := getPeerStatistics(publisherId, validator)
+    if (peerStatistics.challengeCount == 0) {
+        return 0
+    }
+    completedRatio := math.Min(1, peerStatistics.completedChallengeCount / peerStatistics.challengeCount)
+    history := 1.0
+    if (validator.loadShedding.config.PriorityCompletedChallengeCount > 0) {
+        history = math.Min(1, peerStatistics.completedChallengeCount / validator.loadShedding.config.PriorityCompletedChallengeCount)
+    }
+    return completedRatio * history
+}
+
+// true if the challenge request should be ignored because the topic is overloaded
+func shedChallengeRequest(fields pubsubMessageFields, publisherId peer.ID, validator Validator) bool {
+    if (validator.loadShedding == nil || fields.messageType != "CHALLENGEREQUEST") {
+        return false
+    }
+    load := validator.loadShedding.getLoad(fields.topic, validator.now())
+    if (load <= 1) {
+        return false
+    }
+    // the fraction of the requests to ignore to get back to the target
+    shedFraction := 1 - 1 / load
+    return getPublisherPriority(publisherId, validator) < shedFraction
+}
@@ -0,0 +1,177 @@
+package pubsubPlebbitValidator
+
+import (
+    "testing"
+    "time"
+    pubsub "github.com/libp2p/go-libp2p-pubsub"
+    peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// the rate of a count c decaying with a 1 minute half life is c×ln2/60 per second
+func observeLoad(loadShedding *LoadShedding, topic string, count int, now time.Time) {
+    for i := 0; i < count; i++ {
+        loadShedding.observe(topic, now)
+    }
+}
+
+func TestLoadSheddingPriority(t *testing.T) {
+    mockHost := newMockHost()
+    unknownPeerId := mockHost.addPeer("/ip4/1.1.1.1/tcp/4001")
+    mediocrePeerId := mockHost.addPeer("/ip4/2.2.2.2/tcp/4001")
+    goodPeerId := mockHost.addPeer("/ip4/3.3.3.3/tcp/4001")
+    newPeerId := mockHost.addPeer("/ip4/4.4.4.4/tcp/4001")
+    trustedPeerId := mockHost.addPeer("/ip4/5.5.5.5/tcp/4001")
+    loadShedding := NewLoadShedding(LoadSheddingConfig{TargetRate: 1, RateHalfLife: time.Minute, PriorityCompletedChallengeCount: 10})
+    testClock := newTestClock()
+    // the clock is advanced past the timestamp of the messages
+    validator := NewValidator(mockHost, WithLoadShedding(loadShedding), WithTrustedPeers(trustedPeerId), WithClock(testClock.now), WithoutTimestamp())
+    topic := "topic"
+
+    // completes half its challenges
+    validator.peersStatistics.Add(string(mediocrePeerId), &PeerStatistics{challengeCount: 20, completedChallengeCount: 10, lastDecay: testClock.now()})
+    // completes all its challenges
+    validator.peersStatistics.Add(string(goodPeerId), &PeerStatistics{challengeCount: 20, completedChallengeCount: 20, lastDecay: testClock.now()})
+    // completed its only challenge, not enough history for full priority
+    validator.peersStatistics.Add(string(newPeerId), &PeerStatistics{challengeCount: 1, completedChallengeCount: 1, lastDecay: testClock.now()})
+
+    priorities := map[peer.ID]float64{unknownPeerId: 0, mediocrePeerId: 0.5, goodPeerId: 1, newPeerId: 0.1, trustedPeerId: 1}
+    for peerId, expected := range priorities {
+        priority := getPublisherPriority(peerId, validator)
+        if (priority != expected) {
+            t.Fatalf(`peer %v priority is "%v" instead of "%v"`, peerId, priority, expected)
+        }
+    }
+
+    expectResults := func(name string, expected map[peer.ID]pubsub.ValidationResult) {
+        for peerId, expectedResult := range expected {
+            result := validateMessage(validator, peerId, topic, createSignedMessage("CHALLENGEREQUEST", tryGeneratePrivateKey(), nil))
+            if (result != expectedResult) {
+                t.Fatalf(`%v peer %v validation result is "%v" instead of "%v"`, name, peerId, result, expectedResult)
+            }
+        }
+    }
+
+    // under the target rate all the requests are accepted
+    expectResults("not overloaded", map[peer.ID]pubsub.ValidationResult{unknownPeerId: pubsub.ValidationAccept, newPeerId: pubsub.ValidationAccept})
+
+    // 1.5 times the target rate ignores the publishers under a third of priority
+    observeLoad(loadShedding, topic, 130, testClock.now())
+    unknownChallengeCount := getPeerStatistics(unknownPeerId, validator).challengeCount
+    expectResults("1.5 load", map[peer.ID]pubsub.ValidationResult{
+        unknownPeerId: pubsub.ValidationIgnore,
+        newPeerId: pubsub.ValidationIgnore,
+        mediocrePeerId: pubsub.ValidationAccept,
+        goodPeerId: pubsub.ValidationAccept,
+    })
+    // the ignored request is not counted against the publisher
+    if (getPeerStatistics(unknownPeerId, validator).challengeCount != unknownChallengeCount) {
+        t.Fatalf(`ignored publisher challenge count is "%v" instead of "%v"`, getPeerStatistics(unknownPeerId, validator).challengeCount, unknownChallengeCount)
+    }
+
+    // 4 times the target rate only keeps the publishers over three quarters of priority
+    observeLoad(loadShedding, topic, 220, testClock.now())
+    expectResults("4 load", map[peer.ID]pubsub.ValidationResult{
+        mediocrePeerId: pubsub.ValidationIgnore,
+        goodPeerId: pubsub.ValidationAccept,
+        trustedPeerId: pubsub.ValidationAccept,
+    })
+
+    // only the challenge requests are shed
+    result := validateMessage(validator, unknownPeerId, subplebbitPeerId.String(), createSignedMessage("CHALLENGE", subplebbitPrivateKey, nil))
+    if (result != pubsub.ValidationAccept) {
+        t.Fatalf(`challenge validation result is "%v" instead of "%v"`, result, pubsub.ValidationAccept)
+    }
+    // other topics are not overloaded
+    result = validateMessage(validator, unknownPeerId, "other topic", createSignedMessage("CHALLENGEREQUEST", tryGeneratePrivateKey(), nil))
+    if (result != pubsub.ValidationAccept) {
+        t.Fatalf(`other topic validation result is "%v" instead of "%v"`, result, pubsub.ValidationAccept)
+    }
+
+    // the load decays
+    testClock.advance(30 * time.Minute)
+    expectResults("after the load", map[peer.ID]pubsub.ValidationResult{unknownPeerId: pubsub.ValidationAccept})
+}
+
+func TestLoadSheddingInFlight(t *testing.T) {
+    mockHost := newMockHost()
+    unknownPeerId := mockHost.addPeer("/ip4/1.1.1.1/tcp/4001")
+    goodPeerId := mockHost.addPeer("/ip4/3.3.3.3/tcp/4001")
+    loadShedding := NewLoadShedding(LoadSheddingConfig{MaxInFlight: 2, PriorityCompletedChallengeCount: 10})
+    validator := NewValidator(mockHost, WithLoadShedding(loadShedding))
+    validator.peersStatistics.Add(string(goodPeerId), &PeerStatistics{challengeCount: 20, completedChallengeCount: 20, lastDecay: time.Now()})
+
+    result := validateMessage(validator, unknownPeerId, "topic", createSignedMessage("CHALLENGEREQUEST", tryGeneratePrivateKey(), nil))
+    if (result != pubsub.ValidationAccept) {
+        t.Fatalf(`validation result is "%v" instead of "%v"`, result, pubsub.ValidationAccept)
+    }
+    if (loadShedding.InFlight() != 0) {
+        t.Fatalf(`in flight is "%v" instead of "0"`, loadShedding.InFlight())
+    }
+
+    // 3 validations in progress, the 4th one is twice the max in flight
+    *loadShedding.inFlight = 3
+    result = validateMessage(validator, unknownPeerId, "topic", createSignedMessage("CHALLENGEREQUEST", tryGeneratePrivateKey(), nil))
+    if (result != pubsub.ValidationIgnore) {
+        t.Fatalf(`unknown peer validation result is "%v" instead of "%v"`, result, pubsub.ValidationIgnore)
+    }
+    result = validateMessage(validator, goodPeerId, "topic", createSignedMessage("CHALLENGEREQUEST", tryGeneratePrivateKey(), nil))
+    if (result != pubsub.ValidationAccept) {
+        t.Fatalf(`good peer validation result is "%v" instead of "%v"`, result, pubsub.ValidationAccept)
+    }
+    if (loadShedding.InFlight() != 3) {
+        t.Fatalf(`in flight is "%v" instead of "3"`, loadShedding.InFlight())
+    }
+    if (loadShedding.Load("any topic") != 1.5) {
+        t.Fatalf(`load is "%v" instead of "1.5"`, loadShedding.Load("any topic"))
+    }
+}
+
+func TestLoadSheddingObservesAcceptedRate(t *testing.T) {
+    mockHost, peerId := newTestHost()
+    loadShedding := NewLoadShedding(LoadSheddingConfig{TargetRate: 0.05, RateHalfLife: time.Minute})
+    testClock := newTestClock()
+    validator := NewValidator(mockHost, WithLoadShedding(loadShedding), WithClock(testClock.now))
+
+    // 5 accepted requests are over 0.05 per second, then the unknown publishers are ignored
+    results := map[pubsub.ValidationResult]int{}
+    for i := 0; i < 10; i++ {
+        results[validateMessage(validator, peerId, "topic", createSignedMessage("CHALLENGEREQUEST", tryGeneratePrivateKey(), nil))]++
+    }
+    if (results[pubsub.ValidationAccept] != 5 || results[pubsub.ValidationIgnore] != 5) {
+        t.Fatalf(`validation results are "%v"`, results)
+    }
+    if (len(loadShedding.Loads()) != 1) {
+        t.Fatalf(`loads are "%v"`, loadShedding.Loads())
+    }
+
+    // the loads use the clock of the validator
+    testClock.advance(30 * time.Minute)
+    if (loadShedding.Load("topic") > 0.01 || loadShedding.Loads()["topic"] > 0.01) {
+        t.Fatalf(`load after 30 minutes is "%v" instead of "0"`, loadShedding.Load("topic"))
+    }
+}
+
+func TestLoadSheddingChallengeExchanges(t *testing.T) {
+    mockHost, peerId := newTestHost()
+    loadShedding := NewLoadShedding(LoadSheddingConfig{TargetRate: 1, RateHalfLife: time.Minute, PriorityCompletedChallengeCount: 10})
+    testClock := newTestClock()
+    validator := NewValidator(mockHost, WithLoadShedding(loadShedding), WithClock(testClock.now), WithoutTimestamp())
+    topic := subplebbitPeerId.String()
+
+    // the history of a publisher that completes all its challenges
+    sendChallengeExchanges(t, validator, peerId, 10)
+    priority := getPublisherPriority(peerId, validator)
+    if (priority < 0.99) {
+        t.Fatalf(`honest publisher priority is "%v" instead of "1"`, priority)
+    }
+
+    // 4 times the target rate still accepts the honest publisher
+    observeLoad(loadShedding, topic, 350, testClock.now())
+    if (loadShedding.Load(topic) <= 2) {
+        t.Fatalf(`load is "%v" instead of over "2"`, loadShedding.Load(topic))
+    }
+    result := validateMessage(validator, peerId, topic, createSignedMessage("CHALLENGEREQUEST", tryGeneratePrivateKey(), nil))
+    if (result != pubsub.ValidationAccept) {
+        t.Fatalf(`honest publisher validation result is "%v" instead of "%v"`, result, pubsub.ValidationAccept)
+    }
+}
@@ -0,0 +1,41 @@
+package pubsubPlebbitValidator
+
+import (
+    peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// helpers to create plebbit pubsub messages, e.g. for tools and simulations
+
+// an ed25519 private key seed, like plebbit-js signers
+func GeneratePrivateKey() ([]byte, error) {
+    return generatePrivateKey()
+}
+
+func GetPublicKeyFromPrivateKey(privateKey []byte) []byte {
+    return getPublicKeyFromPrivateKey(privateKey)
+}
+
+// the peer id of an ed25519 private key, which is the challengeRequestId of the author or the subplebbit address
+func GetPeerIdFromPrivateKey(privateKey []byte) (peer.ID, error) {
+    return getPeerIdFromPrivateKey(privateKey)
+}
+
+// sign the signedPropertyNames of the message with the ed25519 private key and set message["signature"]
+func SignMessage(message map[string]interface{}, privateKey []byte, signedPropertyNames []string) {
+    signature := map[string]interface{}{}
+    bytesToSign := getBytesToSign(message, signedPropertyNames)
+    signature["signature"] = signEd25519(bytesToSign, privateKey)
+    signature["publicKey"] = getPublicKeyFromPrivateKey(privateKey)
+    signature["signedPropertyNames"] = signedPropertyNames
+    signature["type"] = "ed25519"
+    message["signature"] = signature
+}
+
+// the canonical cbor encoding of the message, the pubsub message data
+func EncodeMessage(message map[string]interface{}) []byte {
+    return cborEncode(message)
+}
+
+func DecodeMessage(encoded []byte) (map[string]interface{}, error) {
+    return cborDecode(encoded)
+}
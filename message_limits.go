@@ -0,0 +1,126 @@
+package pubsubPlebbitValidator
+
+import (
+    "fmt"
+    cbor "github.com/fxamacker/cbor/v2"
+)
+
+// the size limits of a message type, a limit of 0 is no limit
+type MessageLimits struct {
+    // the size of the encoded pubsub message data
+    MaxMessageSize int
+    // the cbor encoded size of the fields, e.g. the encrypted blobs
+    MaxFieldSizes map[string]int
+    // the number of acceptedChallengeTypes and the length of each
+    MaxAcceptedChallengeTypes int
+    MaxAcceptedChallengeTypeLength int
+    MaxUserAgentLength int
+}
+
+// gossipsub drops messages over 1 MiB, the limits leave room for the publication in a
+// challenge request and verification, and for an image in a challenge
+var defaultMessageLimits = map[string]MessageLimits{
+    "CHALLENGEREQUEST": {
+        MaxMessageSize: 256 * 1024,
+        MaxFieldSizes: map[string]int{"encryptedPublication": 240 * 1024},
+        MaxAcceptedChallengeTypes: 10,
+        MaxAcceptedChallengeTypeLength: 100,
+        MaxUserAgentLength: 200,
+    },
+    "CHALLENGE": {
+        MaxMessageSize: 128 * 1024,
+        MaxFieldSizes: map[string]int{"encryptedChallenges": 120 * 1024},
+        MaxUserAgentLength: 200,
+    },
+    "CHALLENGEANSWER": {
+        MaxMessageSize: 16 * 1024,
+        MaxFieldSizes: map[string]int{"encryptedChallengeAnswers": 8 * 1024},
+        MaxUserAgentLength: 200,
+    },
+    "CHALLENGEVERIFICATION": {
+        MaxMessageSize: 256 * 1024,
+        MaxFieldSizes: map[string]int{"encryptedPublication": 240 * 1024},
+        MaxUserAgentLength: 200,
+    },
+}
+
+// a copy of the default limits of the message type, to change some of them with WithMessageLimits
+func DefaultMessageLimits(messageType string) MessageLimits {
+    messageLimits := defaultMessageLimits[messageType]
+    maxFieldSizes := map[string]int{}
+    for field, maxFieldSize := range messageLimits.MaxFieldSizes {
+        maxFieldSizes[field] = maxFieldSize
+    }
+    messageLimits.MaxFieldSizes = maxFieldSizes
+    return messageLimits
+}
+
+// replace the limits of the message type
+func WithMessageLimits(messageType string, messageLimits MessageLimits) ValidatorOption {
+    return func(validator *Validator) {
+        validator.messageLimits[messageType] = messageLimits
+    }
+}
+
+func getDefaultMessageLimits() map[string]MessageLimits {
+    messageLimits := map[string]MessageLimits{}
+    for messageType := range defaultMessageLimits {
+        messageLimits[messageType] = DefaultMessageLimits(messageType)
+    }
+    return messageLimits
+}
+
+func getCborEncodedSize(value interface{}) (int, error) {
+    encMode, _ := cbor.CTAP2EncOptions().EncMode()
+    encoded, err := encMode.Marshal(value)
+    return len(encoded), err
+}
+
+func validateMessageLimits(fields pubsubMessageFields, validator Validator) error {
+    messageLimits := validator.messageLimits[fields.messageType]
+    if (messageLimits.MaxMessageSize != 0 && fields.size > messageLimits.MaxMessageSize) {
+        return fmt.Errorf("invalid message size, %v bytes is over the %v limit of %v bytes", fields.size, fields.messageType, messageLimits.MaxMessageSize)
+    }
+    for field, maxFieldSize := range messageLimits.MaxFieldSizes {
+        if (maxFieldSize == 0 || fields.message[field] == nil) {
+            continue
+        }
+        fieldSize, err := getCborEncodedSize(fields.message[field])
+        if (err != nil) {
+            return fmt.Errorf("invalid message.%v, failed cbor encode: %w", field, err)
+        }
+        if (fieldSize > maxFieldSize) {
+            return fmt.Errorf("invalid message.%v size, %v bytes is over the %v limit of %v bytes", field, fieldSize, fields.messageType, maxFieldSize)
+        }
+    }
+
+    if (fields.message["acceptedChallengeTypes"] != nil && (messageLimits.MaxAcceptedChallengeTypes != 0 || messageLimits.MaxAcceptedChallengeTypeLength != 0)) {
+        acceptedChallengeTypes, ok := fields.message["acceptedChallengeTypes"].([]interface{})
+        if (!ok) {
+            return fmt.Errorf("invalid message.acceptedChallengeTypes, failed convert to array")
+        }
+        if (messageLimits.MaxAcceptedChallengeTypes != 0 && len(acceptedChallengeTypes) > messageLimits.MaxAcceptedChallengeTypes) {
+            return fmt.Errorf("invalid message.acceptedChallengeTypes, %v types is over the %v limit of %v", len(acceptedChallengeTypes), fields.messageType, messageLimits.MaxAcceptedChallengeTypes)
+        }
+        for i, acceptedChallengeType := range acceptedChallengeTypes {
+            acceptedChallengeTypeString, ok := acceptedChallengeType.(string)
+            if (!ok) {
+                return fmt.Errorf("invalid message.acceptedChallengeTypes[%v], failed convert to string", i)
+            }
+            if (messageLimits.MaxAcceptedChallengeTypeLength != 0 && len(acceptedChallengeTypeString) > messageLimits.MaxAcceptedChallengeTypeLength) {
+                return fmt.Errorf("invalid message.acceptedChallengeTypes[%v], length %v is over the %v limit of %v", i, len(acceptedChallengeTypeString), fields.messageType, messageLimits.MaxAcceptedChallengeTypeLength)
+            }
+        }
+    }
+
+    if (fields.message["userAgent"] != nil && messageLimits.MaxUserAgentLength != 0) {
+        userAgent, ok := fields.message["userAgent"].(string)
+        if (!ok) {
+            return fmt.Errorf("invalid message.userAgent, failed convert to string")
+        }
+        if (len(userAgent) > messageLimits.MaxUserAgentLength) {
+            return fmt.Errorf("invalid message.userAgent, length %v is over the %v limit of %v", len(userAgent), fields.messageType, messageLimits.MaxUserAgentLength)
+        }
+    }
+    return nil
+}
@@ -0,0 +1,94 @@
+package pubsubPlebbitValidator
+
+import (
+    "strings"
+    "testing"
+    pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+func expectMessageLimitsError(t *testing.T, name string, encodedMessage []byte, validator Validator, reason string) {
+    fields, err := decodePubsubMessage("topic", encodedMessage)
+    if (err != nil) {
+        t.Fatalf(`%v decode error is "%v" instead of "<nil>"`, name, err)
+    }
+    err = validateMessageLimits(fields, validator)
+    if (reason == "" && err != nil) {
+        t.Fatalf(`%v limits error is "%v" instead of "<nil>"`, name, err)
+    }
+    if (reason != "" && (err == nil || !strings.Contains(err.Error(), reason))) {
+        t.Fatalf(`%v limits error is "%v" instead of "%v"`, name, err, reason)
+    }
+}
+
+func TestMessageLimits(t *testing.T) {
+    validator := NewValidator(newMockHost())
+
+    // message size
+    fields, _ := decodePubsubMessage("topic", createSignedMessage("CHALLENGEANSWER", subplebbitPrivateKey, nil))
+    fields.size = 16 * 1024
+    err := validateMessageLimits(fields, validator)
+    if (err != nil) {
+        t.Fatalf(`max message size limits error is "%v" instead of "<nil>"`, err)
+    }
+    fields.size++
+    err = validateMessageLimits(fields, validator)
+    if (err == nil || !strings.Contains(err.Error(), "invalid message size, 16385 bytes is over the CHALLENGEANSWER limit of 16384 bytes")) {
+        t.Fatalf(`over max message size limits error is "%v"`, err)
+    }
+
+    // field size
+    encryptedChallenges := map[string]interface{}{"ciphertext": make([]byte, 1000)}
+    fieldSize, _ := getCborEncodedSize(encryptedChallenges)
+    messageLimits := DefaultMessageLimits("CHALLENGE")
+    messageLimits.MaxFieldSizes["encryptedChallenges"] = fieldSize
+    validator = NewValidator(newMockHost(), WithMessageLimits("CHALLENGE", messageLimits))
+    expectMessageLimitsError(t, "max field size", createSignedMessage("CHALLENGE", subplebbitPrivateKey, map[string]interface{}{"encryptedChallenges": encryptedChallenges}), validator, "")
+    encryptedChallenges["ciphertext"] = make([]byte, 1001)
+    expectMessageLimitsError(t, "over max field size", createSignedMessage("CHALLENGE", subplebbitPrivateKey, map[string]interface{}{"encryptedChallenges": encryptedChallenges}), validator, "invalid message.encryptedChallenges size")
+    // the default limits are not changed
+    if (DefaultMessageLimits("CHALLENGE").MaxFieldSizes["encryptedChallenges"] != 120 * 1024) {
+        t.Fatalf(`default encryptedChallenges limit is "%v" instead of "%v"`, DefaultMessageLimits("CHALLENGE").MaxFieldSizes["encryptedChallenges"], 120 * 1024)
+    }
+
+    // acceptedChallengeTypes
+    validator = NewValidator(newMockHost())
+    acceptedChallengeTypes := []string{}
+    for i := 0; i < 10; i++ {
+        acceptedChallengeTypes = append(acceptedChallengeTypes, "image/png")
+    }
+    expectMessageLimitsError(t, "max accepted challenge types", createSignedMessage("CHALLENGEREQUEST", subplebbitPrivateKey, map[string]interface{}{"acceptedChallengeTypes": acceptedChallengeTypes}), validator, "")
+    acceptedChallengeTypes = append(acceptedChallengeTypes, "image/png")
+    expectMessageLimitsError(t, "over max accepted challenge types", createSignedMessage("CHALLENGEREQUEST", subplebbitPrivateKey, map[string]interface{}{"acceptedChallengeTypes": acceptedChallengeTypes}), validator, "11 types is over the CHALLENGEREQUEST limit of 10")
+    expectMessageLimitsError(t, "max accepted challenge type length", createSignedMessage("CHALLENGEREQUEST", subplebbitPrivateKey, map[string]interface{}{"acceptedChallengeTypes": []string{strings.Repeat("a", 100)}}), validator, "")
+    expectMessageLimitsError(t, "over max accepted challenge type length", createSignedMessage("CHALLENGEREQUEST", subplebbitPrivateKey, map[string]interface{}{"acceptedChallengeTypes": []string{"image/png", strings.Repeat("a", 101)}}), validator, "acceptedChallengeTypes[1], length 101 is over")
+    expectMessageLimitsError(t, "invalid accepted challenge types", createSignedMessage("CHALLENGEREQUEST", subplebbitPrivateKey, map[string]interface{}{"acceptedChallengeTypes": "image/png"}), validator, "failed convert to array")
+
+    // userAgent
+    expectMessageLimitsError(t, "max user agent length", createSignedMessage("CHALLENGEVERIFICATION", subplebbitPrivateKey, map[string]interface{}{"userAgent": strings.Repeat("a", 200)}), validator, "")
+    expectMessageLimitsError(t, "over max user agent length", createSignedMessage("CHALLENGEVERIFICATION", subplebbitPrivateKey, map[string]interface{}{"userAgent": strings.Repeat("a", 201)}), validator, "invalid message.userAgent, length 201 is over the CHALLENGEVERIFICATION limit of 200")
+
+    // a limit of 0 is no limit
+    validator = NewValidator(newMockHost(), WithMessageLimits("CHALLENGEVERIFICATION", MessageLimits{}))
+    expectMessageLimitsError(t, "no user agent limit", createSignedMessage("CHALLENGEVERIFICATION", subplebbitPrivateKey, map[string]interface{}{"userAgent": strings.Repeat("a", 201)}), validator, "")
+}
+
+func TestValidateMessageLimits(t *testing.T) {
+    mockHost, peerId := newTestHost()
+    validator := NewValidator(mockHost)
+    topic := subplebbitPeerId.String()
+
+    encodedMessage := createSignedMessage("CHALLENGE", subplebbitPrivateKey, map[string]interface{}{"encryptedChallenges": createTestEnvelope(make([]byte, 120 * 1024))})
+    result := validateMessage(validator, peerId, topic, encodedMessage)
+    if (result != pubsub.ValidationReject) {
+        t.Fatalf(`large challenge validation result is "%v" instead of "%v"`, result, pubsub.ValidationReject)
+    }
+    failed, _ := getFailedChecks(validator.Check(topic, encodedMessage))
+    if (len(failed) != 1 || failed[0] != "limits") {
+        t.Fatalf(`failed checks are "%v" instead of "[limits]"`, failed)
+    }
+
+    result = validateMessage(validator, peerId, topic, createSignedMessage("CHALLENGE", subplebbitPrivateKey, map[string]interface{}{"encryptedChallenges": createTestEnvelope(make([]byte, 100 * 1024))}))
+    if (result != pubsub.ValidationAccept) {
+        t.Fatalf(`challenge validation result is "%v" instead of "%v"`, result, pubsub.ValidationAccept)
+    }
+}
@@ -0,0 +1,240 @@
+package pubsubPlebbitValidator
+
+import (
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "math"
+    "math/bits"
+    "sync"
+    "time"
+    lru "github.com/hashicorp/golang-lru/v2"
+    blake2b "github.com/minio/blake2b-simd"
+)
+
+// author keys are free to generate, so signatures don't deter CHALLENGEREQUEST spam, a node can require
+// a proof of work stamp, the message.proofOfWorkNonce that makes the blake2b-256 hash of the signed bytes
+// and the nonce start with difficulty zero bits. the nonce is not signed, it's computed after signing
+var ErrInsufficientProofOfWork = errors.New("insufficient proof of work")
+
+const maxProofOfWorkNonceLength = 32
+
+// the number of topics with an observed load, for the proof of work and the load shedding
+const topicLoadsSize = 10000
+
+type ProofOfWorkConfig struct {
+    // the difficulty in leading zero bits of a topic below the target rate, 0 doesn't require a stamp
+    MinimumDifficulty int
+    // the difficulty the load can't raise above, the same as the minimum to not adapt to the load
+    MaximumDifficulty int
+    // the accepted challenge requests per second on a topic above which each doubling of the rate
+    // adds 1 bit of difficulty, a 1 bit increase doubles the average cost of a stamp
+    TargetRate float64
+    // the half life of the observed rate, how fast the difficulty goes down after the load
+    RateHalfLife time.Duration
+}
+
+var DefaultProofOfWorkConfig = ProofOfWorkConfig{
+    MinimumDifficulty: 0,
+    MaximumDifficulty: 20,
+    TargetRate: 1,
+    RateHalfLife: time.Minute,
+}
+
+type topicLoad struct {
+    // the decayed count of accepted challenge requests
+    count float64
+    updated time.Time
+}
+
+// the per topic difficulty of the challenge request stamps, it can be updated at runtime and is safe
+// for concurrent use. the difficulty is different on each node, so a stamp under the difficulty is
+// ignored instead of rejected, the peer that forwarded it may require less
+type ProofOfWork struct {
+    mutex *sync.Mutex
+    config ProofOfWorkConfig
+    topicLoads *lru.Cache[string, *topicLoad]
+    // the difficulties set with SetDifficulty, they don't adapt to the load
+    difficulties map[string]int
+    // the clock of the validator, see WithClock
+    now func() time.Time
+}
+
+func NewProofOfWork(config ProofOfWorkConfig) *ProofOfWork {
+    topicLoads, err := lru.New[string, *topicLoad](topicLoadsSize)
+    if (err != nil) {
+        panic(err)
+    }
+    return &ProofOfWork{
+        mutex: &sync.Mutex{},
+        config: config,
+        topicLoads: topicLoads,
+        difficulties: map[string]int{},
+        now: time.Now,
+    }
+}
+
+// require proof of work stamps on the challenge requests
+func WithProofOfWork(proofOfWork *ProofOfWork) ValidatorOption {
+    return func(validator *Validator) {
+        validator.proofOfWork = proofOfWork
+    }
+}
+
+// nil without WithProofOfWork
+func (validator Validator) ProofOfWork() *ProofOfWork {
+    return validator.proofOfWork
+}
+
+func (proofOfWork *ProofOfWork) Config() ProofOfWorkConfig {
+    return proofOfWork.config
+}
+
+// set a fixed difficulty on the topic, e.g. during an attack
+func (proofOfWork *ProofOfWork) SetDifficulty(topic string, difficulty int) {
+    proofOfWork.mutex.Lock()
+    defer proofOfWork.mutex.Unlock()
+    proofOfWork.difficulties[topic] = difficulty
+}
+
+// adapt the difficulty of the topic to the load again
+func (proofOfWork *ProofOfWork) ResetDifficulty(topic string) {
+    proofOfWork.mutex.Lock()
+    defer proofOfWork.mutex.Unlock()
+    delete(proofOfWork.difficulties, topic)
+}
+
+func (proofOfWork *ProofOfWork) Difficulty(topic string) int {
+    return proofOfWork.getDifficulty(topic, proofOfWork.now())
+}
+
+// the difficulties of the topics with a fixed difficulty or an observed load
+func (proofOfWork *ProofOfWork) Difficulties() map[string]int {
+    difficulties := map[string]int{}
+    now := proofOfWork.now()
+    for _, topic := range proofOfWork.topicLoads.Keys() {
+        difficulties[topic] = proofOfWork.getDifficulty(topic, now)
+    }
+    proofOfWork.mutex.Lock()
+    for topic, difficulty := range proofOfWork.difficulties {
+        difficulties[topic] = difficulty
+    }
+    proofOfWork.mutex.Unlock()
+    return difficulties
+}
+
+func decayTopicLoad(load *topicLoad, now time.Time, halfLife time.Duration) {
+    elapsed := now.Sub(load.updated)
+    if (elapsed <= 0 || halfLife <= 0) {
+        return
+    }
+    load.count *= math.Pow(0.5, float64(elapsed) / float64(halfLife))
+    load.updated = now
+}
+
+func (proofOfWork *ProofOfWork) getDifficulty(topic string, now time.Time) int {
+    proofOfWork.mutex.Lock()
+    defer proofOfWork.mutex.Unlock()
+    difficulty, ok := proofOfWork.difficulties[topic]
+    if (ok) {
+        return difficulty
+    }
+    config := proofOfWork.config
+    difficulty = config.MinimumDifficulty
+    load, ok := proofOfWork.topicLoads.Peek(topic)
+    if (!ok || config.TargetRate <= 0 || config.RateHalfLife <= 0) {
+        return difficulty
+    }
+    decayTopicLoad(load, now, config.RateHalfLife)
+    // the rate of a count decaying with the half life
+    rate := load.count * math.Ln2 / config.RateHalfLife.Seconds()
+    if (rate > config.TargetRate) {
+        difficulty += int(math.Log2(rate / config.TargetRate))
+    }
+    if (difficulty > config.MaximumDifficulty) {
+        difficulty = config.MaximumDifficulty
+    }
+    return difficulty
+}
+
+// count an accepted challenge request in the load of the topic
+func (proofOfWork *ProofOfWork) observe(topic string, now time.Time) {
+    proofOfWork.mutex.Lock()
+    defer proofOfWork.mutex.Unlock()
+    load, ok := proofOfWork.topicLoads.Get(topic)
+    if (!ok) {
+        load = &topicLoad{updated: now}
+        proofOfWork.topicLoads.Add(topic, load)
+    }
+    decayTopicLoad(load, now, proofOfWork.config.RateHalfLife)
+    load.count++
+}
+
+func getProofOfWorkHash(bytesToSign []byte, nonce []byte) [32]byte {
+    return blake2b.Sum256(append(append([]byte{}, bytesToSign...), nonce...))
+}
+
+// the number of leading zero bits of the hash
+func getProofOfWorkDifficulty(hash [32]byte) int {
+    difficulty := 0
+    for _, hashByte := range hash {
+        difficulty += bits.LeadingZeros8(hashByte)
+        if (hashByte != 0) {
+            break
+        }
+    }
+    return difficulty
+}
+
+// the nonce that makes the hash of the signed bytes and the nonce start with difficulty zero bits,
+// each bit of difficulty doubles the average number of hashes
+func computeProofOfWorkNonce(bytesToSign []byte, difficulty int) []byte {
+    hashInput := append(append([]byte{}, bytesToSign...), make([]byte, 8)...)
+    nonce := hashInput[len(bytesToSign):]
+    for counter := uint64(0); ; counter++ {
+        binary.BigEndian.PutUint64(nonce, counter)
+        if (getProofOfWorkDifficulty(blake2b.Sum256(hashInput)) >= difficulty) {
+            return append([]byte{}, nonce...)
+        }
+    }
+}
+
+// set message.proofOfWorkNonce for the difficulty, after SignMessage because the stamp is over the signed bytes
+func StampMessage(message map[string]interface{}, difficulty int) error {
+    signature, ok := message["signature"].(map[string]interface{})
+    if (!ok) {
+        return errors.New("failed convert message.signature to map[string]interface{}, sign the message first")
+    }
+    signedPropertyNames, ok := signature["signedPropertyNames"].([]string)
+    if (!ok) {
+        return errors.New("failed convert message.signature.signedPropertyNames to []string")
+    }
+    message["proofOfWorkNonce"] = computeProofOfWorkNonce(getBytesToSign(message, signedPropertyNames), difficulty)
+    return nil
+}
+
+func validateProofOfWork(fields pubsubMessageFields, validator Validator) error {
+    if (validator.proofOfWork == nil || fields.messageType != "CHALLENGEREQUEST") {
+        return nil
+    }
+    difficulty := validator.proofOfWork.getDifficulty(fields.topic, validator.now())
+    if (fields.message["proofOfWorkNonce"] == nil) {
+        if (difficulty == 0) {
+            return nil
+        }
+        return fmt.Errorf("%w, no message.proofOfWorkNonce for the difficulty %v", ErrInsufficientProofOfWork, difficulty)
+    }
+    nonce, ok := fields.message["proofOfWorkNonce"].([]byte)
+    if (!ok) {
+        return errors.New("invalid proof of work, failed convert message.proofOfWorkNonce to []byte")
+    }
+    if (len(nonce) > maxProofOfWorkNonceLength) {
+        return fmt.Errorf("invalid proof of work, nonce length %v is over %v", len(nonce), maxProofOfWorkNonceLength)
+    }
+    bytesToSign := getBytesToSign(fields.message, fields.signature.signedPropertyNames)
+    stampDifficulty := getProofOfWorkDifficulty(getProofOfWorkHash(bytesToSign, nonce))
+    if (stampDifficulty < difficulty) {
+        return fmt.Errorf("%w, stamp difficulty %v is under the difficulty %v", ErrInsufficientProofOfWork, stampDifficulty, difficulty)
+    }
+    return nil
+}
@@ -0,0 +1,225 @@
+package pubsubPlebbitValidator
+
+import (
+    "errors"
+    "testing"
+    "time"
+    pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+func TestStampMessage(t *testing.T) {
+    message := createSignedMessageMap("CHALLENGEREQUEST", tryGeneratePrivateKey(), nil)
+    err := StampMessage(message, 12)
+    if (err != nil) {
+        t.Fatalf(`stamp error is "%v" instead of "<nil>"`, err)
+    }
+    signature := message["signature"].(map[string]interface{})
+    bytesToSign := getBytesToSign(message, signature["signedPropertyNames"].([]string))
+    difficulty := getProofOfWorkDifficulty(getProofOfWorkHash(bytesToSign, message["proofOfWorkNonce"].([]byte)))
+    if (difficulty < 12) {
+        t.Fatalf(`stamp difficulty is "%v" instead of at least "12"`, difficulty)
+    }
+    err = StampMessage(map[string]interface{}{}, 1)
+    if (err == nil) {
+        t.Fatalf(`stamp unsigned message error is "<nil>"`)
+    }
+
+    leadingZeros := map[int][32]byte{0: {0xff}, 1: {0x7f}, 8: {0, 0xff}, 15: {0, 0x01}, 256: {}}
+    for expected, hash := range leadingZeros {
+        if (getProofOfWorkDifficulty(hash) != expected) {
+            t.Fatalf(`%x difficulty is "%v" instead of "%v"`, hash, getProofOfWorkDifficulty(hash), expected)
+        }
+    }
+}
+
+func TestValidateProofOfWork(t *testing.T) {
+    mockHost, peerId := newTestHost()
+    proofOfWork := NewProofOfWork(ProofOfWorkConfig{MinimumDifficulty: 8, MaximumDifficulty: 8})
+    validator := NewValidator(mockHost, WithProofOfWork(proofOfWork))
+    topic := "topic"
+    privateKey := tryGeneratePrivateKey()
+
+    // without a stamp
+    result := validateMessage(validator, peerId, topic, createSignedMessage("CHALLENGEREQUEST", privateKey, nil))
+    if (result != pubsub.ValidationIgnore) {
+        t.Fatalf(`unstamped validation result is "%v" instead of "%v"`, result, pubsub.ValidationIgnore)
+    }
+    fields, _ := decodePubsubMessage(topic, createSignedMessage("CHALLENGEREQUEST", privateKey, nil))
+    if (!errors.Is(validateProofOfWork(fields, validator), ErrInsufficientProofOfWork)) {
+        t.Fatalf(`unstamped proof of work error is "%v" instead of "%v"`, validateProofOfWork(fields, validator), ErrInsufficientProofOfWork)
+    }
+
+    message := createSignedMessageMap("CHALLENGEREQUEST", privateKey, nil)
+    StampMessage(message, 8)
+    result = validateMessage(validator, peerId, topic, cborEncode(message))
+    if (result != pubsub.ValidationAccept) {
+        t.Fatalf(`stamped validation result is "%v" instead of "%v"`, result, pubsub.ValidationAccept)
+    }
+
+    // the stamp is over the signed bytes, it can't be reused for another message
+    message["timestamp"] = message["timestamp"].(int64) + 1
+    signPubsubMessage(message, privateKey)
+    fields, _ = decodePubsubMessage(topic, cborEncode(message))
+    stampDifficulty := getProofOfWorkDifficulty(getProofOfWorkHash(getBytesToSign(fields.message, fields.signature.signedPropertyNames), message["proofOfWorkNonce"].([]byte)))
+    if (stampDifficulty < 8 && !errors.Is(validateProofOfWork(fields, validator), ErrInsufficientProofOfWork)) {
+        t.Fatalf(`reused stamp proof of work error is "%v" instead of "%v"`, validateProofOfWork(fields, validator), ErrInsufficientProofOfWork)
+    }
+
+    // a forged signature without a stamp is rejected, not ignored
+    message = createSignedMessageMap("CHALLENGEREQUEST", privateKey, nil)
+    message["signature"].(map[string]interface{})["signature"].([]byte)[0] ^= 0xff
+    result = validateMessage(validator, peerId, topic, cborEncode(message))
+    if (result != pubsub.ValidationReject) {
+        t.Fatalf(`forged signature validation result is "%v" instead of "%v"`, result, pubsub.ValidationReject)
+    }
+
+    // a malformed nonce is rejected
+    for name, nonce := range map[string]interface{}{"string nonce": "nonce", "long nonce": make([]byte, 33)} {
+        message := createSignedMessageMap("CHALLENGEREQUEST", privateKey, nil)
+        message["proofOfWorkNonce"] = nonce
+        result = validateMessage(validator, peerId, topic, cborEncode(message))
+        if (result != pubsub.ValidationReject) {
+            t.Fatalf(`%v validation result is "%v" instead of "%v"`, name, result, pubsub.ValidationReject)
+        }
+    }
+
+    // only the challenge requests need a stamp
+    result = validateMessage(validator, peerId, topic, createSignedMessage("CHALLENGEANSWER", subplebbitPrivateKey, nil))
+    if (result == pubsub.ValidationIgnore) {
+        t.Fatalf(`challenge answer without stamp was ignored`)
+    }
+
+    // a fixed difficulty
+    proofOfWork.SetDifficulty(topic, 0)
+    result = validateMessage(validator, peerId, topic, createSignedMessage("CHALLENGEREQUEST", privateKey, nil))
+    if (result != pubsub.ValidationAccept) {
+        t.Fatalf(`difficulty 0 validation result is "%v" instead of "%v"`, result, pubsub.ValidationAccept)
+    }
+    if (validator.ProofOfWork().Difficulties()[topic] != 0) {
+        t.Fatalf(`difficulties are "%v"`, validator.ProofOfWork().Difficulties())
+    }
+    proofOfWork.ResetDifficulty(topic)
+    if (proofOfWork.Difficulty(topic) != 8) {
+        t.Fatalf(`reset difficulty is "%v" instead of "8"`, proofOfWork.Difficulty(topic))
+    }
+
+    // without WithProofOfWork the stamps are not needed
+    result = validateMessage(NewValidator(mockHost), peerId, topic, createSignedMessage("CHALLENGEREQUEST", privateKey, nil))
+    if (result != pubsub.ValidationAccept) {
+        t.Fatalf(`no proof of work validation result is "%v" instead of "%v"`, result, pubsub.ValidationAccept)
+    }
+}
+
+func TestProofOfWorkAdaptiveDifficulty(t *testing.T) {
+    proofOfWork := NewProofOfWork(ProofOfWorkConfig{MinimumDifficulty: 2, MaximumDifficulty: 6, TargetRate: 1, RateHalfLife: time.Minute})
+    testClock := newTestClock()
+    topic := "topic"
+    if (proofOfWork.getDifficulty(topic, testClock.now()) != 2) {
+        t.Fatalf(`difficulty without load is "%v" instead of "2"`, proofOfWork.getDifficulty(topic, testClock.now()))
+    }
+
+    // the rate of a count c decaying with a 1 minute half life is c×ln2/60 per second,
+    // 1 per second is about 87 challenge requests
+    for i := 0; i < 87; i++ {
+        proofOfWork.observe(topic, testClock.now())
+    }
+    if (proofOfWork.getDifficulty(topic, testClock.now()) != 2) {
+        t.Fatalf(`difficulty at the target rate is "%v" instead of "2"`, proofOfWork.getDifficulty(topic, testClock.now()))
+    }
+    // 2 times the target rate
+    for i := 0; i < 87; i++ {
+        proofOfWork.observe(topic, testClock.now())
+    }
+    if (proofOfWork.getDifficulty(topic, testClock.now()) != 3) {
+        t.Fatalf(`difficulty at 2 times the target rate is "%v" instead of "3"`, proofOfWork.getDifficulty(topic, testClock.now()))
+    }
+    // 4 times the target rate
+    for i := 0; i < 2 * 87; i++ {
+        proofOfWork.observe(topic, testClock.now())
+    }
+    if (proofOfWork.getDifficulty(topic, testClock.now()) != 4) {
+        t.Fatalf(`difficulty at 4 times the target rate is "%v" instead of "4"`, proofOfWork.getDifficulty(topic, testClock.now()))
+    }
+    // the maximum
+    for i := 0; i < 100000; i++ {
+        proofOfWork.observe(topic, testClock.now())
+    }
+    if (proofOfWork.getDifficulty(topic, testClock.now()) != 6) {
+        t.Fatalf(`difficulty under heavy load is "%v" instead of "6"`, proofOfWork.getDifficulty(topic, testClock.now()))
+    }
+    // other topics don't have the load
+    if (proofOfWork.getDifficulty("other topic", testClock.now()) != 2) {
+        t.Fatalf(`other topic difficulty is "%v" instead of "2"`, proofOfWork.getDifficulty("other topic", testClock.now()))
+    }
+
+    // the load decays
+    testClock.advance(30 * time.Minute)
+    if (proofOfWork.getDifficulty(topic, testClock.now()) != 2) {
+        t.Fatalf(`difficulty after the load is "%v" instead of "2"`, proofOfWork.getDifficulty(topic, testClock.now()))
+    }
+}
+
+func TestValidateProofOfWorkObservesLoad(t *testing.T) {
+    mockHost, peerId := newTestHost()
+    proofOfWork := NewProofOfWork(ProofOfWorkConfig{MinimumDifficulty: 0, MaximumDifficulty: 4, TargetRate: 0.01, RateHalfLife: time.Minute})
+    testClock := newTestClock()
+    validator := NewValidator(mockHost, WithProofOfWork(proofOfWork), WithClock(testClock.now))
+    topic := "topic"
+
+    // the accepted challenge requests raise the difficulty until the unstamped ones are ignored
+    results := map[pubsub.ValidationResult]int{}
+    for i := 0; i < 10; i++ {
+        results[validateMessage(validator, peerId, topic, createSignedMessage("CHALLENGEREQUEST", tryGeneratePrivateKey(), nil))]++
+    }
+    if (results[pubsub.ValidationAccept] == 0 || results[pubsub.ValidationIgnore] == 0) {
+        t.Fatalf(`validation results are "%v"`, results)
+    }
+    message := createSignedMessageMap("CHALLENGEREQUEST", tryGeneratePrivateKey(), nil)
+    StampMessage(message, 4)
+    result := validateMessage(validator, peerId, topic, cborEncode(message))
+    if (result != pubsub.ValidationAccept) {
+        t.Fatalf(`stamped validation result is "%v" instead of "%v"`, result, pubsub.ValidationAccept)
+    }
+
+    // the difficulties use the clock of the validator
+    testClock.advance(30 * time.Minute)
+    if (proofOfWork.Difficulty(topic) != 0 || proofOfWork.Difficulties()[topic] != 0) {
+        t.Fatalf(`difficulty after the load is "%v" instead of "0"`, proofOfWork.Difficulty(topic))
+    }
+}
+
+func BenchmarkValidateProofOfWork(b *testing.B) {
+    validator := NewValidator(newMockHost(), WithProofOfWork(NewProofOfWork(ProofOfWorkConfig{MinimumDifficulty: 16, MaximumDifficulty: 16})))
+    message := createSignedMessageMap("CHALLENGEREQUEST", tryGeneratePrivateKey(), nil)
+    StampMessage(message, 16)
+    fields, _ := decodePubsubMessage("topic", cborEncode(message))
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        err := validateProofOfWork(fields, validator)
+        if (err != nil) {
+            b.Fatal(err)
+        }
+    }
+}
+
+// the signature check the proof of work runs after, for comparison
+func BenchmarkValidateSignature(b *testing.B) {
+    fields, _ := decodePubsubMessage("topic", createSignedMessage("CHALLENGEREQUEST", tryGeneratePrivateKey(), nil))
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        err := validateSignature(fields.message, fields.signature)
+        if (err != nil) {
+            b.Fatal(err)
+        }
+    }
+}
+
+// the average cost of a stamp for the author, it doubles with each bit of difficulty
+func BenchmarkStampMessage(b *testing.B) {
+    message := createSignedMessageMap("CHALLENGEREQUEST", tryGeneratePrivateKey(), nil)
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        message["timestamp"] = int64(i)
+        StampMessage(message, 12)
+    }
+}
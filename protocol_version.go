@@ -0,0 +1,214 @@
+package pubsubPlebbitValidator
+
+import (
+    "errors"
+    "fmt"
+    "strconv"
+    "strings"
+    pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+// the semver of message.protocolVersion, the build metadata is ignored
+type ProtocolVersion struct {
+    Major uint64
+    Minor uint64
+    Patch uint64
+    // e.g. "beta.1" in 1.1.0-beta.1
+    Prerelease string
+}
+
+func ParseProtocolVersion(version string) (ProtocolVersion, error) {
+    protocolVersion := ProtocolVersion{}
+    version, _, _ = strings.Cut(version, "+")
+    version, prerelease, hasPrerelease := strings.Cut(version, "-")
+    if (hasPrerelease) {
+        if (prerelease == "") {
+            return ProtocolVersion{}, errors.New("empty prerelease")
+        }
+        for _, identifier := range strings.Split(prerelease, ".") {
+            if (identifier == "") {
+                return ProtocolVersion{}, errors.New("empty prerelease identifier")
+            }
+        }
+        protocolVersion.Prerelease = prerelease
+    }
+    numbers := strings.Split(version, ".")
+    if (len(numbers) != 3) {
+        return ProtocolVersion{}, fmt.Errorf("%v is not major.minor.patch", version)
+    }
+    parsed := make([]uint64, 3)
+    for i, number := range numbers {
+        // no leading zeros
+        if (len(number) > 1 && number[0] == '0') {
+            return ProtocolVersion{}, fmt.Errorf("invalid version number %v", number)
+        }
+        var err error
+        parsed[i], err = strconv.ParseUint(number, 10, 64)
+        if (err != nil) {
+            return ProtocolVersion{}, fmt.Errorf("invalid version number %v", number)
+        }
+    }
+    protocolVersion.Major, protocolVersion.Minor, protocolVersion.Patch = parsed[0], parsed[1], parsed[2]
+    return protocolVersion, nil
+}
+
+func (protocolVersion ProtocolVersion) String() string {
+    version := fmt.Sprintf("%v.%v.%v", protocolVersion.Major, protocolVersion.Minor, protocolVersion.Patch)
+    if (protocolVersion.Prerelease != "") {
+        version += "-" + protocolVersion.Prerelease
+    }
+    return version
+}
+
+func compareUint(a uint64, b uint64) int {
+    if (a < b) {
+        return -1
+    }
+    if (a > b) {
+        return 1
+    }
+    return 0
+}
+
+// -1, 0 or 1 with the semver precedence, a prerelease is lower than its release
+func (protocolVersion ProtocolVersion) Compare(other ProtocolVersion) int {
+    comparison := compareUint(protocolVersion.Major, other.Major)
+    if (comparison == 0) {
+        comparison = compareUint(protocolVersion.Minor, other.Minor)
+    }
+    if (comparison == 0) {
+        comparison = compareUint(protocolVersion.Patch, other.Patch)
+    }
+    if (comparison != 0 || protocolVersion.Prerelease == other.Prerelease) {
+        return comparison
+    }
+    if (protocolVersion.Prerelease == "") {
+        return 1
+    }
+    if (other.Prerelease == "") {
+        return -1
+    }
+    identifiers, otherIdentifiers := strings.Split(protocolVersion.Prerelease, "."), strings.Split(other.Prerelease, ".")
+    for i := 0; i < len(identifiers) && i < len(otherIdentifiers); i++ {
+        number, err := strconv.ParseUint(identifiers[i], 10, 64)
+        isNumber := err == nil
+        otherNumber, err := strconv.ParseUint(otherIdentifiers[i], 10, 64)
+        otherIsNumber := err == nil
+        // numeric identifiers are lower than alphanumeric identifiers
+        if (isNumber && otherIsNumber) {
+            comparison = compareUint(number, otherNumber)
+        } else if (isNumber) {
+            comparison = -1
+        } else if (otherIsNumber) {
+            comparison = 1
+        } else {
+            comparison = strings.Compare(identifiers[i], otherIdentifiers[i])
+        }
+        if (comparison != 0) {
+            return comparison
+        }
+    }
+    return compareUint(uint64(len(identifiers)), uint64(len(otherIdentifiers)))
+}
+
+// the versions supported by default, the next major version can change the protocol in incompatible ways
+var defaultMinimumProtocolVersion = ProtocolVersion{Major: 1}
+var defaultMaximumProtocolVersion = ProtocolVersion{Major: 2}
+
+// messages with a valid protocolVersion outside the supported range, they get the
+// unsupported protocol version result instead of being rejected
+var ErrUnsupportedProtocolVersion = errors.New("unsupported protocol version")
+
+// the minimum version is included, the maximum version is excluded. unsupportedResult is
+// the validation result of the messages outside the range, pubsub.ValidationIgnore drops them
+// without penalizing the peers that forward them, so nodes that upgrade first aren't graylisted
+// by the nodes that didn't, pubsub.ValidationReject penalizes them
+func WithProtocolVersions(minimum ProtocolVersion, maximum ProtocolVersion, unsupportedResult pubsub.ValidationResult) ValidatorOption {
+    return func(validator *Validator) {
+        validator.minimumProtocolVersion = minimum
+        validator.maximumProtocolVersion = maximum
+        validator.unsupportedProtocolVersionResult = unsupportedResult
+    }
+}
+
+func getProtocolVersion(message map[string]interface{}) (ProtocolVersion, error) {
+    version, ok := message["protocolVersion"].(string)
+    if (!ok) {
+        return ProtocolVersion{}, errors.New("invalid protocol version, failed convert message.protocolVersion to string")
+    }
+    protocolVersion, err := ParseProtocolVersion(version)
+    if (err != nil) {
+        return ProtocolVersion{}, fmt.Errorf("invalid protocol version %v, failed ParseProtocolVersion: %w", version, err)
+    }
+    return protocolVersion, nil
+}
+
+func validateProtocolVersion(message map[string]interface{}, validator Validator) error {
+    protocolVersion, err := getProtocolVersion(message)
+    if (err != nil) {
+        return err
+    }
+    if (protocolVersion.Compare(validator.minimumProtocolVersion) < 0 || protocolVersion.Compare(validator.maximumProtocolVersion) >= 0) {
+        // the user agent helps finding which client sends it
+        userAgent, _ := message["userAgent"].(string)
+        return fmt.Errorf("%w %v from user agent %v, supported versions are >=%v <%v", ErrUnsupportedProtocolVersion, protocolVersion, userAgent, validator.minimumProtocolVersion, validator.maximumProtocolVersion)
+    }
+    return nil
+}
+
+// the rules of the messages from a protocol version until the next protocol rules version,
+// add a new protocolRules to roll out a protocol change, e.g. a new required signed property,
+// and release the clients that follow it before the validators that enforce it
+type protocolRules struct {
+    version ProtocolVersion
+    // the properties that must be in signature.signedPropertyNames, by message type
+    requiredSignedPropertyNames map[string][]string
+}
+
+var protocolRuleSets = []protocolRules{
+    {
+        version: ProtocolVersion{Major: 1},
+        requiredSignedPropertyNames: map[string][]string{
+            "CHALLENGEREQUEST": {"type", "timestamp", "challengeRequestId", "encryptedPublication"},
+            "CHALLENGE": {"type", "timestamp", "challengeRequestId"},
+            "CHALLENGEANSWER": {"type", "timestamp", "challengeRequestId"},
+            "CHALLENGEVERIFICATION": {"type", "timestamp", "challengeRequestId"},
+        },
+    },
+}
+
+// the latest rules with a version lower or equal to the protocol version, protocolRuleSets is sorted by version
+func getProtocolRules(protocolVersion ProtocolVersion) (protocolRules, bool) {
+    for i := len(protocolRuleSets) - 1; i >= 0; i-- {
+        if (protocolRuleSets[i].version.Compare(protocolVersion) <= 0) {
+            return protocolRuleSets[i], true
+        }
+    }
+    return protocolRules{}, false
+}
+
+func validateProtocolRules(fields pubsubMessageFields) error {
+    protocolVersion, err := getProtocolVersion(fields.message)
+    if (err != nil) {
+        return err
+    }
+    rules, ok := getProtocolRules(protocolVersion)
+    if (!ok) {
+        return fmt.Errorf("%w %v, no protocol rules", ErrUnsupportedProtocolVersion, protocolVersion)
+    }
+    signedPropertyNames := map[string]bool{}
+    for _, propertyName := range fields.signature.signedPropertyNames {
+        signedPropertyNames[propertyName] = true
+    }
+    // the first rules apply to the messages that don't sign protocolVersion, e.g. from plebbit-js, the later
+    // rules require it signed so a relay can't relabel a message with an older version to skip them
+    if (rules.version.Compare(protocolRuleSets[0].version) > 0 && !signedPropertyNames["protocolVersion"]) {
+        return fmt.Errorf("invalid signature, protocolVersion is not in signature.signedPropertyNames of protocol version %v", protocolVersion)
+    }
+    for _, propertyName := range rules.requiredSignedPropertyNames[fields.messageType] {
+        if (!signedPropertyNames[propertyName]) {
+            return fmt.Errorf("invalid signature, %v is not in signature.signedPropertyNames of protocol version %v", propertyName, protocolVersion)
+        }
+    }
+    return nil
+}
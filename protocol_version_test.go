@@ -0,0 +1,166 @@
+package pubsubPlebbitValidator
+
+import (
+    "errors"
+    "testing"
+    pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+func TestParseProtocolVersion(t *testing.T) {
+    valid := map[string]ProtocolVersion{
+        "1.0.0": {1, 0, 0, ""},
+        "1.2.3": {1, 2, 3, ""},
+        "10.20.30-beta.1": {10, 20, 30, "beta.1"},
+        "1.0.0+build.5": {1, 0, 0, ""},
+        "1.0.0-rc.1+build.5": {1, 0, 0, "rc.1"},
+    }
+    for version, expected := range valid {
+        protocolVersion, err := ParseProtocolVersion(version)
+        if (err != nil || protocolVersion != expected) {
+            t.Fatalf(`ParseProtocolVersion("%v") is "%+v" "%v" instead of "%+v"`, version, protocolVersion, err, expected)
+        }
+    }
+    for _, version := range []string{"", "1", "1.0", "1.0.0.0", "01.0.0", "1.-1.0", "1.0.x", "1.0.0-", "1.0.0-beta..1", "v1.0.0"} {
+        _, err := ParseProtocolVersion(version)
+        if (err == nil) {
+            t.Fatalf(`ParseProtocolVersion("%v") error is "<nil>"`, version)
+        }
+    }
+}
+
+func TestCompareProtocolVersion(t *testing.T) {
+    // sorted by semver precedence
+    versions := []string{"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.0.1", "1.1.0", "2.0.0"}
+    for i := 0; i < len(versions) - 1; i++ {
+        lower, _ := ParseProtocolVersion(versions[i])
+        higher, _ := ParseProtocolVersion(versions[i + 1])
+        if (lower.Compare(higher) != -1 || higher.Compare(lower) != 1 || lower.Compare(lower) != 0) {
+            t.Fatalf(`"%v" is not lower than "%v"`, versions[i], versions[i + 1])
+        }
+    }
+}
+
+func TestValidateProtocolVersion(t *testing.T) {
+    mockHost, peerId := newTestHost()
+    validator := NewValidator(mockHost)
+
+    results := map[string]pubsub.ValidationResult{
+        "1.0.0": pubsub.ValidationAccept,
+        "1.9.0-beta.1": pubsub.ValidationAccept,
+        // unsupported versions are ignored by default
+        "0.9.0": pubsub.ValidationIgnore,
+        "1.0.0-alpha": pubsub.ValidationIgnore,
+        "2.0.0": pubsub.ValidationIgnore,
+        // invalid versions are rejected
+        "1.0": pubsub.ValidationReject,
+        "": pubsub.ValidationReject,
+    }
+    for protocolVersion, expected := range results {
+        result := validateMessage(validator, peerId, "topic", createSignedMessage("CHALLENGEREQUEST", tryGeneratePrivateKey(), map[string]interface{}{"protocolVersion": protocolVersion}))
+        if (result != expected) {
+            t.Fatalf(`protocol version "%v" validation result is "%v" instead of "%v"`, protocolVersion, result, expected)
+        }
+    }
+
+    // a missing protocol version is rejected
+    result := validateMessage(validator, peerId, "topic", createSignedMessage("CHALLENGEREQUEST", tryGeneratePrivateKey(), map[string]interface{}{"protocolVersion": nil}))
+    if (result != pubsub.ValidationReject) {
+        t.Fatalf(`missing protocol version validation result is "%v" instead of "%v"`, result, pubsub.ValidationReject)
+    }
+
+    // a configured range and result
+    validator = NewValidator(mockHost, WithProtocolVersions(ProtocolVersion{Major: 1, Minor: 1}, ProtocolVersion{Major: 3}, pubsub.ValidationReject))
+    results = map[string]pubsub.ValidationResult{
+        "1.0.0": pubsub.ValidationReject,
+        "1.1.0": pubsub.ValidationAccept,
+        "2.5.0": pubsub.ValidationAccept,
+        "3.0.0": pubsub.ValidationReject,
+    }
+    for protocolVersion, expected := range results {
+        result := validateMessage(validator, peerId, "topic", createSignedMessage("CHALLENGEREQUEST", tryGeneratePrivateKey(), map[string]interface{}{"protocolVersion": protocolVersion}))
+        if (result != expected) {
+            t.Fatalf(`protocol version "%v" validation result is "%v" instead of "%v"`, protocolVersion, result, expected)
+        }
+    }
+
+    checkResults := validator.Check("topic", createSignedMessage("CHALLENGEREQUEST", tryGeneratePrivateKey(), map[string]interface{}{"protocolVersion": "3.0.0"}))
+    failed, _ := getFailedChecks(checkResults)
+    if (len(failed) != 1 || failed[0] != "protocolVersion") {
+        t.Fatalf(`failed checks are "%v" instead of "[protocolVersion]"`, failed)
+    }
+    for _, checkResult := range checkResults {
+        if (checkResult.Name == "protocolVersion" && !errors.Is(checkResult.Err, ErrUnsupportedProtocolVersion)) {
+            t.Fatalf(`protocolVersion check error is "%v" instead of "%v"`, checkResult.Err, ErrUnsupportedProtocolVersion)
+        }
+    }
+}
+
+func TestProtocolRules(t *testing.T) {
+    mockHost, peerId := newTestHost()
+    validator := NewValidator(mockHost)
+
+    // the timestamp must be signed
+    privateKey := tryGeneratePrivateKey()
+    message := createPubsubChallengeRequestMessage(privateKey)
+    SignMessage(message, privateKey, []string{"type", "challengeRequestId", "acceptedChallengeTypes", "encryptedPublication"})
+    failed, _ := getFailedChecks(validator.Check("topic", cborEncode(message)))
+    if (len(failed) != 1 || failed[0] != "protocolRules") {
+        t.Fatalf(`failed checks are "%v" instead of "[protocolRules]"`, failed)
+    }
+
+    // a new required signed property from 1.1.0 doesn't apply to 1.0.0 messages
+    defer func(previousRuleSets []protocolRules) {
+        protocolRuleSets = previousRuleSets
+    }(protocolRuleSets)
+    protocolRuleSets = append([]protocolRules{}, protocolRuleSets...)
+    protocolRuleSets = append(protocolRuleSets, protocolRules{
+        version: ProtocolVersion{Major: 1, Minor: 1},
+        requiredSignedPropertyNames: map[string][]string{
+            "CHALLENGEREQUEST": {"type", "timestamp", "challengeRequestId", "encryptedPublication", "userAgent"},
+        },
+    })
+    results := map[string]pubsub.ValidationResult{
+        "1.0.0": pubsub.ValidationAccept,
+        "1.1.0-beta.1": pubsub.ValidationAccept,
+        "1.1.0": pubsub.ValidationReject,
+        "1.2.0": pubsub.ValidationReject,
+    }
+    for protocolVersion, expected := range results {
+        result := validateMessage(validator, peerId, "topic", createSignedMessage("CHALLENGEREQUEST", tryGeneratePrivateKey(), map[string]interface{}{"protocolVersion": protocolVersion}))
+        if (result != expected) {
+            t.Fatalf(`protocol version "%v" validation result is "%v" instead of "%v"`, protocolVersion, result, expected)
+        }
+    }
+    message = createPubsubChallengeRequestMessage(privateKey)
+    message["protocolVersion"] = "1.1.0"
+    SignMessage(message, privateKey, []string{"type", "timestamp", "challengeRequestId", "encryptedPublication", "protocolVersion", "userAgent"})
+    result := validateMessage(validator, peerId, "topic", cborEncode(message))
+    if (result != pubsub.ValidationAccept) {
+        t.Fatalf(`1.1.0 message with signed userAgent validation result is "%v" instead of "%v"`, result, pubsub.ValidationAccept)
+    }
+
+    // the protocol version picks the rules, it must be signed after the first rules
+    message = createPubsubChallengeRequestMessage(privateKey)
+    message["protocolVersion"] = "1.1.0"
+    SignMessage(message, privateKey, []string{"type", "timestamp", "challengeRequestId", "encryptedPublication", "userAgent"})
+    failed, _ = getFailedChecks(validator.Check("topic", cborEncode(message)))
+    if (len(failed) != 1 || failed[0] != "protocolRules") {
+        t.Fatalf(`1.1.0 message with unsigned protocolVersion failed checks are "%v" instead of "[protocolRules]"`, failed)
+    }
+
+    // a signed 1.0.0 message relabeled with 1.1.0, or a signed 1.1.0 message relabeled with 1.0.0 to skip its rules, fails the signature
+    for _, versions := range [][2]string{{"1.0.0", "1.1.0"}, {"1.1.0", "1.0.0"}} {
+        message = createPubsubChallengeRequestMessage(privateKey)
+        message["protocolVersion"] = versions[0]
+        signPubsubMessage(message, privateKey)
+        message["protocolVersion"] = versions[1]
+        failed, _ = getFailedChecks(validator.Check("topic", cborEncode(message)))
+        if (len(failed) == 0 || failed[0] != "signature") {
+            t.Fatalf(`%v message relabeled with %v failed checks are "%v" instead of "[signature ...]"`, versions[0], versions[1], failed)
+        }
+        result = validateMessage(validator, peerId, "topic", cborEncode(message))
+        if (result != pubsub.ValidationReject) {
+            t.Fatalf(`%v message relabeled with %v validation result is "%v" instead of "%v"`, versions[0], versions[1], result, pubsub.ValidationReject)
+        }
+    }
+}
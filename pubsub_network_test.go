@@ -0,0 +1,338 @@
+package pubsubPlebbitValidator
+
+import (
+    "testing"
+    "bytes"
+    "context"
+    "sync"
+    "time"
+    pubsub "github.com/libp2p/go-libp2p-pubsub"
+    pubsub_pb "github.com/libp2p/go-libp2p-pubsub/pb"
+    host "github.com/libp2p/go-libp2p/core/host"
+    peer "github.com/libp2p/go-libp2p/core/peer"
+    mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+type testPubsubNetworkConfig struct {
+    nodeCount int
+    // the pairs of connected nodes, all the nodes are connected if nil
+    links [][2]int
+    // the nodes without the plebbit validator, to publish invalid messages like an attacker
+    unvalidatedNodes []int
+}
+
+// gossipsub nodes on an in-memory libp2p network, joined to the subplebbitPrivateKey topic
+type testPubsubNetwork struct {
+    t *testing.T
+    ctx context.Context
+    nodes []*testPubsubNode
+}
+
+type testPubsubNode struct {
+    host host.Host
+    validator Validator
+    scoreInspector *ScoreInspector
+    topic *pubsub.Topic
+    mutex *sync.Mutex
+    received [][]byte
+    // the rejected messages by the peer they were received from
+    rejected map[peer.ID]int
+}
+
+func newTestPubsubNetwork(t *testing.T, config testPubsubNetworkConfig) *testPubsubNetwork {
+    ctx, cancel := context.WithCancel(context.Background())
+    testNetwork := mocknet.New()
+    t.Cleanup(func() {
+        cancel()
+        testNetwork.Close()
+    })
+
+    for i := 0; i < config.nodeCount; i++ {
+        _, err := testNetwork.GenPeer()
+        if (err != nil) {
+            t.Fatalf(`GenPeer error is "%v" instead of "<nil>"`, err)
+        }
+    }
+    err := testNetwork.LinkAll()
+    if (err != nil) {
+        t.Fatalf(`LinkAll error is "%v" instead of "<nil>"`, err)
+    }
+    hosts := testNetwork.Hosts()
+    if (config.links == nil) {
+        err = testNetwork.ConnectAllButSelf()
+        if (err != nil) {
+            t.Fatalf(`ConnectAllButSelf error is "%v" instead of "<nil>"`, err)
+        }
+    }
+    for _, link := range config.links {
+        _, err = testNetwork.ConnectPeers(hosts[link[0]].ID(), hosts[link[1]].ID())
+        if (err != nil) {
+            t.Fatalf(`ConnectPeers error is "%v" instead of "<nil>"`, err)
+        }
+    }
+
+    unvalidatedNodes := map[int]bool{}
+    for _, unvalidatedNode := range config.unvalidatedNodes {
+        unvalidatedNodes[unvalidatedNode] = true
+    }
+    pubsubNetwork := &testPubsubNetwork{t: t, ctx: ctx}
+    for i, host := range hosts {
+        node := &testPubsubNode{
+            host: host,
+            validator: NewValidator(host),
+            mutex: &sync.Mutex{},
+            rejected: map[peer.ID]int{},
+        }
+        // the statistics don't decay during the test
+        node.validator.now = newTestClock().now
+        node.scoreInspector = NewScoreInspector(node.validator)
+        scoreConfig, _ := NewScoreConfig(ScorePresetDefault)
+        peerScoreOption, err := scoreConfig.PubsubOption(node.validator)
+        if (err != nil) {
+            t.Fatalf(`PubsubOption error is "%v" instead of "<nil>"`, err)
+        }
+        options := []pubsub.Option{
+            peerScoreOption,
+            node.scoreInspector.PubsubOption(100 * time.Millisecond),
+            pubsub.WithEventTracer(node),
+        }
+        if (!unvalidatedNodes[i]) {
+            options = append(options, pubsub.WithDefaultValidator(node.validator.Validate))
+        }
+        nodePubsub, err := pubsub.NewGossipSub(ctx, host, options...)
+        if (err != nil) {
+            t.Fatalf(`NewGossipSub error is "%v" instead of "<nil>"`, err)
+        }
+        node.topic, err = JoinTopic(nodePubsub, subplebbitPeerId.String(), NewTopicScoreParams(1))
+        if (err != nil) {
+            t.Fatalf(`JoinTopic error is "%v" instead of "<nil>"`, err)
+        }
+        subscription, err := node.topic.Subscribe()
+        if (err != nil) {
+            t.Fatalf(`Subscribe error is "%v" instead of "<nil>"`, err)
+        }
+        go node.receive(ctx, subscription)
+        pubsubNetwork.nodes = append(pubsubNetwork.nodes, node)
+    }
+
+    // wait for the subscriptions to propagate, then for a heartbeat to graft the meshes
+    subscribed := pubsubNetwork.waitFor(func() bool {
+        for _, node := range pubsubNetwork.nodes {
+            if (len(node.topic.ListPeers()) != len(node.host.Network().Peers())) {
+                return false
+            }
+        }
+        return true
+    })
+    if (!subscribed) {
+        t.Fatalf(`the subscriptions didn't propagate`)
+    }
+    time.Sleep(pubsub.GossipSubHeartbeatInterval + 200 * time.Millisecond)
+    return pubsubNetwork
+}
+
+func (node *testPubsubNode) receive(ctx context.Context, subscription *pubsub.Subscription) {
+    for {
+        message, err := subscription.Next(ctx)
+        if (err != nil) {
+            return
+        }
+        node.mutex.Lock()
+        node.received = append(node.received, message.Data)
+        node.mutex.Unlock()
+    }
+}
+
+// pubsub.EventTracer to count the rejected messages
+func (node *testPubsubNode) Trace(event *pubsub_pb.TraceEvent) {
+    if (event.GetType() != pubsub_pb.TraceEvent_REJECT_MESSAGE) {
+        return
+    }
+    node.mutex.Lock()
+    defer node.mutex.Unlock()
+    node.rejected[peer.ID(event.GetRejectMessage().GetReceivedFrom())]++
+}
+
+func (node *testPubsubNode) id() peer.ID {
+    return node.host.ID()
+}
+
+func (node *testPubsubNode) hasReceived(data []byte) bool {
+    node.mutex.Lock()
+    defer node.mutex.Unlock()
+    for _, received := range node.received {
+        if (bytes.Equal(received, data)) {
+            return true
+        }
+    }
+    return false
+}
+
+func (node *testPubsubNode) rejectedCount(peerId peer.ID) int {
+    node.mutex.Lock()
+    defer node.mutex.Unlock()
+    return node.rejected[peerId]
+}
+
+// the gossipsub score of the peer, including the app specific score
+func (node *testPubsubNode) score(peerId peer.ID) float64 {
+    return node.scoreInspector.PeerReport(peerId).Score
+}
+
+func (pubsubNetwork *testPubsubNetwork) publish(node int, data []byte) {
+    err := pubsubNetwork.nodes[node].topic.Publish(pubsubNetwork.ctx, data)
+    if (err != nil) {
+        pubsubNetwork.t.Fatalf(`Publish error is "%v" instead of "<nil>"`, err)
+    }
+    // don't overflow the peer outbound queues
+    time.Sleep(10 * time.Millisecond)
+}
+
+// poll the condition until it's true or the timeout
+func (pubsubNetwork *testPubsubNetwork) waitFor(condition func() bool) bool {
+    deadline := time.Now().Add(10 * time.Second)
+    for time.Now().Before(deadline) {
+        if (condition()) {
+            return true
+        }
+        time.Sleep(50 * time.Millisecond)
+    }
+    return condition()
+}
+
+func TestNetworkRelayedHonestTraffic(t *testing.T) {
+    // author <-> relay <-> relay <-> subplebbit
+    pubsubNetwork := newTestPubsubNetwork(t, testPubsubNetworkConfig{
+        nodeCount: 4,
+        links: [][2]int{{0, 1}, {1, 2}, {2, 3}},
+    })
+    author, firstRelay, secondRelay, subplebbit := pubsubNetwork.nodes[0], pubsubNetwork.nodes[1], pubsubNetwork.nodes[2], pubsubNetwork.nodes[3]
+
+    authorPrivateKey := tryGeneratePrivateKey()
+    challengeRequestId, _ := getPeerIdFromPrivateKey(authorPrivateKey)
+    challengeRequest := createSignedMessage("CHALLENGEREQUEST", authorPrivateKey, nil)
+    challengeVerification := createSignedMessage("CHALLENGEVERIFICATION", subplebbitPrivateKey, map[string]interface{}{"challengeRequestId": []byte(challengeRequestId)})
+    pubsubNetwork.publish(0, challengeRequest)
+    received := pubsubNetwork.waitFor(func() bool {
+        return subplebbit.hasReceived(challengeRequest)
+    })
+    if (!received) {
+        t.Fatalf(`challenge request was not received by the subplebbit`)
+    }
+    pubsubNetwork.publish(3, challengeVerification)
+    received = pubsubNetwork.waitFor(func() bool {
+        return author.hasReceived(challengeVerification)
+    })
+    if (!received) {
+        t.Fatalf(`challenge verification was not received by the author`)
+    }
+
+    for i, node := range pubsubNetwork.nodes {
+        if (!node.hasReceived(challengeRequest) || !node.hasReceived(challengeVerification)) {
+            t.Fatalf(`node "%v" didn't receive all the messages`, i)
+        }
+        for _, otherNode := range pubsubNetwork.nodes {
+            if (node.rejectedCount(otherNode.id()) != 0) {
+                t.Fatalf(`node "%v" rejected "%v" messages`, i, node.rejectedCount(otherNode.id()))
+            }
+        }
+    }
+
+    // the relays are credited with the completed challenge
+    relayStatistics := getRelayStatistics(secondRelay.id(), subplebbit.validator)
+    if (relayStatistics.challengeCount != 1 || relayStatistics.completedChallengeCount != 1) {
+        t.Fatalf(`relay statistics are "%+v" instead of 1 completed challenge`, relayStatistics)
+    }
+    authorStatistics := getPeerStatistics(author.id(), secondRelay.validator)
+    if (authorStatistics.challengeCount != 1 || authorStatistics.completedChallengeCount != 1) {
+        t.Fatalf(`author statistics are "%+v" instead of 1 completed challenge`, authorStatistics)
+    }
+    pubsubNetwork.waitFor(func() bool {
+        return firstRelay.scoreInspector.PeerReport(author.id()).Scored
+    })
+    if (firstRelay.score(author.id()) < 0 || secondRelay.score(firstRelay.id()) < 0 || subplebbit.score(secondRelay.id()) < 0) {
+        t.Fatalf(`honest peers have a negative score`)
+    }
+}
+
+func TestNetworkSpamChallengeRequests(t *testing.T) {
+    pubsubNetwork := newTestPubsubNetwork(t, testPubsubNetworkConfig{nodeCount: 4})
+    spammer := pubsubNetwork.nodes[0]
+    scoreConfig, _ := NewScoreConfig(ScorePresetDefault)
+    graylistThreshold := scoreConfig.Thresholds.GraylistThreshold
+
+    // valid challenge requests that never get a challenge verification
+    authorPrivateKey := tryGeneratePrivateKey()
+    for i := 0; i < int(minimumChallengeCount); i++ {
+        pubsubNetwork.publish(0, createSignedMessage("CHALLENGEREQUEST", authorPrivateKey, nil))
+    }
+
+    for i, node := range pubsubNetwork.nodes[1:] {
+        graylisted := pubsubNetwork.waitFor(func() bool {
+            return node.validator.AppSpecificScore(spammer.id()) == worstScore && node.score(spammer.id()) < graylistThreshold
+        })
+        if (!graylisted) {
+            t.Fatalf(`node "%v" spammer score is "%v" instead of below "%v"`, i + 1, node.score(spammer.id()), graylistThreshold)
+        }
+    }
+}
+
+func TestNetworkInvalidMessages(t *testing.T) {
+    pubsubNetwork := newTestPubsubNetwork(t, testPubsubNetworkConfig{nodeCount: 4, unvalidatedNodes: []int{0}})
+    spammer := pubsubNetwork.nodes[0]
+
+    invalidMessages := [][]byte{[]byte("invalid message 1"), []byte("invalid message 2"), []byte("invalid message 3")}
+    for _, invalidMessage := range invalidMessages {
+        pubsubNetwork.publish(0, invalidMessage)
+    }
+
+    // 3 invalid messages: 3² × -1000 × 0.1 = -900
+    for i, node := range pubsubNetwork.nodes[1:] {
+        penalized := pubsubNetwork.waitFor(func() bool {
+            return node.rejectedCount(spammer.id()) == len(invalidMessages) && node.score(spammer.id()) < -500
+        })
+        if (!penalized) {
+            t.Fatalf(`node "%v" rejected "%v" messages and spammer score is "%v"`, i + 1, node.rejectedCount(spammer.id()), node.score(spammer.id()))
+        }
+        for _, invalidMessage := range invalidMessages {
+            if (node.hasReceived(invalidMessage)) {
+                t.Fatalf(`node "%v" received invalid message "%s"`, i + 1, invalidMessage)
+            }
+        }
+    }
+}
+
+func TestNetworkForgedIds(t *testing.T) {
+    pubsubNetwork := newTestPubsubNetwork(t, testPubsubNetworkConfig{nodeCount: 3, unvalidatedNodes: []int{0}})
+    forger := pubsubNetwork.nodes[0]
+    authorPrivateKey := tryGeneratePrivateKey()
+
+    // challenge request id that isn't the multihash of the author public key
+    forgedChallengeRequest := createPubsubChallengeRequestMessage(authorPrivateKey)
+    forgedChallengeRequest["challengeRequestId"] = wrongChallengeRequestId
+    signPubsubMessage(forgedChallengeRequest, authorPrivateKey)
+
+    // challenge verification not signed by the subplebbit
+    forgedChallengeVerification := createPubsubChallengeRequestMessage(authorPrivateKey)
+    forgedChallengeVerification["type"] = "CHALLENGEVERIFICATION"
+    signPubsubMessage(forgedChallengeVerification, authorPrivateKey)
+
+    forgedMessages := [][]byte{cborEncode(forgedChallengeRequest), cborEncode(forgedChallengeVerification)}
+    for _, forgedMessage := range forgedMessages {
+        pubsubNetwork.publish(0, forgedMessage)
+    }
+
+    for i, node := range pubsubNetwork.nodes[1:] {
+        rejected := pubsubNetwork.waitFor(func() bool {
+            return node.rejectedCount(forger.id()) == len(forgedMessages)
+        })
+        if (!rejected) {
+            t.Fatalf(`node "%v" rejected "%v" messages instead of "%v"`, i + 1, node.rejectedCount(forger.id()), len(forgedMessages))
+        }
+        for _, forgedMessage := range forgedMessages {
+            if (node.hasReceived(forgedMessage)) {
+                t.Fatalf(`node "%v" received a forged message`, i + 1)
+            }
+        }
+    }
+}
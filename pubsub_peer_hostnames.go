@@ -1,78 +1,104 @@
-// this file is not used, but could be used later to block peers based on IP
-
 package pubsubPlebbitValidator
 
 import (
-    "fmt"
+    "errors"
+    "net"
+    "strings"
     peer "github.com/libp2p/go-libp2p/core/peer"
     host "github.com/libp2p/go-libp2p/core/host"
+    multiaddr "github.com/multiformats/go-multiaddr"
 )
 
-func getPeerHostnames(peerId peer.ID, host host.Host) ([]string, error) {
-    peerMultiAddresses := host.Peerstore().Addrs(peerId)
-    peerHostnames := make([]string, len(peerMultiAddresses))
-    for i := 0; i < len(peerMultiAddresses); i++ {
-        firstProtocolCode := peerMultiAddresses[i].Protocols()[0].Code
-        firstProtocolValue, err := peerMultiAddresses[i].ValueForProtocol(firstProtocolCode)
-        if (err != nil) {
-            return []string{}, err
-        }
-        peerHostnames[i] = firstProtocolValue
-    }
-    return peerHostnames, nil
+type peerAddress struct {
+    // the IP of an ip4 or ip6 address
+    ip net.IP
+    // the host name of a dns address
+    hostname string
+    // the address goes through a relay, so the IP is the relay's, not the peer's
+    relayed bool
 }
 
-// could be used later to block peers based on IP
-func validatePeerHostnames(message map[string]interface{}, challengeRequestId []byte, peerId peer.ID, messageType string, validator Validator) bool {
-    // nothing to do for challenge message type
-    if (messageType == "CHALLENGE") {
-        return true
-    }
-
-    // get challenge request id string
-    challengeRequestIdString := string(challengeRequestId)
-    if (!validator.challenges.Contains(challengeRequestIdString)) {
-        validator.challenges.Add(challengeRequestIdString, make(map[string]bool))
-    }
-    // get peer hostnames associated with the challenge request id
-    challengePeerHostnames, _ := validator.challenges.Get(challengeRequestIdString)
-
-    // on challenge verification, challenges and peer statistics are updated with the completed challenge
-    if (messageType == "CHALLENGEVERIFICATION") {
-        // update the peer hostname completedChallengeCount
-        for peerHostname := range challengePeerHostnames {
-            if (validator.peersStatistics.Contains(peerHostname)) {
-                peerStatistics, _ := validator.peersStatistics.Get(peerHostname)
-                peerStatistics.completedChallengeCount++
+// extract the IP or host name of a multiaddr and whether it is relayed
+func classifyMultiaddr(address multiaddr.Multiaddr) (peerAddress, error) {
+    classified := peerAddress{}
+    multiaddr.ForEach(address, func(component multiaddr.Component) bool {
+        switch component.Protocol().Code {
+        case multiaddr.P_IP4, multiaddr.P_IP6:
+            // only the first IP is the peer's, the others are after a relay
+            if (classified.ip == nil && classified.hostname == "") {
+                classified.ip = net.IP(component.RawValue())
+            }
+        case multiaddr.P_DNS, multiaddr.P_DNS4, multiaddr.P_DNS6, multiaddr.P_DNSADDR:
+            if (classified.ip == nil && classified.hostname == "") {
+                classified.hostname = strings.ToLower(component.Value())
             }
+        case multiaddr.P_CIRCUIT:
+            classified.relayed = true
         }
-
-        // delete the challenge because it's now completed
-        validator.challenges.Remove(challengeRequestIdString)
         return true
+    })
+    if (classified.ip == nil && classified.hostname == "") {
+        return classified, errors.New("multiaddr has no ip or dns component")
     }
+    return classified, nil
+}
 
-    // the 2 message types left are CHALLENGEREQUEST AND CHALLENGEANSWER
+// IPv4 are bucketed by /24 and IPv6 by /64, because a single user usually controls the whole subnet
+var ipv4BucketMask net.IPMask = net.CIDRMask(24, 32)
+var ipv6BucketMask net.IPMask = net.CIDRMask(64, 128)
 
-    // get the peer hostnames of the message sender
-    peerHostnames, err := getPeerHostnames(peerId, validator.host)
-    if (err != nil) {
-        fmt.Println("failed getPeerHostnames(peerId, host)", err)
-        return false
+func getPeerAddressBucket(address peerAddress) string {
+    if (address.ip == nil) {
+        return address.hostname
     }
+    if ip4 := address.ip.To4(); ip4 != nil {
+        return (&net.IPNet{IP: ip4.Mask(ipv4BucketMask), Mask: ipv4BucketMask}).String()
+    }
+    return (&net.IPNet{IP: address.ip.Mask(ipv6BucketMask), Mask: ipv6BucketMask}).String()
+}
+
+// get the multiaddrs of a peer, prefer the remote address of the actual connections
+// over the addresses advertised in the peerstore, which the peer can choose freely,
+// the peerstore addresses are only used if the peer is not directly connected
+func getPeerMultiaddrs(peerId peer.ID, host host.Host) []multiaddr.Multiaddr {
+    conns := host.Network().ConnsToPeer(peerId)
+    if (len(conns) > 0) {
+        peerMultiaddrs := make([]multiaddr.Multiaddr, len(conns))
+        for i, conn := range conns {
+            peerMultiaddrs[i] = conn.RemoteMultiaddr()
+        }
+        return peerMultiaddrs
+    }
+    return host.Peerstore().Addrs(peerId)
+}
 
-    // a peer can have multiple hostnames, iterate over all
-    for i := 0; i < len(peerHostnames); i++ {
-        // handle setting Validator.peersStatistics
-        if (!validator.peersStatistics.Contains(peerHostnames[i])) {
-            validator.peersStatistics.Add(peerHostnames[i], PeerStatistics{1, 0})
-        } else {
-            peerStatistics, _ := validator.peersStatistics.Get(peerHostnames[i])
-            peerStatistics.challengeCount++
+// get the subnet buckets or dns hostnames of a peer, relayed and invalid addresses are skipped
+func getPeerHostnames(peerId peer.ID, host host.Host) []string {
+    peerHostnames := []string{}
+    seen := map[string]bool{}
+    for _, peerMultiaddr := range getPeerMultiaddrs(peerId, host) {
+        address, err := classifyMultiaddr(peerMultiaddr)
+        if (err != nil || address.relayed) {
+            continue
+        }
+        peerHostname := getPeerAddressBucket(address)
+        if (!seen[peerHostname]) {
+            seen[peerHostname] = true
+            peerHostnames = append(peerHostnames, peerHostname)
         }
+    }
+    return peerHostnames
+}
 
-        // handle setting Validator.challenges
-        challengePeerHostnames[peerHostnames[i]] = true
+// the keys of the peer in Validator.peersStatistics, fallback to the peer id
+// if the peer has no usable hostname, e.g. it is only reachable through a relay
+func getPeerStatisticsKeys(peerId peer.ID, validator Validator) []string {
+    if (!validator.peerHostnameStatistics) {
+        return []string{string(peerId)}
+    }
+    peerHostnames := getPeerHostnames(peerId, validator.host)
+    if (len(peerHostnames) == 0) {
+        return []string{string(peerId)}
     }
-    return true
+    return peerHostnames
 }
@@ -0,0 +1,279 @@
+package pubsubPlebbitValidator
+
+import (
+    "testing"
+    "strconv"
+    pubsub "github.com/libp2p/go-libp2p-pubsub"
+    peer "github.com/libp2p/go-libp2p/core/peer"
+    host "github.com/libp2p/go-libp2p/core/host"
+    network "github.com/libp2p/go-libp2p/core/network"
+    peerstore "github.com/libp2p/go-libp2p/core/peerstore"
+    pstoremem "github.com/libp2p/go-libp2p/p2p/host/peerstore/pstoremem"
+    multiaddr "github.com/multiformats/go-multiaddr"
+)
+
+// host with only a peerstore and connections, to set the peer addresses without a network
+type mockHost struct {
+    host.Host
+    peerstore peerstore.Peerstore
+    network mockNetwork
+}
+
+type mockNetwork struct {
+    network.Network
+    conns map[peer.ID][]network.Conn
+}
+
+type mockConn struct {
+    network.Conn
+    remoteMultiaddr multiaddr.Multiaddr
+}
+
+func (mockHost mockHost) Peerstore() peerstore.Peerstore {
+    return mockHost.peerstore
+}
+
+func (mockHost mockHost) Network() network.Network {
+    return mockHost.network
+}
+
+func (mockNetwork mockNetwork) ConnsToPeer(peerId peer.ID) []network.Conn {
+    return mockNetwork.conns[peerId]
+}
+
+func (mockNetwork mockNetwork) Peers() []peer.ID {
+    peerIds := []peer.ID{}
+    for peerId := range mockNetwork.conns {
+        peerIds = append(peerIds, peerId)
+    }
+    return peerIds
+}
+
+func (mockConn mockConn) RemoteMultiaddr() multiaddr.Multiaddr {
+    return mockConn.remoteMultiaddr
+}
+
+func newMockHost() mockHost {
+    peerstore, err := pstoremem.NewPeerstore()
+    if (err != nil) {
+        panic(err)
+    }
+    return mockHost{nil, peerstore, mockNetwork{nil, map[peer.ID][]network.Conn{}}}
+}
+
+// add a peer connected from the address
+func (mockHost mockHost) addPeer(address string) peer.ID {
+    peerId, err := getPeerIdFromPrivateKey(tryGeneratePrivateKey())
+    if (err != nil) {
+        panic(err)
+    }
+    mockHost.addConn(peerId, address)
+    return peerId
+}
+
+// add a peer that is not directly connected, with an address advertised in the peerstore
+func (mockHost mockHost) addPeerstorePeer(address string) peer.ID {
+    peerId, err := getPeerIdFromPrivateKey(tryGeneratePrivateKey())
+    if (err != nil) {
+        panic(err)
+    }
+    mockHost.peerstore.AddAddr(peerId, multiaddr.StringCast(address), peerstore.PermanentAddrTTL)
+    return peerId
+}
+
+func (mockHost mockHost) addConn(peerId peer.ID, remoteAddress string) {
+    conn := mockConn{nil, multiaddr.StringCast(remoteAddress)}
+    mockHost.network.conns[peerId] = append(mockHost.network.conns[peerId], conn)
+}
+
+// send challenge requests that never get a challenge verification
+func sendFailedChallenges(validator Validator, peerId peer.ID, count int) {
+    for i := 0; i < count; i++ {
+        challengeRequestId := []byte(string(peerId) + strconv.Itoa(i))
+        validatePeer(map[string]interface{}{}, challengeRequestId, peerId, "", "CHALLENGEREQUEST", validator)
+    }
+}
+
+func sendCompletedChallenges(validator Validator, peerId peer.ID, count int) {
+    for i := 0; i < count; i++ {
+        challengeRequestId := []byte(string(peerId) + strconv.Itoa(i))
+        validatePeer(map[string]interface{}{}, challengeRequestId, peerId, "", "CHALLENGEREQUEST", validator)
+        validatePeer(map[string]interface{}{}, challengeRequestId, peerId, "", "CHALLENGEVERIFICATION", validator)
+    }
+}
+
+// the 4 messages of challenges completed by new authors, forwarded by the peer
+func sendChallengeExchanges(t *testing.T, validator Validator, peerId peer.ID, count int) {
+    for i := 0; i < count; i++ {
+        authorPrivateKey := tryGeneratePrivateKey()
+        authorPeerId, _ := getPeerIdFromPrivateKey(authorPrivateKey)
+        challengeRequestId := map[string]interface{}{"challengeRequestId": []byte(authorPeerId)}
+        messages := [][]byte{
+            createSignedMessage("CHALLENGEREQUEST", authorPrivateKey, nil),
+            createSignedMessage("CHALLENGE", subplebbitPrivateKey, challengeRequestId),
+            createSignedMessage("CHALLENGEANSWER", authorPrivateKey, nil),
+            createSignedMessage("CHALLENGEVERIFICATION", subplebbitPrivateKey, challengeRequestId),
+        }
+        for _, message := range messages {
+            result := validateMessage(validator, peerId, subplebbitPeerId.String(), message)
+            if (result != pubsub.ValidationAccept) {
+                t.Fatalf(`challenge exchange validation result is "%v" instead of "%v"`, result, pubsub.ValidationAccept)
+            }
+        }
+    }
+}
+
+func TestClassifyMultiaddr(t *testing.T) {
+    tests := []struct {
+        address string
+        bucket string
+        relayed bool
+    }{
+        {"/ip4/1.2.3.4/tcp/4001", "1.2.3.0/24", false},
+        {"/ip4/1.2.3.4/udp/4001/quic", "1.2.3.0/24", false},
+        {"/ip6/2001:db8:1:2:3:4:5:6/tcp/4001", "2001:db8:1:2::/64", false},
+        {"/ip6zone/eth0/ip6/fe80::1/tcp/4001", "fe80::/64", false},
+        {"/dns4/Example.com/tcp/443/wss", "example.com", false},
+        {"/dns6/example.com/tcp/443", "example.com", false},
+        {"/ip4/1.2.3.4/tcp/4001/p2p/12D3KooWDpJ7As7BWAwRMfu1VU2WCqNjvq387JEYKDBj4kx6nXTN/p2p-circuit", "1.2.3.0/24", true},
+    }
+    for _, test := range tests {
+        address, err := classifyMultiaddr(multiaddr.StringCast(test.address))
+        if (err != nil) {
+            t.Fatalf(`classifyMultiaddr "%v" error is "%v" instead of "<nil>"`, test.address, err)
+        }
+        bucket := getPeerAddressBucket(address)
+        if (bucket != test.bucket) {
+            t.Fatalf(`bucket of "%v" is "%v" instead of "%v"`, test.address, bucket, test.bucket)
+        }
+        if (address.relayed != test.relayed) {
+            t.Fatalf(`relayed of "%v" is "%v" instead of "%v"`, test.address, address.relayed, test.relayed)
+        }
+    }
+
+    _, err := classifyMultiaddr(multiaddr.StringCast("/p2p-circuit"))
+    if (err == nil) {
+        t.Fatalf(`classifyMultiaddr "/p2p-circuit" error is "<nil>"`)
+    }
+}
+
+func TestGetPeerHostnames(t *testing.T) {
+    mockHost := newMockHost()
+
+    // connection addresses in the same subnet are deduplicated, relayed connections are skipped
+    peerId := mockHost.addPeer("/ip4/1.2.3.4/tcp/4001")
+    mockHost.addConn(peerId, "/ip4/1.2.3.5/tcp/4001")
+    mockHost.addConn(peerId, "/ip4/5.6.7.8/tcp/4001/p2p/12D3KooWDpJ7As7BWAwRMfu1VU2WCqNjvq387JEYKDBj4kx6nXTN/p2p-circuit")
+    peerHostnames := getPeerHostnames(peerId, mockHost)
+    if (len(peerHostnames) != 1 || peerHostnames[0] != "1.2.3.0/24") {
+        t.Fatalf(`peer hostnames are "%v" instead of "[1.2.3.0/24]"`, peerHostnames)
+    }
+
+    // the connection addresses are preferred over the advertised peerstore addresses
+    mockHost.peerstore.AddAddr(peerId, multiaddr.StringCast("/ip4/9.9.9.9/tcp/4001"), peerstore.PermanentAddrTTL)
+    peerHostnames = getPeerHostnames(peerId, mockHost)
+    if (len(peerHostnames) != 1 || peerHostnames[0] != "1.2.3.0/24") {
+        t.Fatalf(`peer hostnames are "%v" instead of "[1.2.3.0/24]"`, peerHostnames)
+    }
+
+    // a peer that is not directly connected uses its peerstore addresses
+    peerstorePeerId := mockHost.addPeerstorePeer("/ip4/9.9.9.9/tcp/4001")
+    mockHost.peerstore.AddAddr(peerstorePeerId, multiaddr.StringCast("/ip4/9.9.9.10/tcp/4001"), peerstore.PermanentAddrTTL)
+    validator := NewValidator(mockHost, WithPeerHostnameStatistics())
+    peerStatisticsKeys := getPeerStatisticsKeys(peerstorePeerId, validator)
+    if (len(peerStatisticsKeys) != 1 || peerStatisticsKeys[0] != "9.9.9.0/24") {
+        t.Fatalf(`peerstore peer statistics keys are "%v" instead of "[9.9.9.0/24]"`, peerStatisticsKeys)
+    }
+
+    // a peer without any address uses its peer id
+    unknownPeerId, _ := getPeerIdFromPrivateKey(tryGeneratePrivateKey())
+    peerStatisticsKeys = getPeerStatisticsKeys(unknownPeerId, validator)
+    if (len(peerStatisticsKeys) != 1 || peerStatisticsKeys[0] != string(unknownPeerId)) {
+        t.Fatalf(`unknown peer statistics keys are "%v" instead of "[%v]"`, peerStatisticsKeys, unknownPeerId)
+    }
+
+    // a peer only reachable through a relay falls back to its peer id
+    relayedPeerId := mockHost.addPeer("/ip4/5.6.7.8/tcp/4001/p2p/12D3KooWDpJ7As7BWAwRMfu1VU2WCqNjvq387JEYKDBj4kx6nXTN/p2p-circuit")
+    peerStatisticsKeys = getPeerStatisticsKeys(relayedPeerId, validator)
+    if (len(peerStatisticsKeys) != 1 || peerStatisticsKeys[0] != string(relayedPeerId)) {
+        t.Fatalf(`peer statistics keys are "%v" instead of "[%v]"`, peerStatisticsKeys, relayedPeerId)
+    }
+}
+
+func TestPeerHostnameStatisticsScore(t *testing.T) {
+    mockHost := newMockHost()
+    validator := NewValidator(mockHost, WithPeerHostnameStatistics())
+    validator.now = newTestClock().now
+
+    // a peer that fails all its challenges gets the worst score
+    spamPeerId := mockHost.addPeer("/ip4/1.2.3.4/tcp/4001")
+    sendFailedChallenges(validator, spamPeerId, int(minimumChallengeCount))
+    score := validator.AppSpecificScore(spamPeerId)
+    if (score != worstScore) {
+        t.Fatalf(`spam peer score is "%v" instead of "%v"`, score, worstScore)
+    }
+
+    // a new peer id on the same subnet keeps the score of the subnet
+    rotatedPeerId := mockHost.addPeer("/ip4/1.2.3.100/tcp/4002")
+    score = validator.AppSpecificScore(rotatedPeerId)
+    if (score != worstScore) {
+        t.Fatalf(`rotated peer score is "%v" instead of "%v"`, score, worstScore)
+    }
+
+    // a peer on another IP is not affected
+    otherPeerId := mockHost.addPeer("/ip4/5.6.7.8/tcp/4001")
+    score = validator.AppSpecificScore(otherPeerId)
+    if (score != 0) {
+        t.Fatalf(`other peer score is "%v" instead of "0"`, score)
+    }
+
+    // a peer that completes all its challenges has no penalty
+    sendCompletedChallenges(validator, otherPeerId, int(minimumChallengeCount))
+    score = validator.AppSpecificScore(otherPeerId)
+    if (score != 0) {
+        t.Fatalf(`honest peer score is "%v" instead of "0"`, score)
+    }
+}
+
+func TestPeerIdStatisticsScore(t *testing.T) {
+    mockHost := newMockHost()
+    validator := NewValidator(mockHost)
+    validator.now = newTestClock().now
+
+    spamPeerId := mockHost.addPeer("/ip4/1.2.3.4/tcp/4001")
+    sendFailedChallenges(validator, spamPeerId, int(minimumChallengeCount) - 1)
+    score := validator.AppSpecificScore(spamPeerId)
+    if (score != 0) {
+        t.Fatalf(`spam peer score is "%v" instead of "0" before minimumChallengeCount`, score)
+    }
+    sendFailedChallenges(validator, spamPeerId, int(minimumChallengeCount))
+    score = validator.AppSpecificScore(spamPeerId)
+    if (score != worstScore) {
+        t.Fatalf(`spam peer score is "%v" instead of "%v"`, score, worstScore)
+    }
+
+    // without hostname statistics, a new peer id on the same IP starts fresh
+    rotatedPeerId := mockHost.addPeer("/ip4/1.2.3.4/tcp/4002")
+    score = validator.AppSpecificScore(rotatedPeerId)
+    if (score != 0) {
+        t.Fatalf(`rotated peer score is "%v" instead of "0"`, score)
+    }
+}
+
+func TestChallengeExchangesScore(t *testing.T) {
+    for _, options := range [][]ValidatorOption{{}, {WithPeerHostnameStatistics()}} {
+        mockHost, peerId := newTestHost()
+        validator := NewValidator(mockHost, options...)
+
+        // the challenge answers are not counted as challenges, an honest peer completes all its challenges
+        sendChallengeExchanges(t, validator, peerId, int(minimumChallengeCount))
+        peerStatistics := getPeerStatistics(peerId, validator)
+        if (peerStatistics.challengeCount != float64(minimumChallengeCount) || peerStatistics.completedChallengeCount != float64(minimumChallengeCount)) {
+            t.Fatalf(`challenge counts are "%v" and "%v" instead of "%v" and "%v"`, peerStatistics.challengeCount, peerStatistics.completedChallengeCount, minimumChallengeCount, minimumChallengeCount)
+        }
+        score := validator.AppSpecificScore(peerId)
+        if (score != 0) {
+            t.Fatalf(`honest peer score is "%v" instead of "0"`, score)
+        }
+    }
+}
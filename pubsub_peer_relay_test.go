@@ -0,0 +1,85 @@
+package pubsubPlebbitValidator
+
+import (
+    "testing"
+    "strconv"
+    peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// send challenge requests published by originatorId and forwarded by peerId that never get a challenge verification
+func relayFailedChallenges(validator Validator, peerId peer.ID, originatorId peer.ID, count int) {
+    for i := 0; i < count; i++ {
+        challengeRequestId := []byte(string(originatorId) + strconv.Itoa(i))
+        validatePeer(map[string]interface{}{}, challengeRequestId, peerId, originatorId, "CHALLENGEREQUEST", validator)
+    }
+}
+
+func TestOriginatorAndRelayStatistics(t *testing.T) {
+    mockHost := newMockHost()
+    validator := NewValidator(mockHost)
+    validator.now = newTestClock().now
+    spamPeerId := mockHost.addPeer("/ip4/1.2.3.4/tcp/4001")
+    relayPeerId := mockHost.addPeer("/ip4/5.6.7.8/tcp/4001")
+
+    // the spam is blamed on the original publisher, the relay gets a fraction of the penalty
+    relayFailedChallenges(validator, relayPeerId, spamPeerId, int(minimumChallengeCount))
+    if (validator.AppSpecificScore(spamPeerId) != worstScore) {
+        t.Fatalf(`originator score is "%v" instead of "%v"`, validator.AppSpecificScore(spamPeerId), worstScore)
+    }
+    // the relay is penalized but still gets gossip, it's not graylisted
+    relayScore := validator.AppSpecificScore(relayPeerId)
+    if (relayScore >= 0 || relayScore <= PeerScoreThresholds.GossipThreshold) {
+        t.Fatalf(`relay score is "%v" instead of between the gossip threshold "%v" and 0`, relayScore, PeerScoreThresholds.GossipThreshold)
+    }
+    if (getPeerStatistics(relayPeerId, validator).challengeCount != 0 || getRelayStatistics(relayPeerId, validator).challengeCount != float64(minimumChallengeCount)) {
+        t.Fatalf(`relay statistics are "%+v" and "%+v"`, getPeerStatistics(relayPeerId, validator), getRelayStatistics(relayPeerId, validator))
+    }
+
+    // completed challenges are credited to both the originator and the relay
+    honestPeerId := mockHost.addPeer("/ip4/9.9.9.9/tcp/4001")
+    challengeRequestId := []byte("honest challenge")
+    validatePeer(map[string]interface{}{}, challengeRequestId, relayPeerId, honestPeerId, "CHALLENGEREQUEST", validator)
+    validatePeer(map[string]interface{}{}, challengeRequestId, relayPeerId, "", "CHALLENGEVERIFICATION", validator)
+    if (getPeerStatistics(honestPeerId, validator).completedChallengeCount != 1 || getRelayStatistics(relayPeerId, validator).completedChallengeCount != 1) {
+        t.Fatalf(`completed statistics are "%+v" and "%+v"`, getPeerStatistics(honestPeerId, validator), getRelayStatistics(relayPeerId, validator))
+    }
+
+    // a trusted relay is exempt, the originator is still penalized
+    trustedValidator := NewValidator(mockHost, WithTrustedPeers(relayPeerId))
+    trustedValidator.now = newTestClock().now
+    relayFailedChallenges(trustedValidator, relayPeerId, spamPeerId, int(minimumChallengeCount))
+    if (getRelayStatistics(relayPeerId, trustedValidator).challengeCount != 0 || trustedValidator.AppSpecificScore(spamPeerId) != worstScore) {
+        t.Fatalf(`trusted relay statistics are "%+v" and originator score is "%v"`, getRelayStatistics(relayPeerId, trustedValidator), trustedValidator.AppSpecificScore(spamPeerId))
+    }
+}
+
+func TestOriginatorAndRelayTopology(t *testing.T) {
+    // spam peer <-> relay peer <-> validator peer, the spam peer is not connected to the validator peer
+    pubsubNetwork := newTestPubsubNetwork(t, testPubsubNetworkConfig{
+        nodeCount: 3,
+        links: [][2]int{{0, 1}, {1, 2}},
+    })
+    spamPeerId, relayPeerId, validator := pubsubNetwork.nodes[0].id(), pubsubNetwork.nodes[1].id(), pubsubNetwork.nodes[2].validator
+
+    // the spam peer publishes signed challenge requests that never get a challenge verification
+    authorPrivateKey := tryGeneratePrivateKey()
+    for i := 0; i < int(minimumChallengeCount); i++ {
+        pubsubNetwork.publish(0, createSignedMessage("CHALLENGEREQUEST", authorPrivateKey, nil))
+    }
+    pubsubNetwork.waitFor(func() bool {
+        return getPeerStatistics(spamPeerId, validator).challengeCount == float64(minimumChallengeCount)
+    })
+
+    // the spam is attributed to the original publisher, not to the relay that forwarded it
+    spamScore := validator.AppSpecificScore(spamPeerId)
+    relayScore := validator.AppSpecificScore(relayPeerId)
+    if (spamScore != worstScore) {
+        t.Fatalf(`spam peer score is "%v" instead of "%v"`, spamScore, worstScore)
+    }
+    if (relayScore >= 0 || relayScore <= PeerScoreThresholds.GossipThreshold) {
+        t.Fatalf(`relay peer score is "%v" instead of between the gossip threshold "%v" and 0`, relayScore, PeerScoreThresholds.GossipThreshold)
+    }
+    if (getPeerStatistics(relayPeerId, validator).challengeCount != 0) {
+        t.Fatalf(`relay peer published challenge count is "%v" instead of "0"`, getPeerStatistics(relayPeerId, validator).challengeCount)
+    }
+}
@@ -1,45 +1,59 @@
 package pubsubPlebbitValidator
 
 import (
+    "errors"
+    "fmt"
+    "math"
     "time"
     "net"
     pubsub "github.com/libp2p/go-libp2p-pubsub"
     peer "github.com/libp2p/go-libp2p/core/peer"
 )
 
-// values copied from https://github.com/filecoin-project/lotus/blob/42d2f4d7e48104c4b8c6f19720e4eef369976442/node/modules/lp2p/pubsub.go
-var PeerScoreParams pubsub.PeerScoreParams = pubsub.PeerScoreParams{
-    AppSpecificScore: func(p peer.ID) float64 {
-        return 0
-    },
-    AppSpecificWeight: 1,
+type ScorePreset string
+
+const (
+    // tolerate more failed challenges and colocated peers before graylisting, fewer false positives on honest relays
+    ScorePresetConservative ScorePreset = "conservative"
+    // values copied from lotus
+    ScorePresetDefault ScorePreset = "default"
+    // graylist spammers faster and remember them longer, more false positives on honest relays
+    ScorePresetAggressive ScorePreset = "aggressive"
+)
+
+// the peer score params and thresholds, create with NewScoreConfig and override with the With methods
+type ScoreConfig struct {
+    AppSpecificWeight float64
 
-    // This sets the IP colocation threshold to 5 peers before we apply penalties
-    IPColocationFactorThreshold: 5,
-    IPColocationFactorWeight:    -100,
-    IPColocationFactorWhitelist: []*net.IPNet{},
+    // cap the positive topic scores, so they can't offset the app specific score
+    TopicScoreCap float64
 
-    // P7: behavioural penalties, decay after 1hr
-    BehaviourPenaltyThreshold: 6,
-    BehaviourPenaltyWeight:    -10,
-    BehaviourPenaltyDecay:     pubsub.ScoreParameterDecay(time.Hour),
+    // P6: IP colocation, penalize more than IPColocationFactorThreshold peers on the same IP
+    IPColocationFactorThreshold int
+    IPColocationFactorWeight float64
+    IPColocationFactorWhitelist []*net.IPNet
 
-    DecayInterval: pubsub.DefaultDecayInterval,
-    DecayToZero:   pubsub.DefaultDecayToZero,
+    // P7: behavioural penalties, BehaviourPenaltyDecay is the time for the penalty to decay to zero
+    BehaviourPenaltyThreshold float64
+    BehaviourPenaltyWeight float64
+    BehaviourPenaltyDecay time.Duration
 
-    // this retains non-positive scores for 6 hours
-    RetainScore: 6 * time.Hour,
+    DecayInterval time.Duration
+    DecayToZero float64
 
-    // topic parameters
-    // in plebbit all topics are equal so dont set any
-    Topics: map[string]*pubsub.TopicScoreParams{},
+    // time to retain non-positive scores of disconnected peers
+    RetainScore time.Duration
+
+    Thresholds pubsub.PeerScoreThresholds
 }
 
-func NewPeerScoreParams(validator Validator) pubsub.PeerScoreParams {
-    return pubsub.PeerScoreParams{
-        AppSpecificScore: validator.AppSpecificScore,
+// values copied from https://github.com/filecoin-project/lotus/blob/42d2f4d7e48104c4b8c6f19720e4eef369976442/node/modules/lp2p/pubsub.go
+func defaultScoreConfig() *ScoreConfig {
+    return &ScoreConfig{
         AppSpecificWeight: 1,
 
+        TopicScoreCap: 10,
+
         // This sets the IP colocation threshold to 5 peers before we apply penalties
         IPColocationFactorThreshold: 5,
         IPColocationFactorWeight:    -100,
@@ -48,7 +62,7 @@ func NewPeerScoreParams(validator Validator) pubsub.PeerScoreParams {
         // P7: behavioural penalties, decay after 1hr
         BehaviourPenaltyThreshold: 6,
         BehaviourPenaltyWeight:    -10,
-        BehaviourPenaltyDecay:     pubsub.ScoreParameterDecay(time.Hour),
+        BehaviourPenaltyDecay:     time.Hour,
 
         DecayInterval: pubsub.DefaultDecayInterval,
         DecayToZero:   pubsub.DefaultDecayToZero,
@@ -56,17 +70,300 @@ func NewPeerScoreParams(validator Validator) pubsub.PeerScoreParams {
         // this retains non-positive scores for 6 hours
         RetainScore: 6 * time.Hour,
 
+        Thresholds: pubsub.PeerScoreThresholds{
+            GossipThreshold: -500,
+            PublishThreshold: -1000,
+            GraylistThreshold: -2500,
+            // only accept PX from trusted peers, see WithTrustedPeers
+            AcceptPXThreshold: 1000,
+            OpportunisticGraftThreshold: 3.5,
+        },
+    }
+}
+
+func NewScoreConfig(preset ScorePreset) (*ScoreConfig, error) {
+    scoreConfig := defaultScoreConfig()
+    switch preset {
+    case ScorePresetDefault:
+    case ScorePresetConservative:
+        scoreConfig.IPColocationFactorThreshold = 10
+        scoreConfig.IPColocationFactorWeight = -50
+        scoreConfig.BehaviourPenaltyThreshold = 10
+        scoreConfig.BehaviourPenaltyWeight = -5
+        scoreConfig.BehaviourPenaltyDecay = 30 * time.Minute
+        scoreConfig.RetainScore = time.Hour
+        // a challenge failure ratio of ~30% before graylisting instead of ~15%
+        scoreConfig.Thresholds.GossipThreshold = -2500
+        scoreConfig.Thresholds.PublishThreshold = -5000
+        scoreConfig.Thresholds.GraylistThreshold = -10000
+    case ScorePresetAggressive:
+        scoreConfig.IPColocationFactorThreshold = 3
+        scoreConfig.IPColocationFactorWeight = -200
+        scoreConfig.BehaviourPenaltyThreshold = 3
+        scoreConfig.BehaviourPenaltyWeight = -20
+        scoreConfig.BehaviourPenaltyDecay = 2 * time.Hour
+        scoreConfig.RetainScore = 24 * time.Hour
+        // a challenge failure ratio of ~10% before graylisting instead of ~15%
+        scoreConfig.Thresholds.GossipThreshold = -250
+        scoreConfig.Thresholds.PublishThreshold = -500
+        scoreConfig.Thresholds.GraylistThreshold = -1000
+    default:
+        return nil, errors.New("unknown score preset " + string(preset))
+    }
+    return scoreConfig, nil
+}
+
+func (scoreConfig *ScoreConfig) WithAppSpecificWeight(weight float64) *ScoreConfig {
+    scoreConfig.AppSpecificWeight = weight
+    return scoreConfig
+}
+
+func (scoreConfig *ScoreConfig) WithTopicScoreCap(topicScoreCap float64) *ScoreConfig {
+    scoreConfig.TopicScoreCap = topicScoreCap
+    return scoreConfig
+}
+
+func (scoreConfig *ScoreConfig) WithIPColocation(threshold int, weight float64, whitelist ...*net.IPNet) *ScoreConfig {
+    scoreConfig.IPColocationFactorThreshold = threshold
+    scoreConfig.IPColocationFactorWeight = weight
+    scoreConfig.IPColocationFactorWhitelist = whitelist
+    return scoreConfig
+}
+
+func (scoreConfig *ScoreConfig) WithBehaviourPenalty(threshold float64, weight float64, decay time.Duration) *ScoreConfig {
+    scoreConfig.BehaviourPenaltyThreshold = threshold
+    scoreConfig.BehaviourPenaltyWeight = weight
+    scoreConfig.BehaviourPenaltyDecay = decay
+    return scoreConfig
+}
+
+func (scoreConfig *ScoreConfig) WithDecay(interval time.Duration, decayToZero float64) *ScoreConfig {
+    scoreConfig.DecayInterval = interval
+    scoreConfig.DecayToZero = decayToZero
+    return scoreConfig
+}
+
+func (scoreConfig *ScoreConfig) WithRetainScore(retainScore time.Duration) *ScoreConfig {
+    scoreConfig.RetainScore = retainScore
+    return scoreConfig
+}
+
+func (scoreConfig *ScoreConfig) WithThresholds(thresholds pubsub.PeerScoreThresholds) *ScoreConfig {
+    scoreConfig.Thresholds = thresholds
+    return scoreConfig
+}
+
+func (scoreConfig *ScoreConfig) peerScoreParams(appSpecificScore func(peer.ID) float64, ipColocationFactorWhitelist []*net.IPNet) pubsub.PeerScoreParams {
+    whitelist := append([]*net.IPNet{}, scoreConfig.IPColocationFactorWhitelist...)
+    whitelist = append(whitelist, ipColocationFactorWhitelist...)
+    behaviourPenaltyDecay := 0.0
+    if (scoreConfig.BehaviourPenaltyDecay > 0 && scoreConfig.DecayInterval > 0) {
+        behaviourPenaltyDecay = pubsub.ScoreParameterDecayWithBase(scoreConfig.BehaviourPenaltyDecay, scoreConfig.DecayInterval, scoreConfig.DecayToZero)
+    }
+    return pubsub.PeerScoreParams{
+        AppSpecificScore: appSpecificScore,
+        AppSpecificWeight: scoreConfig.AppSpecificWeight,
+
+        TopicScoreCap: scoreConfig.TopicScoreCap,
+
+        IPColocationFactorThreshold: scoreConfig.IPColocationFactorThreshold,
+        IPColocationFactorWeight: scoreConfig.IPColocationFactorWeight,
+        IPColocationFactorWhitelist: whitelist,
+
+        BehaviourPenaltyThreshold: scoreConfig.BehaviourPenaltyThreshold,
+        BehaviourPenaltyWeight: scoreConfig.BehaviourPenaltyWeight,
+        BehaviourPenaltyDecay: behaviourPenaltyDecay,
+
+        DecayInterval: scoreConfig.DecayInterval,
+        DecayToZero: scoreConfig.DecayToZero,
+
+        RetainScore: scoreConfig.RetainScore,
+
         // topic parameters
-        // in plebbit all topics are equal so dont set any
+        // subplebbit topics are joined dynamically, their params are set with JoinTopic
         Topics: map[string]*pubsub.TopicScoreParams{},
     }
 }
 
-// values copied from https://github.com/filecoin-project/lotus/blob/42d2f4d7e48104c4b8c6f19720e4eef369976442/node/modules/lp2p/pubsub.go
-var PeerScoreThresholds pubsub.PeerScoreThresholds = pubsub.PeerScoreThresholds{
-    GossipThreshold: -500,
-    PublishThreshold: -1000,
-    GraylistThreshold: -2500,
-    AcceptPXThreshold: 1000,
-    OpportunisticGraftThreshold: 3.5,
+// build the peer score params and thresholds, the ip ranges allowed in the validator
+// access list are added to the IPColocationFactorWhitelist, changes after creation are not applied
+func (scoreConfig *ScoreConfig) Build(validator Validator) (*pubsub.PeerScoreParams, *pubsub.PeerScoreThresholds, error) {
+    peerScoreParams := scoreConfig.peerScoreParams(validator.AppSpecificScore, validator.accessList.AllowedIpRanges())
+    err := validatePeerScoreParams(peerScoreParams)
+    if (err != nil) {
+        return nil, nil, err
+    }
+    peerScoreThresholds := scoreConfig.Thresholds
+    err = validatePeerScoreThresholds(peerScoreThresholds)
+    if (err != nil) {
+        return nil, nil, err
+    }
+    if (len(validator.trustedPeers) > 0) {
+        err = validateTrustedPeerScore(peerScoreParams.AppSpecificWeight, peerScoreThresholds)
+        if (err != nil) {
+            return nil, nil, err
+        }
+    }
+    return &peerScoreParams, &peerScoreThresholds, nil
+}
+
+// the pubsub.WithPeerScore option with the built params and thresholds
+func (scoreConfig *ScoreConfig) PubsubOption(validator Validator) (pubsub.Option, error) {
+    peerScoreParams, peerScoreThresholds, err := scoreConfig.Build(validator)
+    if (err != nil) {
+        return nil, err
+    }
+    return pubsub.WithPeerScore(peerScoreParams, peerScoreThresholds), nil
+}
+
+func isInvalidNumber(number float64) bool {
+    return math.IsNaN(number) || math.IsInf(number, 0)
+}
+
+// same checks as the unexported pubsub.PeerScoreParams.validate(), so misconfiguration fails when building
+func validatePeerScoreParams(params pubsub.PeerScoreParams) error {
+    for topic, topicParams := range params.Topics {
+        err := validateTopicScoreParams(topicParams)
+        if (err != nil) {
+            return fmt.Errorf("invalid score parameters for topic %s: %w", topic, err)
+        }
+    }
+    if (params.TopicScoreCap < 0 || isInvalidNumber(params.TopicScoreCap)) {
+        return errors.New("invalid TopicScoreCap; must be positive (or 0 for no cap) and a valid number")
+    }
+    if (params.AppSpecificScore == nil) {
+        return errors.New("missing AppSpecificScore function")
+    }
+    if (params.IPColocationFactorWeight > 0 || isInvalidNumber(params.IPColocationFactorWeight)) {
+        return errors.New("invalid IPColocationFactorWeight; must be negative (or 0 to disable) and a valid number")
+    }
+    if (params.IPColocationFactorWeight != 0 && params.IPColocationFactorThreshold < 1) {
+        return errors.New("invalid IPColocationFactorThreshold; must be at least 1")
+    }
+    if (params.BehaviourPenaltyWeight > 0 || isInvalidNumber(params.BehaviourPenaltyWeight)) {
+        return errors.New("invalid BehaviourPenaltyWeight; must be negative (or 0 to disable) and a valid number")
+    }
+    if (params.BehaviourPenaltyWeight != 0 && (params.BehaviourPenaltyDecay <= 0 || params.BehaviourPenaltyDecay >= 1 || isInvalidNumber(params.BehaviourPenaltyDecay))) {
+        return errors.New("invalid BehaviourPenaltyDecay; must be between 0 and 1, the decay time must be longer than DecayInterval")
+    }
+    if (params.BehaviourPenaltyThreshold < 0 || isInvalidNumber(params.BehaviourPenaltyThreshold)) {
+        return errors.New("invalid BehaviourPenaltyThreshold; must be >= 0 and a valid number")
+    }
+    if (params.DecayInterval < time.Second) {
+        return errors.New("invalid DecayInterval; must be at least 1s")
+    }
+    if (params.DecayToZero <= 0 || params.DecayToZero >= 1 || isInvalidNumber(params.DecayToZero)) {
+        return errors.New("invalid DecayToZero; must be between 0 and 1")
+    }
+    return nil
 }
+
+// same checks as the unexported pubsub.TopicScoreParams.validate()
+func validateTopicScoreParams(params *pubsub.TopicScoreParams) error {
+    if (params.TopicWeight < 0 || isInvalidNumber(params.TopicWeight)) {
+        return errors.New("invalid TopicWeight; must be >= 0 and a valid number")
+    }
+
+    // P1
+    if (params.TimeInMeshQuantum == 0) {
+        return errors.New("invalid TimeInMeshQuantum; must be non zero")
+    }
+    if (params.TimeInMeshWeight < 0 || isInvalidNumber(params.TimeInMeshWeight)) {
+        return errors.New("invalid TimeInMeshWeight; must be positive (or 0 to disable) and a valid number")
+    }
+    if (params.TimeInMeshWeight != 0 && params.TimeInMeshQuantum <= 0) {
+        return errors.New("invalid TimeInMeshQuantum; must be positive")
+    }
+    if (params.TimeInMeshWeight != 0 && (params.TimeInMeshCap <= 0 || isInvalidNumber(params.TimeInMeshCap))) {
+        return errors.New("invalid TimeInMeshCap; must be positive and a valid number")
+    }
+
+    // P2
+    if (params.FirstMessageDeliveriesWeight < 0 || isInvalidNumber(params.FirstMessageDeliveriesWeight)) {
+        return errors.New("invalid FirstMessageDeliveriesWeight; must be positive (or 0 to disable) and a valid number")
+    }
+    if (params.FirstMessageDeliveriesWeight != 0 && (params.FirstMessageDeliveriesDecay <= 0 || params.FirstMessageDeliveriesDecay >= 1 || isInvalidNumber(params.FirstMessageDeliveriesDecay))) {
+        return errors.New("invalid FirstMessageDeliveriesDecay; must be between 0 and 1")
+    }
+    if (params.FirstMessageDeliveriesWeight != 0 && (params.FirstMessageDeliveriesCap <= 0 || isInvalidNumber(params.FirstMessageDeliveriesCap))) {
+        return errors.New("invalid FirstMessageDeliveriesCap; must be positive and a valid number")
+    }
+
+    // P3
+    if (params.MeshMessageDeliveriesWeight > 0 || isInvalidNumber(params.MeshMessageDeliveriesWeight)) {
+        return errors.New("invalid MeshMessageDeliveriesWeight; must be negative (or 0 to disable) and a valid number")
+    }
+    if (params.MeshMessageDeliveriesWeight != 0 && (params.MeshMessageDeliveriesDecay <= 0 || params.MeshMessageDeliveriesDecay >= 1 || isInvalidNumber(params.MeshMessageDeliveriesDecay))) {
+        return errors.New("invalid MeshMessageDeliveriesDecay; must be between 0 and 1")
+    }
+    if (params.MeshMessageDeliveriesWeight != 0 && (params.MeshMessageDeliveriesCap <= 0 || isInvalidNumber(params.MeshMessageDeliveriesCap))) {
+        return errors.New("invalid MeshMessageDeliveriesCap; must be positive and a valid number")
+    }
+    if (params.MeshMessageDeliveriesWeight != 0 && (params.MeshMessageDeliveriesThreshold <= 0 || isInvalidNumber(params.MeshMessageDeliveriesThreshold))) {
+        return errors.New("invalid MeshMessageDeliveriesThreshold; must be positive and a valid number")
+    }
+    if (params.MeshMessageDeliveriesWindow < 0) {
+        return errors.New("invalid MeshMessageDeliveriesWindow; must be non-negative")
+    }
+    if (params.MeshMessageDeliveriesWeight != 0 && params.MeshMessageDeliveriesActivation < time.Second) {
+        return errors.New("invalid MeshMessageDeliveriesActivation; must be at least 1s")
+    }
+
+    // P3b
+    if (params.MeshFailurePenaltyWeight > 0 || isInvalidNumber(params.MeshFailurePenaltyWeight)) {
+        return errors.New("invalid MeshFailurePenaltyWeight; must be negative (or 0 to disable) and a valid number")
+    }
+    if (params.MeshFailurePenaltyWeight != 0 && (params.MeshFailurePenaltyDecay <= 0 || params.MeshFailurePenaltyDecay >= 1 || isInvalidNumber(params.MeshFailurePenaltyDecay))) {
+        return errors.New("invalid MeshFailurePenaltyDecay; must be between 0 and 1")
+    }
+
+    // P4
+    if (params.InvalidMessageDeliveriesWeight > 0 || isInvalidNumber(params.InvalidMessageDeliveriesWeight)) {
+        return errors.New("invalid InvalidMessageDeliveriesWeight; must be negative (or 0 to disable) and a valid number")
+    }
+    if (params.InvalidMessageDeliveriesDecay <= 0 || params.InvalidMessageDeliveriesDecay >= 1 || isInvalidNumber(params.InvalidMessageDeliveriesDecay)) {
+        return errors.New("invalid InvalidMessageDeliveriesDecay; must be between 0 and 1")
+    }
+    return nil
+}
+
+// same checks as the unexported pubsub.PeerScoreThresholds.validate()
+func validatePeerScoreThresholds(thresholds pubsub.PeerScoreThresholds) error {
+    if (thresholds.GossipThreshold > 0 || isInvalidNumber(thresholds.GossipThreshold)) {
+        return errors.New("invalid GossipThreshold; must be <= 0 and a valid number")
+    }
+    if (thresholds.PublishThreshold > 0 || thresholds.PublishThreshold > thresholds.GossipThreshold || isInvalidNumber(thresholds.PublishThreshold)) {
+        return errors.New("invalid PublishThreshold; must be <= 0 and <= GossipThreshold and a valid number")
+    }
+    if (thresholds.GraylistThreshold > 0 || thresholds.GraylistThreshold > thresholds.PublishThreshold || isInvalidNumber(thresholds.GraylistThreshold)) {
+        return errors.New("invalid GraylistThreshold; must be <= 0 and <= PublishThreshold and a valid number")
+    }
+    if (thresholds.AcceptPXThreshold < 0 || isInvalidNumber(thresholds.AcceptPXThreshold)) {
+        return errors.New("invalid AcceptPXThreshold; must be >= 0 and a valid number")
+    }
+    if (thresholds.OpportunisticGraftThreshold < 0 || isInvalidNumber(thresholds.OpportunisticGraftThreshold)) {
+        return errors.New("invalid OpportunisticGraftThreshold; must be >= 0 and a valid number")
+    }
+    return nil
+}
+
+// PX is only accepted from the trusted peers, their weighted app specific score must reach AcceptPXThreshold
+func validateTrustedPeerScore(appSpecificWeight float64, thresholds pubsub.PeerScoreThresholds) error {
+    if (trustedPeerScore * appSpecificWeight < thresholds.AcceptPXThreshold) {
+        return fmt.Errorf("invalid AppSpecificWeight; the trusted peer score %v × %v is below AcceptPXThreshold %v", trustedPeerScore, appSpecificWeight, thresholds.AcceptPXThreshold)
+    }
+    return nil
+}
+
+// deprecated: use NewScoreConfig(ScorePresetDefault).Build(validator), the app specific score is always 0
+var PeerScoreParams pubsub.PeerScoreParams = defaultScoreConfig().peerScoreParams(func(p peer.ID) float64 {
+    return 0
+}, []*net.IPNet{})
+
+// the ScorePresetDefault params, use NewScoreConfig to configure them
+func NewPeerScoreParams(validator Validator) pubsub.PeerScoreParams {
+    return defaultScoreConfig().peerScoreParams(validator.AppSpecificScore, validator.accessList.AllowedIpRanges())
+}
+
+// deprecated: use NewScoreConfig(ScorePresetDefault).Build(validator) instead of sharing a mutable global
+var PeerScoreThresholds pubsub.PeerScoreThresholds = defaultScoreConfig().Thresholds
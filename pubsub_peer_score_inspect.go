@@ -0,0 +1,125 @@
+package pubsubPlebbitValidator
+
+import (
+    "sort"
+    "sync"
+    "time"
+    pubsub "github.com/libp2p/go-libp2p-pubsub"
+    peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// the gossipsub score of a peer merged with its challenge statistics
+type PeerReport struct {
+    PeerId peer.ID
+    // false if gossipsub has no score for the peer, e.g. it was never connected
+    Scored bool
+    Score float64
+    AppSpecificScore float64
+    IPColocationFactor float64
+    BehaviourPenalty float64
+    Topics map[string]*pubsub.TopicScoreSnapshot
+    // the challenges published by the peer
+    ChallengeCount float64
+    CompletedChallengeCount float64
+    // the challenges relayed by the peer for other publishers
+    RelayedChallengeCount float64
+    RelayedCompletedChallengeCount float64
+}
+
+// keeps the latest gossipsub peer score snapshots, pass PubsubOption to pubsub.NewGossipSub
+// after the pubsub.WithPeerScore option
+type ScoreInspector struct {
+    validator Validator
+    mutex *sync.RWMutex
+    snapshots map[peer.ID]*pubsub.PeerScoreSnapshot
+}
+
+func NewScoreInspector(validator Validator) *ScoreInspector {
+    return &ScoreInspector{
+        validator: validator,
+        mutex: &sync.RWMutex{},
+        snapshots: map[peer.ID]*pubsub.PeerScoreSnapshot{},
+    }
+}
+
+// the pubsub.WithPeerScoreInspect option, the snapshots are updated every period
+func (scoreInspector *ScoreInspector) PubsubOption(period time.Duration) pubsub.Option {
+    return pubsub.WithPeerScoreInspect(scoreInspector.inspect, period)
+}
+
+func (scoreInspector *ScoreInspector) inspect(snapshots map[peer.ID]*pubsub.PeerScoreSnapshot) {
+    scoreInspector.mutex.Lock()
+    defer scoreInspector.mutex.Unlock()
+    scoreInspector.snapshots = snapshots
+}
+
+func (scoreInspector *ScoreInspector) PeerReport(peerId peer.ID) PeerReport {
+    scoreInspector.mutex.RLock()
+    snapshot := scoreInspector.snapshots[peerId]
+    scoreInspector.mutex.RUnlock()
+    return scoreInspector.peerReport(peerId, snapshot)
+}
+
+func (scoreInspector *ScoreInspector) peerReport(peerId peer.ID, snapshot *pubsub.PeerScoreSnapshot) PeerReport {
+    peerStatistics := getPeerStatistics(peerId, scoreInspector.validator)
+    relayStatistics := getRelayStatistics(peerId, scoreInspector.validator)
+    peerReport := PeerReport{
+        PeerId: peerId,
+        ChallengeCount: peerStatistics.challengeCount,
+        CompletedChallengeCount: peerStatistics.completedChallengeCount,
+        RelayedChallengeCount: relayStatistics.challengeCount,
+        RelayedCompletedChallengeCount: relayStatistics.completedChallengeCount,
+    }
+    if (snapshot != nil) {
+        peerReport.Scored = true
+        peerReport.Score = snapshot.Score
+        peerReport.AppSpecificScore = snapshot.AppSpecificScore
+        peerReport.IPColocationFactor = snapshot.IPColocationFactor
+        peerReport.BehaviourPenalty = snapshot.BehaviourPenalty
+        peerReport.Topics = snapshot.Topics
+    }
+    return peerReport
+}
+
+// the reports of all the peers scored by gossipsub, sorted by score from best to worst
+func (scoreInspector *ScoreInspector) PeerReports() []PeerReport {
+    scoreInspector.mutex.RLock()
+    snapshots := scoreInspector.snapshots
+    scoreInspector.mutex.RUnlock()
+
+    peerReports := make([]PeerReport, 0, len(snapshots))
+    for peerId, snapshot := range snapshots {
+        peerReports = append(peerReports, scoreInspector.peerReport(peerId, snapshot))
+    }
+    sort.Slice(peerReports, func(i, j int) bool {
+        if (peerReports[i].Score == peerReports[j].Score) {
+            return peerReports[i].PeerId < peerReports[j].PeerId
+        }
+        return peerReports[i].Score > peerReports[j].Score
+    })
+    return peerReports
+}
+
+// the n peers with the best score
+func (scoreInspector *ScoreInspector) TopPeers(n int) []PeerReport {
+    return firstPeerReports(scoreInspector.PeerReports(), n)
+}
+
+// the n peers with the worst score, from worst to best
+func (scoreInspector *ScoreInspector) WorstPeers(n int) []PeerReport {
+    peerReports := scoreInspector.PeerReports()
+    for i, j := 0, len(peerReports) - 1; i < j; i, j = i + 1, j - 1 {
+        peerReports[i], peerReports[j] = peerReports[j], peerReports[i]
+    }
+    return firstPeerReports(peerReports, n)
+}
+
+func firstPeerReports(peerReports []PeerReport, n int) []PeerReport {
+    if (n < 0) {
+        n = 0
+    }
+    if (n < len(peerReports)) {
+        peerReports = peerReports[:n]
+    }
+    return peerReports
+}
@@ -0,0 +1,91 @@
+package pubsubPlebbitValidator
+
+import (
+    "testing"
+    "context"
+    "time"
+    pubsub "github.com/libp2p/go-libp2p-pubsub"
+    peer "github.com/libp2p/go-libp2p/core/peer"
+    mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+func TestScoreInspectorReports(t *testing.T) {
+    mockHost := newMockHost()
+    validator := NewValidator(mockHost)
+    validator.now = newTestClock().now
+    scoreInspector := NewScoreInspector(validator)
+    goodPeerId := mockHost.addPeer("/ip4/1.2.3.4/tcp/4001")
+    badPeerId := mockHost.addPeer("/ip4/5.6.7.8/tcp/4001")
+    neutralPeerId := mockHost.addPeer("/ip4/9.9.9.9/tcp/4001")
+    unscoredPeerId := mockHost.addPeer("/ip4/9.9.9.10/tcp/4001")
+    sendCompletedChallenges(validator, goodPeerId, 3)
+    sendFailedChallenges(validator, badPeerId, 5)
+
+    scoreInspector.inspect(map[peer.ID]*pubsub.PeerScoreSnapshot{
+        goodPeerId: &pubsub.PeerScoreSnapshot{Score: 10},
+        badPeerId: &pubsub.PeerScoreSnapshot{Score: -1000, AppSpecificScore: -1000},
+        neutralPeerId: &pubsub.PeerScoreSnapshot{Score: 0},
+    })
+
+    peerReport := scoreInspector.PeerReport(badPeerId)
+    if (!peerReport.Scored || peerReport.Score != -1000 || peerReport.AppSpecificScore != -1000) {
+        t.Fatalf(`bad peer report is "%+v"`, peerReport)
+    }
+    if (peerReport.ChallengeCount != 5 || peerReport.CompletedChallengeCount != 0) {
+        t.Fatalf(`bad peer report challenge counts are "%v" and "%v" instead of "5" and "0"`, peerReport.ChallengeCount, peerReport.CompletedChallengeCount)
+    }
+    peerReport = scoreInspector.PeerReport(goodPeerId)
+    if (peerReport.ChallengeCount != 3 || peerReport.CompletedChallengeCount != 3) {
+        t.Fatalf(`good peer report challenge counts are "%v" and "%v" instead of "3" and "3"`, peerReport.ChallengeCount, peerReport.CompletedChallengeCount)
+    }
+    peerReport = scoreInspector.PeerReport(unscoredPeerId)
+    if (peerReport.Scored) {
+        t.Fatalf(`unscored peer report is scored`)
+    }
+
+    topPeers := scoreInspector.TopPeers(2)
+    if (len(topPeers) != 2 || topPeers[0].PeerId != goodPeerId || topPeers[1].PeerId != neutralPeerId) {
+        t.Fatalf(`top peers are "%+v"`, topPeers)
+    }
+    worstPeers := scoreInspector.WorstPeers(1)
+    if (len(worstPeers) != 1 || worstPeers[0].PeerId != badPeerId) {
+        t.Fatalf(`worst peers are "%+v"`, worstPeers)
+    }
+    if (len(scoreInspector.WorstPeers(10)) != 3 || len(scoreInspector.TopPeers(-1)) != 0) {
+        t.Fatalf(`peer reports are not limited to the scored peers`)
+    }
+}
+
+func TestScoreInspectorPubsubOption(t *testing.T) {
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    testNetwork, err := mocknet.FullMeshConnected(2)
+    if (err != nil) {
+        t.Fatalf(`mocknet error is "%v" instead of "<nil>"`, err)
+    }
+    defer testNetwork.Close()
+    host, otherHost := testNetwork.Hosts()[0], testNetwork.Hosts()[1]
+
+    validator := NewValidator(host)
+    scoreInspector := NewScoreInspector(validator)
+    scoreConfig, _ := NewScoreConfig(ScorePresetDefault)
+    peerScoreOption, _ := scoreConfig.PubsubOption(validator)
+    _, err = pubsub.NewGossipSub(ctx, host, peerScoreOption, scoreInspector.PubsubOption(100 * time.Millisecond))
+    if (err != nil) {
+        t.Fatalf(`NewGossipSub error is "%v" instead of "<nil>"`, err)
+    }
+    _, err = pubsub.NewGossipSub(ctx, otherHost)
+    if (err != nil) {
+        t.Fatalf(`NewGossipSub error is "%v" instead of "<nil>"`, err)
+    }
+
+    // the connected gossipsub peer gets a snapshot
+    deadline := time.Now().Add(5 * time.Second)
+    for time.Now().Before(deadline) {
+        if (scoreInspector.PeerReport(otherHost.ID()).Scored) {
+            return
+        }
+        time.Sleep(100 * time.Millisecond)
+    }
+    t.Fatalf(`connected peer has no score snapshot`)
+}
@@ -0,0 +1,182 @@
+package pubsubPlebbitValidator
+
+import (
+    "testing"
+    "net"
+    "time"
+    pubsub "github.com/libp2p/go-libp2p-pubsub"
+    peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestTrustedPeerScore(t *testing.T) {
+    mockHost := newMockHost()
+    trustedPeerId := mockHost.addPeer("/ip4/1.2.3.4/tcp/4001")
+    untrustedPeerId := mockHost.addPeer("/ip4/5.6.7.8/tcp/4001")
+    validator := NewValidator(mockHost, WithTrustedPeers(trustedPeerId))
+    peerScoreParams := NewPeerScoreParams(validator)
+
+    // both peers relay only failed challenges
+    for _, peerId := range []peer.ID{trustedPeerId, untrustedPeerId} {
+        sendFailedChallenges(validator, peerId, int(minimumChallengeCount) * 10)
+    }
+
+    trustedScore := peerScoreParams.AppSpecificScore(trustedPeerId) * peerScoreParams.AppSpecificWeight
+    if (trustedScore <= PeerScoreThresholds.GraylistThreshold) {
+        t.Fatalf(`trusted peer score "%v" is not above the graylist threshold "%v"`, trustedScore, PeerScoreThresholds.GraylistThreshold)
+    }
+    if (trustedScore < PeerScoreThresholds.AcceptPXThreshold) {
+        t.Fatalf(`trusted peer score "%v" is below the accept PX threshold "%v"`, trustedScore, PeerScoreThresholds.AcceptPXThreshold)
+    }
+    if (validator.peersStatistics.Contains(string(trustedPeerId))) {
+        t.Fatalf(`trusted peer statistics should not be recorded`)
+    }
+
+    untrustedScore := peerScoreParams.AppSpecificScore(untrustedPeerId) * peerScoreParams.AppSpecificWeight
+    if (untrustedScore > PeerScoreThresholds.GraylistThreshold) {
+        t.Fatalf(`untrusted peer score "%v" is above the graylist threshold "%v"`, untrustedScore, PeerScoreThresholds.GraylistThreshold)
+    }
+    if (untrustedScore >= PeerScoreThresholds.AcceptPXThreshold) {
+        t.Fatalf(`untrusted peer score "%v" is above the accept PX threshold "%v"`, untrustedScore, PeerScoreThresholds.AcceptPXThreshold)
+    }
+}
+
+func TestBlockedTrustedPeerScore(t *testing.T) {
+    mockHost := newMockHost()
+    trustedPeerId := mockHost.addPeer("/ip4/1.2.3.4/tcp/4001")
+    validator := NewValidator(mockHost, WithTrustedPeers(trustedPeerId))
+
+    // the access list takes precedence over trusted peers
+    validator.AccessList().Block(AccessListEntries{PeerIds: []string{trustedPeerId.String()}})
+    score := validator.AppSpecificScore(trustedPeerId)
+    if (score != worstScore) {
+        t.Fatalf(`blocked trusted peer score is "%v" instead of "%v"`, score, worstScore)
+    }
+}
+
+func TestScoreConfigPresets(t *testing.T) {
+    validator := NewValidator(newMockHost())
+    for _, preset := range []ScorePreset{ScorePresetConservative, ScorePresetDefault, ScorePresetAggressive} {
+        scoreConfig, err := NewScoreConfig(preset)
+        if (err != nil) {
+            t.Fatalf(`NewScoreConfig "%v" error is "%v" instead of "<nil>"`, preset, err)
+        }
+        _, _, err = scoreConfig.Build(validator)
+        if (err != nil) {
+            t.Fatalf(`Build "%v" error is "%v" instead of "<nil>"`, preset, err)
+        }
+    }
+
+    _, err := NewScoreConfig("invalid")
+    if (err == nil) {
+        t.Fatalf(`NewScoreConfig "invalid" error is "<nil>"`)
+    }
+
+    // the default preset is the same as NewPeerScoreParams and PeerScoreThresholds
+    scoreConfig, _ := NewScoreConfig(ScorePresetDefault)
+    peerScoreParams, peerScoreThresholds, _ := scoreConfig.Build(validator)
+    defaultPeerScoreParams := NewPeerScoreParams(validator)
+    if (peerScoreParams.IPColocationFactorThreshold != defaultPeerScoreParams.IPColocationFactorThreshold ||
+        peerScoreParams.BehaviourPenaltyDecay != defaultPeerScoreParams.BehaviourPenaltyDecay ||
+        peerScoreParams.RetainScore != defaultPeerScoreParams.RetainScore) {
+        t.Fatalf(`default preset params "%+v" are not NewPeerScoreParams "%+v"`, peerScoreParams, defaultPeerScoreParams)
+    }
+    if (*peerScoreThresholds != PeerScoreThresholds) {
+        t.Fatalf(`default preset thresholds "%+v" are not PeerScoreThresholds "%+v"`, *peerScoreThresholds, PeerScoreThresholds)
+    }
+}
+
+func TestScoreConfigOverrides(t *testing.T) {
+    validator := NewValidator(newMockHost())
+    _, whitelist, _ := net.ParseCIDR("10.0.0.0/8")
+    scoreConfig, _ := NewScoreConfig(ScorePresetDefault)
+    peerScoreParams, _, err := scoreConfig.
+        WithIPColocation(2, -50, whitelist).
+        WithBehaviourPenalty(1, -1, 10 * time.Minute).
+        WithDecay(2 * time.Second, 0.1).
+        WithRetainScore(time.Minute).
+        Build(validator)
+    if (err != nil) {
+        t.Fatalf(`Build error is "%v" instead of "<nil>"`, err)
+    }
+    if (peerScoreParams.IPColocationFactorThreshold != 2 || len(peerScoreParams.IPColocationFactorWhitelist) != 1) {
+        t.Fatalf(`ip colocation overrides not applied "%+v"`, peerScoreParams)
+    }
+    expectedBehaviourPenaltyDecay := pubsub.ScoreParameterDecayWithBase(10 * time.Minute, 2 * time.Second, 0.1)
+    if (peerScoreParams.BehaviourPenaltyDecay != expectedBehaviourPenaltyDecay) {
+        t.Fatalf(`BehaviourPenaltyDecay is "%v" instead of "%v"`, peerScoreParams.BehaviourPenaltyDecay, expectedBehaviourPenaltyDecay)
+    }
+    if (peerScoreParams.RetainScore != time.Minute || peerScoreParams.DecayInterval != 2 * time.Second) {
+        t.Fatalf(`decay overrides not applied "%+v"`, peerScoreParams)
+    }
+}
+
+func TestScoreConfigTrustedPeerScore(t *testing.T) {
+    mockHost := newMockHost()
+    trustedPeerId := mockHost.addPeer("/ip4/1.2.3.4/tcp/4001")
+    validator := NewValidator(mockHost, WithTrustedPeers(trustedPeerId))
+
+    // 2500 × 0.1 is below the AcceptPXThreshold of 1000, PX from the trusted peers would be ignored
+    scoreConfig, _ := NewScoreConfig(ScorePresetDefault)
+    scoreConfig.WithAppSpecificWeight(0.1)
+    _, _, err := scoreConfig.Build(validator)
+    if (err == nil) {
+        t.Fatalf(`Build error is "<nil>" with a trusted peer score below AcceptPXThreshold`)
+    }
+    // without trusted peers the weight is valid
+    _, _, err = scoreConfig.Build(NewValidator(mockHost))
+    if (err != nil) {
+        t.Fatalf(`Build without trusted peers error is "%v" instead of "<nil>"`, err)
+    }
+    // the trusted peer weighted score reaches the threshold
+    scoreConfig.WithAppSpecificWeight(0.4)
+    _, _, err = scoreConfig.Build(validator)
+    if (err != nil) {
+        t.Fatalf(`Build error is "%v" instead of "<nil>"`, err)
+    }
+}
+
+func TestScoreConfigMisconfiguration(t *testing.T) {
+    validator := NewValidator(newMockHost())
+    misconfigurations := map[string]func(scoreConfig *ScoreConfig){
+        "positive IPColocationFactorWeight": func(scoreConfig *ScoreConfig) {
+            scoreConfig.WithIPColocation(5, 100)
+        },
+        "zero IPColocationFactorThreshold": func(scoreConfig *ScoreConfig) {
+            scoreConfig.WithIPColocation(0, -100)
+        },
+        "positive BehaviourPenaltyWeight": func(scoreConfig *ScoreConfig) {
+            scoreConfig.WithBehaviourPenalty(6, 10, time.Hour)
+        },
+        "BehaviourPenaltyDecay shorter than DecayInterval": func(scoreConfig *ScoreConfig) {
+            scoreConfig.WithBehaviourPenalty(6, -10, time.Millisecond)
+        },
+        "DecayInterval below 1s": func(scoreConfig *ScoreConfig) {
+            scoreConfig.WithDecay(time.Millisecond, 0.01)
+        },
+        "DecayToZero above 1": func(scoreConfig *ScoreConfig) {
+            scoreConfig.WithDecay(time.Second, 2)
+        },
+        "GraylistThreshold above PublishThreshold": func(scoreConfig *ScoreConfig) {
+            thresholds := scoreConfig.Thresholds
+            thresholds.GraylistThreshold = -1
+            scoreConfig.WithThresholds(thresholds)
+        },
+        "negative AcceptPXThreshold": func(scoreConfig *ScoreConfig) {
+            thresholds := scoreConfig.Thresholds
+            thresholds.AcceptPXThreshold = -1
+            scoreConfig.WithThresholds(thresholds)
+        },
+    }
+    for name, misconfigure := range misconfigurations {
+        scoreConfig, _ := NewScoreConfig(ScorePresetDefault)
+        misconfigure(scoreConfig)
+        _, _, err := scoreConfig.Build(validator)
+        if (err == nil) {
+            t.Fatalf(`Build error is "<nil>" with %v`, name)
+        }
+        _, err = scoreConfig.PubsubOption(validator)
+        if (err == nil) {
+            t.Fatalf(`PubsubOption error is "<nil>" with %v`, name)
+        }
+    }
+}
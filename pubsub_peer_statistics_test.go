@@ -0,0 +1,96 @@
+package pubsubPlebbitValidator
+
+import (
+    "testing"
+    "time"
+)
+
+// a clock that only moves when advanced, to test the statistics decay
+type testClock struct {
+    time time.Time
+}
+
+// starts at the current time, the messages signed by the tests have a current timestamp
+func newTestClock() *testClock {
+    return &testClock{time.Now()}
+}
+
+func (testClock *testClock) now() time.Time {
+    return testClock.time
+}
+
+func (testClock *testClock) advance(duration time.Duration) {
+    testClock.time = testClock.time.Add(duration)
+}
+
+func TestPeerStatisticsDecay(t *testing.T) {
+    mockHost, peerId := newTestHost()
+    clock := newTestClock()
+    validator := NewValidator(mockHost, WithStatisticsDecay(time.Hour, time.Minute), WithClock(clock.now))
+    sendFailedChallenges(validator, peerId, 400)
+    sendCompletedChallenges(validator, peerId, 400)
+
+    // decay by whole intervals only
+    clock.advance(59 * time.Second)
+    peerStatistics := getPeerStatistics(peerId, validator)
+    if (peerStatistics.challengeCount != 800 || peerStatistics.completedChallengeCount != 400) {
+        t.Fatalf(`challenge counts are "%v" and "%v" instead of "800" and "400"`, peerStatistics.challengeCount, peerStatistics.completedChallengeCount)
+    }
+
+    // the counts are halved every half life
+    clock.advance(time.Hour - 59 * time.Second)
+    peerStatistics = getPeerStatistics(peerId, validator)
+    if (peerStatistics.challengeCount != 400 || peerStatistics.completedChallengeCount != 200) {
+        t.Fatalf(`challenge counts are "%v" and "%v" instead of "400" and "200"`, peerStatistics.challengeCount, peerStatistics.completedChallengeCount)
+    }
+    clock.advance(time.Hour)
+    peerStatistics = getPeerStatistics(peerId, validator)
+    if (peerStatistics.challengeCount != 200 || peerStatistics.completedChallengeCount != 100) {
+        t.Fatalf(`challenge counts are "%v" and "%v" instead of "200" and "100"`, peerStatistics.challengeCount, peerStatistics.completedChallengeCount)
+    }
+
+    // small counts decay to zero
+    clock.advance(24 * time.Hour)
+    peerStatistics = getPeerStatistics(peerId, validator)
+    if (peerStatistics.challengeCount != 0 || peerStatistics.completedChallengeCount != 0) {
+        t.Fatalf(`challenge counts are "%v" and "%v" instead of "0" and "0"`, peerStatistics.challengeCount, peerStatistics.completedChallengeCount)
+    }
+}
+
+func TestPeerStatisticsDecayScore(t *testing.T) {
+    mockHost := newMockHost()
+    clock := newTestClock()
+    validator := NewValidator(mockHost, WithStatisticsDecay(time.Hour, time.Minute))
+    validator.now = clock.now
+
+    // a peer that spammed long ago is forgiven
+    oldSpamPeerId := mockHost.addPeer("/ip4/1.2.3.4/tcp/4001")
+    sendFailedChallenges(validator, oldSpamPeerId, int(minimumChallengeCount))
+    if (validator.AppSpecificScore(oldSpamPeerId) != worstScore) {
+        t.Fatalf(`spam peer score is "%v" instead of "%v"`, validator.AppSpecificScore(oldSpamPeerId), worstScore)
+    }
+    clock.advance(time.Hour)
+    if (validator.AppSpecificScore(oldSpamPeerId) != 0) {
+        t.Fatalf(`old spam peer score is "%v" instead of "0"`, validator.AppSpecificScore(oldSpamPeerId))
+    }
+
+    // a good peer that starts spamming is judged on its recent behaviour
+    goodPeerId := mockHost.addPeer("/ip4/5.6.7.8/tcp/4001")
+    sendCompletedChallenges(validator, goodPeerId, 1000)
+    clock.advance(4 * time.Hour)
+    sendFailedChallenges(validator, goodPeerId, 200)
+    // 62.5 completed of 262.5 challenges: 0.76²×−100000
+    score := validator.AppSpecificScore(goodPeerId)
+    if (score > -50000) {
+        t.Fatalf(`spamming good peer score is "%v" instead of below "-50000"`, score)
+    }
+
+    // without decay the lifetime counts are used
+    validator = NewValidator(mockHost, WithStatisticsDecay(0, time.Minute))
+    validator.now = clock.now
+    sendFailedChallenges(validator, oldSpamPeerId, int(minimumChallengeCount))
+    clock.advance(100 * time.Hour)
+    if (validator.AppSpecificScore(oldSpamPeerId) != worstScore) {
+        t.Fatalf(`spam peer score without decay is "%v" instead of "%v"`, validator.AppSpecificScore(oldSpamPeerId), worstScore)
+    }
+}
@@ -2,225 +2,441 @@ package pubsubPlebbitValidator
 
 import (
     "context"
-    // "fmt"
+    "errors"
+    "fmt"
     "time"
     "math"
+    "sync"
+    "sync/atomic"
     pubsub "github.com/libp2p/go-libp2p-pubsub"
     pubsub_pb "github.com/libp2p/go-libp2p-pubsub/pb"
     peer "github.com/libp2p/go-libp2p/core/peer"
-    crypto "github.com/libp2p/go-libp2p/core/crypto"
     lru "github.com/hashicorp/golang-lru/v2"
     host "github.com/libp2p/go-libp2p/core/host"
     blake2b "github.com/minio/blake2b-simd"
 )
 
-func validateSignature(message map[string]interface{}, signature Signature) bool {
+func validateSignature(message map[string]interface{}, signature Signature) error {
     bytesToSign := getBytesToSign(message, signature.signedPropertyNames)
     signatureVerified := verifyEd25519(bytesToSign, signature.signature, signature.publicKey)
     if (signatureVerified == false) {
-        // fmt.Println("invalid signature")
-        return false
+        return errors.New("invalid signature")
     }
-    return true
+    return nil
 }
 
-func validateType(messageType string) bool {
+func validateType(messageType string) error {
     if messageType != "CHALLENGEREQUEST" && messageType != "CHALLENGE" && messageType != "CHALLENGEANSWER" && messageType != "CHALLENGEVERIFICATION" {
-        // fmt.Println("invalid message type")
-        return false
+        return errors.New("invalid message type")
     }
-    return true
+    return nil
 }
 
-func validateChallengeRequestId(challengeRequestId []byte, signature Signature, messageType string) bool {
+func validateChallengeRequestId(challengeRequestId []byte, signature Signature, messageType string) error {
     // challenge request id can only be invalid if from non sub owner, ie CHALLENGEREQUEST or CHALLENGEANSWER
     if messageType != "CHALLENGEREQUEST" && messageType != "CHALLENGEANSWER" {
-        return true
+        return nil
     }
 
-    publicKey, err := crypto.UnmarshalEd25519PublicKey(signature.publicKey)
+    err := matchChallengeRequestId(challengeRequestId, signature.publicKey)
     if (err != nil) {
-        // fmt.Println("invalid challenge request id, failed crypto.UnmarshalEd25519PublicKey(signature.publicKey)", err)
-        return false
+        return fmt.Errorf("invalid challenge request id, %w", err)
     }
-    challengeRequestIdPeerId, err := peer.IDFromBytes(challengeRequestId)
-    if (err != nil) {
-        // fmt.Println("invalid challenge request id, failed peer.IDFromPublicKey(signature.publicKey)", err)
-        return false
-    }
-    if (challengeRequestIdPeerId.MatchesPublicKey(publicKey) == false) {
-        // fmt.Println("invalid challenge request id, failed challengeRequestId.MatchesPublicKey(publicKey)")
-        return false
-    }
-    return true
+    return nil
 }
 
-func validatePubsubTopic(pubsubTopic string, signature Signature, messageType string) bool {
+func validatePubsubTopic(ctx context.Context, pubsubTopic string, message map[string]interface{}, signature Signature, messageType string, validator Validator) error {
     // pubsub topic can only be invalid if from sub owner, ie CHALLENGE or CHALLENGEVERIFICATION
     if messageType != "CHALLENGE" && messageType != "CHALLENGEVERIFICATION" {
-        return true
+        return nil
     }
 
     signaturePeerId, err := getPeerIdFromPublicKey(signature.publicKey)
     if (err != nil) {
-        // fmt.Println("invalid pubsub topic, failed getPeerIdFromPublicKey(signature.publicKey)", err)
-        return false
+        return fmt.Errorf("invalid pubsub topic, failed getPeerIdFromPublicKey(signature.publicKey): %w", err)
+    }
+    if (pubsubTopic == signaturePeerId.String()) {
+        return nil
     }
-    if (pubsubTopic != signaturePeerId.String()) {
-        // fmt.Println("invalid pubsub topic, failed pubsubTopic == signaturePeerId")
-        return false   
+
+    // the owner of the topic is the peer id, or the peer id of the subplebbit address like a domain
+    ownerPeerId, err := peer.Decode(pubsubTopic)
+    if (err != nil && validator.addressResolver == nil) {
+        return fmt.Errorf("invalid pubsub topic, failed pubsubTopic == signaturePeerId, %v is not %v", pubsubTopic, signaturePeerId)
     }
-   return true
+    if (err != nil) {
+        ctx, cancel := context.WithTimeout(ctx, addressResolveTimeout)
+        defer cancel()
+        ownerPeerId, err = validator.addressResolver.Resolve(ctx, pubsubTopic)
+        if (err != nil) {
+            return addressResolutionError{pubsubTopic, err}
+        }
+        if (ownerPeerId == signaturePeerId) {
+            return nil
+        }
+    }
+
+    // the owner delegated signing to another key
+    err = validateDelegation(message, pubsubTopic, ownerPeerId, signature.publicKey, validator)
+    if (err != nil) {
+        return fmt.Errorf("invalid pubsub topic, %v is not signed by owner %v or a delegated key, %w", pubsubTopic, ownerPeerId, err)
+    }
+    return nil
 }
 
-func validateTimestamp(message map[string]interface{}, validator Validator) bool {
+func validateTimestamp(message map[string]interface{}, validator Validator) error {
     // ignore timestamp for tests that use old hardcoded signatures
     if (validator.noTimestamp) {
-        return true
+        return nil
     }
 
     timestamp, ok := message["timestamp"].(uint64)
     if !ok {
-        // fmt.Println("invalid message timestamp, failed convert message.timestamp to uint64")
-        return false
+        return errors.New("invalid message timestamp, failed convert message.timestamp to uint64")
     }
-    now := uint64(time.Now().Unix())
+    now := uint64(validator.now().Unix())
     fiveMinutes := uint64(60 * 5)
     if (timestamp > now + fiveMinutes) {
-        // fmt.Println("invalid message timestamp, newer than now + 5 minutes")
-        return false
+        return errors.New("invalid message timestamp, newer than now + 5 minutes")
     }
-    if (timestamp < now - fiveMinutes) {
-        // fmt.Println("invalid message timestamp, older than 5 minutes")
-        return false
+    if (timestamp + fiveMinutes < now) {
+        return errors.New("invalid message timestamp, older than 5 minutes")
     }
-    return true
+    return nil
 }
 
-func validatePeer(message map[string]interface{}, challengeRequestId []byte, peerId peer.ID, messageType string, validator Validator) bool {
-    // nothing to do for challenge message type
-    if (messageType == "CHALLENGE") {
+func validatePeer(message map[string]interface{}, challengeRequestId []byte, peerId peer.ID, originatorId peer.ID, messageType string, validator Validator) bool {
+    // nothing to do for challenge and challenge answer message types, a challenge is counted once
+    // on challenge request and completed once on challenge verification
+    if (messageType == "CHALLENGE" || messageType == "CHALLENGEANSWER") {
         return true
     }
 
-    peerIdString := string(peerId)
+    validator.statisticsMutex.Lock()
+    defer validator.statisticsMutex.Unlock()
 
     // get challenge request id string
     challengeRequestIdString := string(challengeRequestId)
     if (!validator.challenges.Contains(challengeRequestIdString)) {
-        validator.challenges.Add(challengeRequestIdString, make(map[string]bool))
+        validator.challenges.Add(challengeRequestIdString, &challengePeerKeys{map[string]bool{}, map[string]bool{}})
     }
     // get peer hostnames associated with the challenge request id
     challengePeers, _ := validator.challenges.Get(challengeRequestIdString)
 
     // on challenge verification, challenges and peer statistics are updated with the completed challenge
     if (messageType == "CHALLENGEVERIFICATION") {
-        // update the peer hostname completedChallengeCount
-        if (validator.peersStatistics.Contains(peerIdString)) {
-            peerStatistics, _ := validator.peersStatistics.Get(peerIdString)
-            peerStatistics.completedChallengeCount++
-        }
+        // update the completedChallengeCount of the peers that published or relayed the challenge
+        completeChallenge(challengePeers.originators, validator.peersStatistics, validator)
+        completeChallenge(challengePeers.relays, validator.relaysStatistics, validator)
 
         // delete the challenge because it's now completed
         validator.challenges.Remove(challengeRequestIdString)
         return true
     }
 
-    // the 2 message types left are CHALLENGEREQUEST AND CHALLENGEANSWER
+    // the message type left is CHALLENGEREQUEST
 
-    // handle setting Validator.peersStatistics
-    if (!validator.peersStatistics.Contains(peerIdString)) {
-        validator.peersStatistics.Add(peerIdString, PeerStatistics{1, 0})
-    } else {
-        peerStatistics, _ := validator.peersStatistics.Get(peerIdString)
-        peerStatistics.challengeCount++
+    // without a signed original publisher, the forwarding peer is blamed like it published the challenge
+    if (originatorId == "") {
+        originatorId = peerId
     }
 
-    // handle setting Validator.challenges
-    challengePeers[peerIdString] = true
+    // trusted peers are exempt from challenge statistics
+    if (!validator.trustedPeers[originatorId]) {
+        addChallenge(originatorId, challengePeers.originators, validator.peersStatistics, validator)
+    }
+    if (originatorId != peerId && !validator.trustedPeers[peerId]) {
+        addChallenge(peerId, challengePeers.relays, validator.relaysStatistics, validator)
+    }
     return true
 }
 
+// the peers statistics keys that published or relayed a challenge, to update on challenge verification
+type challengePeerKeys struct {
+    originators map[string]bool
+    relays map[string]bool
+}
+
+func addChallenge(peerId peer.ID, challengePeers map[string]bool, statistics *lru.Cache[string, *PeerStatistics], validator Validator) {
+    // the peer id, or the peer hostnames with WithPeerHostnameStatistics, a peer can have multiple hostnames
+    peerStatisticsKeys := getPeerStatisticsKeys(peerId, validator)
+    for _, peerStatisticsKey := range peerStatisticsKeys {
+        // handle setting the statistics
+        if (!statistics.Contains(peerStatisticsKey)) {
+            statistics.Add(peerStatisticsKey, &PeerStatistics{challengeCount: 1, lastDecay: validator.now()})
+        } else {
+            peerStatistics, _ := statistics.Get(peerStatisticsKey)
+            decayPeerStatistics(peerStatistics, validator)
+            peerStatistics.challengeCount++
+        }
+
+        // handle setting Validator.challenges
+        challengePeers[peerStatisticsKey] = true
+    }
+}
+
+func completeChallenge(challengePeers map[string]bool, statistics *lru.Cache[string, *PeerStatistics], validator Validator) {
+    for challengePeer := range challengePeers {
+        peerStatistics, ok := statistics.Get(challengePeer)
+        if (ok) {
+            decayPeerStatistics(peerStatistics, validator)
+            peerStatistics.completedChallengeCount++
+        }
+    }
+}
+
+// the original publisher of a signed message, or "" if it's unknown or it's the forwarding peer,
+// the signature is verified by pubsub before the validators run
+func getOriginatorId(peerId peer.ID, pubsubMessage *pubsub.Message) peer.ID {
+    if (len(pubsubMessage.GetSignature()) == 0 || pubsubMessage.GetFrom() == peerId) {
+        return ""
+    }
+    return pubsubMessage.GetFrom()
+}
+
+// the counts decay exponentially, so the score reflects the recent behaviour of the peer
 type PeerStatistics struct {
-    challengeCount uint
-    completedChallengeCount uint
+    challengeCount float64
+    completedChallengeCount float64
+    // the counts are decayed lazily when they are used
+    lastDecay time.Time
+}
+
+// the counts lose half their weight every half life, a peer that misbehaved long ago is forgiven
+var defaultStatisticsHalfLife time.Duration = 24 * time.Hour
+// decay by whole intervals, like gossipsub decays the peer scores every DecayInterval
+var defaultStatisticsDecayInterval time.Duration = pubsub.DefaultDecayInterval
+// same as gossipsub DefaultDecayToZero
+var statisticsDecayToZero float64 = 0.01
+
+func decayPeerStatistics(peerStatistics *PeerStatistics, validator Validator) {
+    if (validator.statisticsHalfLife <= 0 || validator.statisticsDecayInterval <= 0) {
+        return
+    }
+    intervals := validator.now().Sub(peerStatistics.lastDecay) / validator.statisticsDecayInterval
+    if (intervals <= 0) {
+        return
+    }
+    elapsed := intervals * validator.statisticsDecayInterval
+    decay := math.Pow(0.5, float64(elapsed) / float64(validator.statisticsHalfLife))
+    peerStatistics.challengeCount *= decay
+    peerStatistics.completedChallengeCount *= decay
+    if (peerStatistics.challengeCount < statisticsDecayToZero) {
+        peerStatistics.challengeCount = 0
+        peerStatistics.completedChallengeCount = 0
+    }
+    peerStatistics.lastDecay = peerStatistics.lastDecay.Add(elapsed)
 }
 
 type Validator struct {
     host host.Host
-    challenges *lru.Cache[string, map[string]bool]
-    peersStatistics *lru.Cache[string, PeerStatistics]
+    challenges *lru.Cache[string, *challengePeerKeys]
+    // the statistics of the challenges published by a peer
+    peersStatistics *lru.Cache[string, *PeerStatistics]
+    // the statistics of the challenges relayed by a peer for other publishers
+    relaysStatistics *lru.Cache[string, *PeerStatistics]
+    // the challenges peers and the peers statistics are updated concurrently by pubsub
+    statisticsMutex *sync.Mutex
     noTimestamp bool
+    peerHostnameStatistics bool
+    accessList *AccessList
+    trustedPeers map[peer.ID]bool
+    stateStore StateStore
+    // the error of the state restore in NewValidator
+    restoreStateError error
+    statisticsHalfLife time.Duration
+    statisticsDecayInterval time.Duration
+    messageLimits map[string]MessageLimits
+    addressResolver AddressResolver
+    // the challenge requests seen on each topic, for WithStrictChallengeResponses, 0 ttl disables it
+    seenChallengeRequests *lru.Cache[string, *lru.Cache[string, time.Time]]
+    seenChallengeRequestTtl time.Duration
+    seenChallengeRequestsSize int
+    delegations *Delegations
+    // ignore the delegations in the messages
+    localDelegationsOnly bool
+    // nil if the challenge requests don't need proof of work stamps
+    proofOfWork *ProofOfWork
+    // nil if the challenge requests are not shed under load
+    loadShedding *LoadShedding
+    minimumProtocolVersion ProtocolVersion
+    maximumProtocolVersion ProtocolVersion
+    unsupportedProtocolVersionResult pubsub.ValidationResult
+    // the clock of the statistics decay, the delegations expiry, the seen challenge requests and the topic loads, replaced in tests
+    now func() time.Time
 }
 
-func NewValidator(host host.Host) Validator {
-    challenges, _ := lru.New[string, map[string]bool](10000)
-    peersStatistics, _ := lru.New[string, PeerStatistics](10000)
-    return Validator{
-        host,
-        challenges,
-        peersStatistics,
-        false,
+type ValidatorOption func(*Validator)
+
+// aggregate the peers statistics by peer hostname (IPv4 /24, IPv6 /64 or dns name) instead
+// of by peer id, so peers sharing an IP can't reset their score by rotating peer ids
+func WithPeerHostnameStatistics() ValidatorOption {
+    return func(validator *Validator) {
+        validator.peerHostnameStatistics = true
     }
 }
 
-func (validator Validator) Validate(ctx context.Context, peerId peer.ID, pubsubMessage *pubsub.Message) bool {
-   // cbor decode
-    message, err := cborDecode(pubsubMessage.Data)
-    if (err != nil) {
-        // fmt.Println("failed cbor decode", err)
-        return false
+// decay the peers statistics every decayInterval so they lose half their weight every halfLife,
+// use the same decayInterval as ScoreConfig.DecayInterval, a halfLife of 0 disables the decay
+func WithStatisticsDecay(halfLife time.Duration, decayInterval time.Duration) ValidatorOption {
+    return func(validator *Validator) {
+        validator.statisticsHalfLife = halfLife
+        validator.statisticsDecayInterval = decayInterval
     }
-    signature, err := toSignature(message["signature"])
-    if (err != nil) {
-        // fmt.Println("invalid signature, failed cbor decode", err)
-        return false
+}
+
+// use an access list to block peers, ip ranges and authors, by default the access list is empty
+func WithAccessList(accessList *AccessList) ValidatorOption {
+    return func(validator *Validator) {
+        validator.accessList = accessList
     }
-    messageType, ok := message["type"].(string)
-    if !ok {
-        // fmt.Println("invalid message type, failed convert message.type to string")
-        return false
+}
+
+// trusted peers like bootstrap and subplebbit nodes relay a lot of failed challenges,
+// they are exempt from the challenge failure penalties and get trustedPeerScore
+func WithTrustedPeers(peerIds ...peer.ID) ValidatorOption {
+    return func(validator *Validator) {
+        for _, peerId := range peerIds {
+            validator.trustedPeers[peerId] = true
+        }
     }
-    challengeRequestId, ok := message["challengeRequestId"].([]byte)
-    if !ok {
-        // fmt.Println("invalid challenge request id, failed convert message.challengeRequestId to []byte")
-        return false
+}
+
+// replace the clock of the validator, e.g. to restore the state at a fixed time in tests
+func WithClock(now func() time.Time) ValidatorOption {
+    return func(validator *Validator) {
+        validator.now = now
     }
+}
 
-    // validate message type
-    validType := validateType(messageType)
-    if (validType == false) {
-        return false
+// skip the timestamp check, e.g. to check a captured message more than 5 minutes later
+func WithoutTimestamp() ValidatorOption {
+    return func(validator *Validator) {
+        validator.noTimestamp = true
     }
+}
 
-    // validate signature
-    signed := validateSignature(message, signature)
-    if (signed == false) {
-        return false
+func NewValidator(host host.Host, options ...ValidatorOption) Validator {
+    challenges, _ := lru.New[string, *challengePeerKeys](10000)
+    peersStatistics, _ := lru.New[string, *PeerStatistics](10000)
+    relaysStatistics, _ := lru.New[string, *PeerStatistics](10000)
+    seenChallengeRequests, _ := lru.New[string, *lru.Cache[string, time.Time]](seenChallengeRequestTopicsSize)
+    validator := Validator{
+        host: host,
+        challenges: challenges,
+        peersStatistics: peersStatistics,
+        relaysStatistics: relaysStatistics,
+        seenChallengeRequests: seenChallengeRequests,
+        statisticsMutex: &sync.Mutex{},
+        accessList: NewAccessList(),
+        delegations: NewDelegations(),
+        messageLimits: getDefaultMessageLimits(),
+        trustedPeers: map[peer.ID]bool{},
+        statisticsHalfLife: defaultStatisticsHalfLife,
+        statisticsDecayInterval: defaultStatisticsDecayInterval,
+        minimumProtocolVersion: defaultMinimumProtocolVersion,
+        maximumProtocolVersion: defaultMaximumProtocolVersion,
+        unsupportedProtocolVersionResult: pubsub.ValidationIgnore,
+        now: time.Now,
+    }
+    for _, option := range options {
+        option(&validator)
+    }
+    // the difficulties and the loads use the clock of the validator
+    if (validator.proofOfWork != nil) {
+        validator.proofOfWork.now = validator.now
+    }
+    if (validator.loadShedding != nil) {
+        validator.loadShedding.now = validator.now
+    }
+    // a missing or invalid state starts with empty statistics, RestoreStateError returns the error
+    if (validator.stateStore != nil) {
+        validator.restoreStateError = validator.RestoreState()
     }
+    return validator
+}
+
+// the error of the state restore in NewValidator, nil if the state was restored, if there was no
+// state yet or if the validator has no state store
+func (validator Validator) RestoreStateError() error {
+    return validator.restoreStateError
+}
 
-    // validate challengeRequestId if from author
-    validChallengeRequestId := validateChallengeRequestId(challengeRequestId, signature, messageType)
-    if (validChallengeRequestId == false) {
-        return false
+// the access list can be updated at runtime, e.g. validator.AccessList().Block(entries)
+func (validator Validator) AccessList() *AccessList {
+    return validator.accessList
+}
+
+// the pubsub validator, for pubsub.WithDefaultValidator. it returns a pubsub.ValidationResult instead
+// of a bool since the access list, the invalid messages are rejected and penalize the forwarding peer,
+// the messages that may be valid for other nodes are ignored. a direct caller that used the bool
+// compares the result to pubsub.ValidationAccept
+func (validator Validator) Validate(ctx context.Context, peerId peer.ID, pubsubMessage *pubsub.Message) pubsub.ValidationResult {
+    // the validation queue depth of the load shedding
+    if (validator.loadShedding != nil) {
+        atomic.AddInt64(validator.loadShedding.inFlight, 1)
+        defer atomic.AddInt64(validator.loadShedding.inFlight, -1)
     }
 
-    // validate pubsub topic if from subplebbit owner
-    validPubsubTopic := validatePubsubTopic(*pubsubMessage.Topic, signature, messageType)
-    if (validPubsubTopic == false) {
-        return false
+    // validate the forwarding peer and the original publisher are not blocked
+    if (validator.accessList.IsPeerBlocked(peerId, validator.host)) {
+        return pubsub.ValidationReject
+    }
+    if (pubsubMessage.GetFrom() != "" && validator.accessList.IsPeerBlocked(pubsubMessage.GetFrom(), validator.host)) {
+        return pubsub.ValidationReject
     }
 
-    // validate timestamp
-    validTimestamp := validateTimestamp(message, validator)
-    if (validTimestamp == false) {
-        return false
+    // decode and run the message checks
+    fields, err := decodePubsubMessage(*pubsubMessage.Topic, pubsubMessage.Data)
+    if (err != nil) {
+        // fmt.Println(err)
+        return pubsub.ValidationReject
+    }
+    for _, messageCheck := range messageChecks {
+        err = messageCheck.check(ctx, fields, validator)
+        if (errors.Is(err, ErrUnsupportedProtocolVersion)) {
+            return validator.unsupportedProtocolVersionResult
+        }
+        // the peer that forwarded the stamp may require a lower difficulty
+        if (errors.Is(err, ErrInsufficientProofOfWork)) {
+            return pubsub.ValidationIgnore
+        }
+        // the resolver of the forwarding peer may have resolved the address, or the resolver is down
+        if (errors.Is(err, ErrAddressResolution)) {
+            return pubsub.ValidationIgnore
+        }
+        if (err != nil) {
+            // fmt.Println(messageCheck.name, err)
+            return pubsub.ValidationReject
+        }
+    }
+
+    // in strict mode, ignore the owner responses to unseen challenge requests
+    if (!validateChallengeResponse(fields, validator)) {
+        return pubsub.ValidationIgnore
+    }
+
+    // under load, ignore the challenge requests of the publishers without a good history
+    originatorId := getOriginatorId(peerId, pubsubMessage)
+    publisherId := originatorId
+    if (publisherId == "") {
+        publisherId = peerId
+    }
+    if (shedChallengeRequest(fields, publisherId, validator)) {
+        return pubsub.ValidationIgnore
     }
 
     // validate too many failed requests forwards
-    validPeer := validatePeer(message, challengeRequestId, peerId, messageType, validator)
+    validPeer := validatePeer(fields.message, fields.challengeRequestId, peerId, originatorId, fields.messageType, validator)
     if (validPeer == false) {
-        return false
+        return pubsub.ValidationReject
+    }
+    addSeenChallengeRequest(fields, validator)
+    if (validator.proofOfWork != nil && fields.messageType == "CHALLENGEREQUEST") {
+        validator.proofOfWork.observe(fields.topic, validator.now())
+    }
+    if (validator.loadShedding != nil && fields.messageType == "CHALLENGEREQUEST") {
+        validator.loadShedding.observe(fields.topic, validator.now())
     }
 
     // debug peer validator
@@ -232,23 +448,83 @@ func (validator Validator) Validate(ctx context.Context, peerId peer.ID, pubsubM
     // }
     // validator.AppSpecificScore(peerId)
 
-    return true
+    return pubsub.ValidationAccept
 }
 
 var minimumChallengeCount uint = 100
 var worstScore float64 = -100000
+// honest relays forward the spam of other publishers, only a fraction of their failed challenges is penalized
+var relayPenaltyWeight float64 = 0.1
+// the relay penalty never goes below the gossip threshold of the score presets, an honest relay forwarding
+// only spam must not be graylisted, e.g. 0.1×-25000 = -2500 at a 50% failure ratio is the default graylist threshold
+var relayWorstScore float64 = -200
+// same as lotus bootstrappers, above PeerScoreThresholds.AcceptPXThreshold so PX is accepted from trusted peers,
+// ScoreConfig.Build fails if the AppSpecificWeight puts it below the AcceptPXThreshold
+var trustedPeerScore float64 = 2500
 func (validator Validator) AppSpecificScore(peerId peer.ID) float64 {
-    // disable AppSpecificScore until we can test it more
-    return 0
+    if (validator.accessList.IsPeerBlocked(peerId, validator.host)) {
+        return worstScore
+    }
+    if (validator.trustedPeers[peerId]) {
+        return trustedPeerScore
+    }
+
+    validator.statisticsMutex.Lock()
+    defer validator.statisticsMutex.Unlock()
+
+    // a peer can have multiple hostnames, use the worst score
+    var score float64 = 0
+    for _, peerStatisticsKey := range getPeerStatisticsKeys(peerId, validator) {
+        var peerStatisticsKeyScore float64 = 0
+        peerStatistics, ok := validator.peersStatistics.Get(peerStatisticsKey)
+        if (ok) {
+            decayPeerStatistics(peerStatistics, validator)
+            peerStatisticsKeyScore += getPeerStatisticsScore(*peerStatistics)
+        }
+        relayStatistics, ok := validator.relaysStatistics.Get(peerStatisticsKey)
+        if (ok) {
+            decayPeerStatistics(relayStatistics, validator)
+            peerStatisticsKeyScore += math.Max(relayPenaltyWeight * getPeerStatisticsScore(*relayStatistics), relayWorstScore)
+        }
+        score = math.Min(score, peerStatisticsKeyScore)
+    }
+    return score
+}
+
+// the sum of the statistics of the challenges published by the peer
+func getPeerStatistics(peerId peer.ID, validator Validator) PeerStatistics {
+    return sumPeerStatistics(peerId, validator.peersStatistics, validator)
+}
 
-    peerStatistics, _ := validator.peersStatistics.Get(string(peerId))
+// the sum of the statistics of the challenges relayed by the peer
+func getRelayStatistics(peerId peer.ID, validator Validator) PeerStatistics {
+    return sumPeerStatistics(peerId, validator.relaysStatistics, validator)
+}
+
+func sumPeerStatistics(peerId peer.ID, statistics *lru.Cache[string, *PeerStatistics], validator Validator) PeerStatistics {
+    validator.statisticsMutex.Lock()
+    defer validator.statisticsMutex.Unlock()
+
+    sum := PeerStatistics{}
+    for _, peerStatisticsKey := range getPeerStatisticsKeys(peerId, validator) {
+        peerStatistics, ok := statistics.Get(peerStatisticsKey)
+        if (!ok) {
+            continue
+        }
+        decayPeerStatistics(peerStatistics, validator)
+        sum.challengeCount += peerStatistics.challengeCount
+        sum.completedChallengeCount += peerStatistics.completedChallengeCount
+    }
+    return sum
+}
 
+func getPeerStatisticsScore(peerStatistics PeerStatistics) float64 {
     // need a minimum count for statistics to mean something
-    if (peerStatistics.challengeCount < minimumChallengeCount) {
+    if (peerStatistics.challengeCount < float64(minimumChallengeCount)) {
         return 0
     }
 
-    challengeFailureRatio := float64(1 - (peerStatistics.completedChallengeCount / peerStatistics.challengeCount))
+    challengeFailureRatio := 1 - peerStatistics.completedChallengeCount / peerStatistics.challengeCount
     //  1% failure ratio: 0.01²×−100000 = -10
     // 10% failure ratio: 0.10²×−100000 = -1000
     // 50% failure ratio: 0.50²×−100000 = -25000
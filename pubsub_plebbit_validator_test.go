@@ -6,9 +6,12 @@ import (
     "time"
     libp2p "github.com/libp2p/go-libp2p"
     pubsub "github.com/libp2p/go-libp2p-pubsub"
+    peer "github.com/libp2p/go-libp2p/core/peer"
 )
 
 var subplebbitPrivateKey []byte = []byte{49,69,50,213,51,78,20,35,193,100,36,247,205,129,13,190,124,95,112,200,141,229,111,59,146,66,65,245,169,108,168,184}
+// the topic of the subplebbit messages
+var subplebbitPeerId, _ = getPeerIdFromPrivateKey(subplebbitPrivateKey)
 var wrongChallengeRequestId []byte = []byte{0,36,8,1,18,32,244,84,230,177,77,214,35,244,185,233,200,209,89,241,126,211,13,198,231,57,165,9,143,70,222,166,20,35,112,60,6,106}
 
 func tryGeneratePrivateKey() ([]byte) {
@@ -65,6 +68,11 @@ func publishPubsubMessageRandomTopic(encodedMessage []byte) error {
     return topic.Publish(ctx, encodedMessage)
 }
 
+// a structurally valid envelope, the ciphertext is not encrypted
+func createTestEnvelope(ciphertext []byte) map[string]interface{} {
+    return map[string]interface{}{"ciphertext": ciphertext, "iv": make([]byte, envelopeIvLength), "tag": make([]byte, envelopeTagLength), "type": envelopeType}
+}
+
 func createPubsubChallengeRequestMessage(privateKey []byte) map[string]interface{} {
     message := map[string]interface{}{}
     message["type"] = "CHALLENGEREQUEST"
@@ -72,7 +80,7 @@ func createPubsubChallengeRequestMessage(privateKey []byte) map[string]interface
     message["protocolVersion"] = "1.0.0"
     message["userAgent"] = "/pubsub-plebbit-validator/0.0.1"
     message["acceptedChallengeTypes"] = []string{"image/png"}
-    message["encryptedPublication"] = map[string]interface{}{}
+    message["encryptedPublication"] = createTestEnvelope([]byte("publication"))
     // add challenge request id which is multihash of signature.publicKey
     peerId, err := getPeerIdFromPrivateKey(privateKey)
     if (err != nil) {
@@ -82,16 +90,39 @@ func createPubsubChallengeRequestMessage(privateKey []byte) map[string]interface
     return message
 }
 
+// a signed message of the type with the envelope the type requires, the properties replace
+// the properties of the message and a nil property is removed
+func createSignedMessage(messageType string, privateKey []byte, properties map[string]interface{}) []byte {
+    return cborEncode(createSignedMessageMap(messageType, privateKey, properties))
+}
+
+// the signed message before encoding, e.g. to stamp it
+func createSignedMessageMap(messageType string, privateKey []byte, properties map[string]interface{}) map[string]interface{} {
+    message := createPubsubChallengeRequestMessage(privateKey)
+    message["type"] = messageType
+    if (requiredEnvelopeFieldNames[messageType] != "") {
+        message[requiredEnvelopeFieldNames[messageType]] = createTestEnvelope([]byte("encrypted"))
+    }
+    for name, value := range properties {
+        if (value == nil) {
+            delete(message, name)
+            continue
+        }
+        message[name] = value
+    }
+    signPubsubMessage(message, privateKey)
+    return message
+}
+
+// a mock host with a peer forwarding the messages to validate
+func newTestHost() (mockHost, peer.ID) {
+    mockHost := newMockHost()
+    return mockHost, mockHost.addPeer("/ip4/1.2.3.4/tcp/4001")
+}
+
 func signPubsubMessage(message map[string]interface{}, privateKey []byte) {
-    // sign
-    signedPropertyNames := []string{"type", "timestamp", "challengeRequestId", "acceptedChallengeTypes", "encryptedPublication"}
-    signature := map[string]interface{}{}
-    bytesToSign := getBytesToSign(message, signedPropertyNames)
-    signature["signature"] = signEd25519(bytesToSign, privateKey)
-    signature["publicKey"] = getPublicKeyFromPrivateKey(privateKey)
-    signature["signedPropertyNames"] = signedPropertyNames
-    signature["type"] = "ed25519"
-    message["signature"] = signature
+    signedPropertyNames := []string{"type", "timestamp", "protocolVersion", "challengeRequestId", "acceptedChallengeTypes", "encryptedPublication"}
+    SignMessage(message, privateKey, signedPropertyNames)
 }
 
 func TestValidPubsubChallengeRequestMessage(t *testing.T) {
@@ -109,6 +140,7 @@ func TestValidPubsubChallengeAnwserMessage(t *testing.T) {
     privateKey := tryGeneratePrivateKey()
     message := createPubsubChallengeRequestMessage(privateKey)
     message["type"] = "CHALLENGEANSWER"
+    message["encryptedChallengeAnswers"] = createTestEnvelope([]byte("challenge answers"))
     signPubsubMessage(message, privateKey)
     encodedMessage := cborEncode(message)
     err := publishPubsubMessage(encodedMessage)
@@ -120,6 +152,7 @@ func TestValidPubsubChallengeAnwserMessage(t *testing.T) {
 func TestValidPubsubChallengeMessage(t *testing.T) {
     message := createPubsubChallengeRequestMessage(subplebbitPrivateKey)
     message["type"] = "CHALLENGE"
+    message["encryptedChallenges"] = createTestEnvelope([]byte("challenges"))
     // make sure sub owner can send any challenge request id they want
     message["challengeRequestId"] = wrongChallengeRequestId
     signPubsubMessage(message, subplebbitPrivateKey)
@@ -196,6 +229,7 @@ func TestInvalidPubsubMessageType(t *testing.T) {
         t.Fatalf(`publish error is "%v" instead of "<nil>"`, err)
     }
     message["type"] = "CHALLENGEANSWER"
+    message["encryptedChallengeAnswers"] = createTestEnvelope([]byte("challenge answers"))
     signPubsubMessage(message, privateKey)
     encodedMessage = cborEncode(message)
     err = publishPubsubMessage(encodedMessage)
@@ -206,6 +240,7 @@ func TestInvalidPubsubMessageType(t *testing.T) {
     // subplebbit message types, only sub owner can publish challenges or challenge verifications
     subplebbitMessage := createPubsubChallengeRequestMessage(subplebbitPrivateKey)
     subplebbitMessage["type"] = "CHALLENGE"
+    subplebbitMessage["encryptedChallenges"] = createTestEnvelope([]byte("challenges"))
     // make sure sub owner can send any challenge request id they want
     message["challengeRequestId"] = wrongChallengeRequestId
     signPubsubMessage(subplebbitMessage, subplebbitPrivateKey)
@@ -270,6 +305,7 @@ func TestInvalidPubsubTopic(t *testing.T) {
         t.Fatalf(`publish error is "%v" instead of "<nil>"`, err)
     }
     message["type"] = "CHALLENGEANSWER"
+    message["encryptedChallengeAnswers"] = createTestEnvelope([]byte("challenge answers"))
     signPubsubMessage(message, privateKey)
     encodedMessage = cborEncode(message)
     err = publishPubsubMessageRandomTopic(encodedMessage)
@@ -280,6 +316,7 @@ func TestInvalidPubsubTopic(t *testing.T) {
     // subplebbit message types, should be invalid with random topic
     subplebbitMessage := createPubsubChallengeRequestMessage(subplebbitPrivateKey)
     subplebbitMessage["type"] = "CHALLENGE"
+    subplebbitMessage["encryptedChallenges"] = createTestEnvelope([]byte("challenges"))
     signPubsubMessage(subplebbitMessage, subplebbitPrivateKey)
     encodedMessage = cborEncode(subplebbitMessage)
     err = publishPubsubMessageRandomTopic(encodedMessage)
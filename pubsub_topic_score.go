@@ -0,0 +1,86 @@
+package pubsubPlebbitValidator
+
+import (
+    "math"
+    "time"
+    pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+// the time window of expected message deliveries used to compute the P2 and P3 counters
+var topicDeliveriesWindow time.Duration = time.Minute
+// P3 is only enabled if at least this many messages are expected per topicDeliveriesWindow,
+// otherwise honest mesh peers of a quiet subplebbit would be penalized for not delivering
+var minimumMeshMessageDeliveries float64 = 1
+
+// generate the topic score params of a subplebbit topic from its expected messages per second,
+// the counters decay with the scoreConfig DecayInterval and DecayToZero
+func (scoreConfig *ScoreConfig) TopicScoreParams(expectedMessageRate float64) *pubsub.TopicScoreParams {
+    decay := func(duration time.Duration) float64 {
+        return pubsub.ScoreParameterDecayWithBase(duration, scoreConfig.DecayInterval, scoreConfig.DecayToZero)
+    }
+    expectedMessageDeliveries := expectedMessageRate * topicDeliveriesWindow.Seconds()
+
+    topicScoreParams := &pubsub.TopicScoreParams{
+        // in plebbit all topics are equal
+        TopicWeight: 0.1,
+
+        // P1: time in the mesh, up to +10 after 1 hour
+        TimeInMeshWeight: 10.0 / 3600,
+        TimeInMeshQuantum: time.Second,
+        TimeInMeshCap: 3600,
+
+        // P2: first message deliveries, up to +50 for delivering all the messages of the last 10 minutes
+        FirstMessageDeliveriesDecay: decay(10 * time.Minute),
+
+        // P4: invalid messages, rejected by the validator, decay after 1 hour,
+        // values copied from the lotus messages topic
+        InvalidMessageDeliveriesWeight: -1000,
+        InvalidMessageDeliveriesDecay: decay(time.Hour),
+    }
+    firstMessageDeliveriesCap := math.Max(1, expectedMessageDeliveries * 10)
+    topicScoreParams.FirstMessageDeliveriesCap = firstMessageDeliveriesCap
+    topicScoreParams.FirstMessageDeliveriesWeight = 50 / firstMessageDeliveriesCap
+
+    // P3: mesh message deliveries, penalize mesh peers delivering less than 20% of the expected messages
+    if (expectedMessageDeliveries >= minimumMeshMessageDeliveries) {
+        meshMessageDeliveriesThreshold := math.Max(1, expectedMessageDeliveries * 0.2)
+        // -100 when no messages are delivered
+        meshMessageDeliveriesWeight := -100 / math.Pow(meshMessageDeliveriesThreshold, 2)
+        topicScoreParams.MeshMessageDeliveriesWeight = meshMessageDeliveriesWeight
+        topicScoreParams.MeshMessageDeliveriesDecay = decay(topicDeliveriesWindow)
+        topicScoreParams.MeshMessageDeliveriesThreshold = meshMessageDeliveriesThreshold
+        topicScoreParams.MeshMessageDeliveriesCap = meshMessageDeliveriesThreshold * 10
+        topicScoreParams.MeshMessageDeliveriesWindow = 10 * time.Millisecond
+        // give new mesh peers time to start delivering
+        topicScoreParams.MeshMessageDeliveriesActivation = 2 * topicDeliveriesWindow
+
+        // P3b: keep the penalty of peers pruned from the mesh while under delivering
+        topicScoreParams.MeshFailurePenaltyWeight = meshMessageDeliveriesWeight
+        topicScoreParams.MeshFailurePenaltyDecay = decay(topicDeliveriesWindow)
+    }
+    return topicScoreParams
+}
+
+// the ScorePresetDefault topic score params
+func NewTopicScoreParams(expectedMessageRate float64) *pubsub.TopicScoreParams {
+    return defaultScoreConfig().TopicScoreParams(expectedMessageRate)
+}
+
+// join a subplebbit topic and register its topic score params, so mesh deliveries
+// and invalid messages rejected by the validator (P1-P4) count in the peer score
+func JoinTopic(ps *pubsub.PubSub, topicName string, topicScoreParams *pubsub.TopicScoreParams) (*pubsub.Topic, error) {
+    err := validateTopicScoreParams(topicScoreParams)
+    if (err != nil) {
+        return nil, err
+    }
+    topic, err := ps.Join(topicName)
+    if (err != nil) {
+        return nil, err
+    }
+    err = topic.SetScoreParams(topicScoreParams)
+    if (err != nil) {
+        topic.Close()
+        return nil, err
+    }
+    return topic, nil
+}
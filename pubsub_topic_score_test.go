@@ -0,0 +1,115 @@
+package pubsubPlebbitValidator
+
+import (
+    "testing"
+    "context"
+    "sync"
+    "time"
+    pubsub "github.com/libp2p/go-libp2p-pubsub"
+    peer "github.com/libp2p/go-libp2p/core/peer"
+    mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+func TestTopicScoreParams(t *testing.T) {
+    for _, expectedMessageRate := range []float64{0, 0.001, 0.1, 1, 100} {
+        topicScoreParams := NewTopicScoreParams(expectedMessageRate)
+        err := validateTopicScoreParams(topicScoreParams)
+        if (err != nil) {
+            t.Fatalf(`topic score params of rate "%v" error is "%v" instead of "<nil>"`, expectedMessageRate, err)
+        }
+        if (topicScoreParams.InvalidMessageDeliveriesWeight >= 0) {
+            t.Fatalf(`InvalidMessageDeliveriesWeight of rate "%v" is "%v" instead of negative`, expectedMessageRate, topicScoreParams.InvalidMessageDeliveriesWeight)
+        }
+    }
+
+    // mesh message deliveries are not penalized on quiet topics
+    if (NewTopicScoreParams(0.001).MeshMessageDeliveriesWeight != 0) {
+        t.Fatalf(`MeshMessageDeliveriesWeight is not disabled on a quiet topic`)
+    }
+    if (NewTopicScoreParams(1).MeshMessageDeliveriesWeight >= 0) {
+        t.Fatalf(`MeshMessageDeliveriesWeight is not enabled on a busy topic`)
+    }
+
+    // the decay uses the score config decay interval
+    scoreConfig, _ := NewScoreConfig(ScorePresetDefault)
+    scoreConfig.WithDecay(10 * time.Second, 0.01)
+    expectedDecay := pubsub.ScoreParameterDecayWithBase(time.Hour, 10 * time.Second, 0.01)
+    if (scoreConfig.TopicScoreParams(1).InvalidMessageDeliveriesDecay != expectedDecay) {
+        t.Fatalf(`InvalidMessageDeliveriesDecay is "%v" instead of "%v"`, scoreConfig.TopicScoreParams(1).InvalidMessageDeliveriesDecay, expectedDecay)
+    }
+}
+
+func TestInvalidMessagesTopicScore(t *testing.T) {
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    testNetwork, err := mocknet.FullMeshConnected(2)
+    if (err != nil) {
+        t.Fatalf(`mocknet error is "%v" instead of "<nil>"`, err)
+    }
+    defer testNetwork.Close()
+    spamHost, validatorHost := testNetwork.Hosts()[0], testNetwork.Hosts()[1]
+    topicString := "topic"
+
+    // the spam peer doesn't validate its own messages
+    spamPubsub, err := pubsub.NewGossipSub(ctx, spamHost)
+    if (err != nil) {
+        t.Fatalf(`NewGossipSub error is "%v" instead of "<nil>"`, err)
+    }
+    spamTopic, err := spamPubsub.Join(topicString)
+    if (err != nil) {
+        t.Fatalf(`Join error is "%v" instead of "<nil>"`, err)
+    }
+    spamTopic.Subscribe()
+
+    // the validator peer scores the spam peer
+    validator := NewValidator(validatorHost)
+    scoreConfig, _ := NewScoreConfig(ScorePresetDefault)
+    peerScoreOption, err := scoreConfig.PubsubOption(validator)
+    if (err != nil) {
+        t.Fatalf(`PubsubOption error is "%v" instead of "<nil>"`, err)
+    }
+    scores := map[peer.ID]float64{}
+    scoresMutex := sync.Mutex{}
+    validatorPubsub, err := pubsub.NewGossipSub(
+        ctx,
+        validatorHost,
+        pubsub.WithDefaultValidator(validator.Validate),
+        peerScoreOption,
+        pubsub.WithPeerScoreInspect(func(inspectedScores map[peer.ID]float64) {
+            scoresMutex.Lock()
+            defer scoresMutex.Unlock()
+            scores = inspectedScores
+        }, 100 * time.Millisecond),
+    )
+    if (err != nil) {
+        t.Fatalf(`NewGossipSub error is "%v" instead of "<nil>"`, err)
+    }
+    validatorTopic, err := JoinTopic(validatorPubsub, topicString, NewTopicScoreParams(1))
+    if (err != nil) {
+        t.Fatalf(`JoinTopic error is "%v" instead of "<nil>"`, err)
+    }
+    validatorTopic.Subscribe()
+
+    // wait for the subscriptions to propagate
+    time.Sleep(time.Second)
+
+    for i := 0; i < 3; i++ {
+        err = spamTopic.Publish(ctx, []byte("invalid message"))
+        if (err != nil) {
+            t.Fatalf(`Publish error is "%v" instead of "<nil>"`, err)
+        }
+    }
+
+    // 3 invalid messages: 3² × -1000 × 0.1 = -900
+    deadline := time.Now().Add(5 * time.Second)
+    for time.Now().Before(deadline) {
+        scoresMutex.Lock()
+        score := scores[spamHost.ID()]
+        scoresMutex.Unlock()
+        if (score < -500) {
+            return
+        }
+        time.Sleep(100 * time.Millisecond)
+    }
+    t.Fatalf(`spam peer score is "%v" instead of below "-500"`, scores[spamHost.ID()])
+}
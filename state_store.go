@@ -0,0 +1,187 @@
+package pubsubPlebbitValidator
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "os"
+    "path/filepath"
+    "time"
+    lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// the persisted statistics of a peer statistics key, the key is a peer id or a peer hostname
+type PeerStatisticsState struct {
+    Key []byte `json:"key"`
+    ChallengeCount float64 `json:"challengeCount"`
+    CompletedChallengeCount float64 `json:"completedChallengeCount"`
+}
+
+type ValidatorState struct {
+    // when the state was saved, the statistics are decayed from it on restore
+    Timestamp time.Time `json:"timestamp"`
+    // from least to most recently used
+    PeersStatistics []PeerStatisticsState `json:"peersStatistics"`
+    RelaysStatistics []PeerStatisticsState `json:"relaysStatistics"`
+}
+
+// persist the validator state across restarts, Load returns nil without error if there is no state yet
+type StateStore interface {
+    Load() (*ValidatorState, error)
+    Save(state *ValidatorState) error
+}
+
+// save the validator state as a json file
+type FileStateStore struct {
+    path string
+}
+
+func NewFileStateStore(path string) *FileStateStore {
+    return &FileStateStore{path}
+}
+
+func (fileStateStore *FileStateStore) Load() (*ValidatorState, error) {
+    file, err := os.ReadFile(fileStateStore.path)
+    if (errors.Is(err, os.ErrNotExist)) {
+        return nil, nil
+    }
+    if (err != nil) {
+        return nil, err
+    }
+    state := &ValidatorState{}
+    err = json.Unmarshal(file, state)
+    if (err != nil) {
+        return nil, err
+    }
+    return state, nil
+}
+
+func (fileStateStore *FileStateStore) Save(state *ValidatorState) error {
+    file, err := json.Marshal(state)
+    if (err != nil) {
+        return err
+    }
+    // write to a temporary file and rename it, so a crash can't leave a partial state
+    temporaryFile, err := os.CreateTemp(filepath.Dir(fileStateStore.path), filepath.Base(fileStateStore.path) + ".tmp")
+    if (err != nil) {
+        return err
+    }
+    defer os.Remove(temporaryFile.Name())
+    _, err = temporaryFile.Write(file)
+    if (err != nil) {
+        temporaryFile.Close()
+        return err
+    }
+    err = temporaryFile.Close()
+    if (err != nil) {
+        return err
+    }
+    return os.Rename(temporaryFile.Name(), fileStateStore.path)
+}
+
+// restore and persist the peers statistics, the state is restored by NewValidator
+func WithStateStore(stateStore StateStore) ValidatorOption {
+    return func(validator *Validator) {
+        validator.stateStore = stateStore
+    }
+}
+
+func (validator Validator) getState() *ValidatorState {
+    validator.statisticsMutex.Lock()
+    defer validator.statisticsMutex.Unlock()
+
+    return &ValidatorState{
+        Timestamp: validator.now(),
+        PeersStatistics: getStatisticsState(validator.peersStatistics, validator),
+        RelaysStatistics: getStatisticsState(validator.relaysStatistics, validator),
+    }
+}
+
+func getStatisticsState(statistics *lru.Cache[string, *PeerStatistics], validator Validator) []PeerStatisticsState {
+    statisticsState := []PeerStatisticsState{}
+    for _, peerStatisticsKey := range statistics.Keys() {
+        peerStatistics, ok := statistics.Peek(peerStatisticsKey)
+        if (!ok) {
+            continue
+        }
+        decayPeerStatistics(peerStatistics, validator)
+        statisticsState = append(statisticsState, PeerStatisticsState{
+            Key: []byte(peerStatisticsKey),
+            ChallengeCount: peerStatistics.challengeCount,
+            CompletedChallengeCount: peerStatistics.completedChallengeCount,
+        })
+    }
+    return statisticsState
+}
+
+func (validator Validator) setState(state *ValidatorState) {
+    validator.statisticsMutex.Lock()
+    defer validator.statisticsMutex.Unlock()
+
+    setStatisticsState(validator.peersStatistics, state.PeersStatistics, state.Timestamp, validator)
+    setStatisticsState(validator.relaysStatistics, state.RelaysStatistics, state.Timestamp, validator)
+}
+
+func setStatisticsState(statistics *lru.Cache[string, *PeerStatistics], statisticsState []PeerStatisticsState, timestamp time.Time, validator Validator) {
+    for _, peerStatisticsState := range statisticsState {
+        peerStatistics := &PeerStatistics{
+            challengeCount: peerStatisticsState.ChallengeCount,
+            completedChallengeCount: peerStatisticsState.CompletedChallengeCount,
+            lastDecay: timestamp,
+        }
+        decayPeerStatistics(peerStatistics, validator)
+        // the statistics have decayed to nothing
+        if (peerStatistics.challengeCount == 0) {
+            continue
+        }
+        statistics.Add(string(peerStatisticsState.Key), peerStatistics)
+    }
+}
+
+// save the peers statistics to the state store
+func (validator Validator) SaveState() error {
+    if (validator.stateStore == nil) {
+        return errors.New("validator has no state store")
+    }
+    return validator.stateStore.Save(validator.getState())
+}
+
+// restore the peers statistics from the state store, decayed by the time elapsed since they were saved
+func (validator Validator) RestoreState() error {
+    if (validator.stateStore == nil) {
+        return errors.New("validator has no state store")
+    }
+    state, err := validator.stateStore.Load()
+    if (err != nil) {
+        return err
+    }
+    if (state == nil) {
+        return nil
+    }
+    validator.setState(state)
+    return nil
+}
+
+// save the state every interval and once more when the context is done, save errors are passed
+// to onError if not nil, e.g. to log them, and retried on the next interval
+func (validator Validator) PersistStatePeriodically(ctx context.Context, interval time.Duration, onError func(err error)) {
+    saveState := func() {
+        err := validator.SaveState()
+        if (err != nil && onError != nil) {
+            onError(err)
+        }
+    }
+    go func() {
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-ticker.C:
+                saveState()
+            case <-ctx.Done():
+                saveState()
+                return
+            }
+        }
+    }()
+}
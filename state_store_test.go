@@ -0,0 +1,143 @@
+package pubsubPlebbitValidator
+
+import (
+    "testing"
+    "context"
+    "os"
+    "path/filepath"
+    "time"
+)
+
+func TestFileStateStoreRestore(t *testing.T) {
+    mockHost := newMockHost()
+    stateStore := NewFileStateStore(filepath.Join(t.TempDir(), "state.json"))
+    clock := newTestClock()
+    validator := NewValidator(mockHost, WithStateStore(stateStore), WithClock(clock.now))
+    badPeerId := mockHost.addPeer("/ip4/1.2.3.4/tcp/4001")
+    goodPeerId := mockHost.addPeer("/ip4/5.6.7.8/tcp/4001")
+    sendFailedChallenges(validator, badPeerId, int(minimumChallengeCount))
+    sendCompletedChallenges(validator, goodPeerId, 3)
+
+    err := validator.SaveState()
+    if (err != nil) {
+        t.Fatalf(`SaveState error is "%v" instead of "<nil>"`, err)
+    }
+
+    // the restarted validator keeps the bad peer score
+    restoredValidator := NewValidator(mockHost, WithStateStore(stateStore), WithClock(clock.now))
+    if (restoredValidator.RestoreStateError() != nil) {
+        t.Fatalf(`RestoreStateError is "%v" instead of "<nil>"`, restoredValidator.RestoreStateError())
+    }
+    if (validator.AppSpecificScore(badPeerId) != worstScore) {
+        t.Fatalf(`score is "%v" instead of "%v"`, validator.AppSpecificScore(badPeerId), worstScore)
+    }
+    if (restoredValidator.AppSpecificScore(badPeerId) != worstScore) {
+        t.Fatalf(`restored score is "%v" instead of "%v"`, restoredValidator.AppSpecificScore(badPeerId), worstScore)
+    }
+    peerStatistics := getPeerStatistics(goodPeerId, restoredValidator)
+    if (peerStatistics.challengeCount != 3 || peerStatistics.completedChallengeCount != 3) {
+        t.Fatalf(`restored challenge counts are "%v" and "%v" instead of "3" and "3"`, peerStatistics.challengeCount, peerStatistics.completedChallengeCount)
+    }
+}
+
+func TestFileStateStoreDecay(t *testing.T) {
+    mockHost, peerId := newTestHost()
+    stateStore := NewFileStateStore(filepath.Join(t.TempDir(), "state.json"))
+    clock := newTestClock()
+    err := stateStore.Save(&ValidatorState{
+        Timestamp: clock.time.Add(-defaultStatisticsHalfLife),
+        PeersStatistics: []PeerStatisticsState{
+            PeerStatisticsState{Key: []byte(peerId), ChallengeCount: 200, CompletedChallengeCount: 10},
+        },
+    })
+    if (err != nil) {
+        t.Fatalf(`Save error is "%v" instead of "<nil>"`, err)
+    }
+
+    // a half life later the counts are halved
+    validator := NewValidator(mockHost, WithStateStore(stateStore), WithClock(clock.now))
+    peerStatistics := getPeerStatistics(peerId, validator)
+    if (peerStatistics.challengeCount != 100 || peerStatistics.completedChallengeCount != 5) {
+        t.Fatalf(`restored challenge counts are "%v" and "%v" instead of "100" and "5"`, peerStatistics.challengeCount, peerStatistics.completedChallengeCount)
+    }
+
+    // long expired statistics are not restored
+    stateStore.Save(&ValidatorState{
+        Timestamp: clock.time.Add(-100 * defaultStatisticsHalfLife),
+        PeersStatistics: []PeerStatisticsState{
+            PeerStatisticsState{Key: []byte(peerId), ChallengeCount: 200, CompletedChallengeCount: 10},
+        },
+    })
+    validator = NewValidator(mockHost, WithStateStore(stateStore), WithClock(clock.now))
+    if (validator.peersStatistics.Len() != 0) {
+        t.Fatalf(`restored statistics count is "%v" instead of "0"`, validator.peersStatistics.Len())
+    }
+}
+
+func TestFileStateStoreErrors(t *testing.T) {
+    mockHost := newMockHost()
+    path := filepath.Join(t.TempDir(), "state.json")
+
+    // no state yet
+    validator := NewValidator(mockHost, WithStateStore(NewFileStateStore(path)))
+    if (validator.RestoreStateError() != nil) {
+        t.Fatalf(`RestoreStateError is "%v" instead of "<nil>"`, validator.RestoreStateError())
+    }
+
+    // an invalid state starts with empty statistics
+    os.WriteFile(path, []byte("invalid state"), 0644)
+    validator = NewValidator(mockHost, WithStateStore(NewFileStateStore(path)))
+    if (validator.RestoreStateError() == nil) {
+        t.Fatalf(`RestoreStateError is "<nil>" instead of an error`)
+    }
+    if (validator.peersStatistics.Len() != 0) {
+        t.Fatalf(`restored statistics count is "%v" instead of "0"`, validator.peersStatistics.Len())
+    }
+
+    if (NewValidator(mockHost).SaveState() == nil) {
+        t.Fatalf(`SaveState without state store error is "<nil>" instead of an error`)
+    }
+}
+
+func TestPersistStatePeriodically(t *testing.T) {
+    mockHost, peerId := newTestHost()
+    path := filepath.Join(t.TempDir(), "state.json")
+    validator := NewValidator(mockHost, WithStateStore(NewFileStateStore(path)))
+    sendFailedChallenges(validator, peerId, 1)
+
+    ctx, cancel := context.WithCancel(context.Background())
+    validator.PersistStatePeriodically(ctx, 10 * time.Millisecond, nil)
+    deadline := time.Now().Add(5 * time.Second)
+    for time.Now().Before(deadline) {
+        state, _ := NewFileStateStore(path).Load()
+        if (state != nil && len(state.PeersStatistics) == 1) {
+            cancel()
+            return
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+    cancel()
+    t.Fatalf(`state was not persisted`)
+}
+
+func TestPersistStatePeriodicallyErrors(t *testing.T) {
+    // the directory of the state doesn't exist
+    validator := NewValidator(newMockHost(), WithStateStore(NewFileStateStore(filepath.Join(t.TempDir(), "missing", "state.json"))))
+    errs := make(chan error, 1)
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    validator.PersistStatePeriodically(ctx, 10 * time.Millisecond, func(err error) {
+        select {
+        case errs <- err:
+        default:
+        }
+    })
+    select {
+    case err := <-errs:
+        if (err == nil) {
+            t.Fatalf(`save error is "<nil>" instead of an error`)
+        }
+    case <-time.After(5 * time.Second):
+        t.Fatalf(`save error was not reported`)
+    }
+}